@@ -16,9 +16,10 @@ import (
 
 // ESTRUCTURAS DE DATOS
 type Rating struct {
-	UserID  int
-	MovieID int
-	Rating  float64
+	UserID    int
+	MovieID   int
+	Rating    float64
+	Timestamp int64
 }
 
 type Movie struct {
@@ -41,6 +42,7 @@ type Recommendation struct {
 
 type DataSet struct {
 	UserRatingsMap  map[int]UserRatings
+	UserTimestamps  map[int]map[int]int64 // userID -> movieID -> timestamp
 	Movies          map[int]Movie
 	UserAvgRatings  map[int]float64
 	GlobalAvgRating float64
@@ -114,10 +116,17 @@ func LoadRatings(filepath string) ([]Rating, error) {
 			continue
 		}
 
+		// Timestamp opcional (cuarta columna)
+		var timestamp int64
+		if len(record) >= 4 {
+			timestamp, _ = strconv.ParseInt(record[3], 10, 64)
+		}
+
 		ratings = append(ratings, Rating{
-			UserID:  userID,
-			MovieID: movieID,
-			Rating:  rating,
+			UserID:    userID,
+			MovieID:   movieID,
+			Rating:    rating,
+			Timestamp: timestamp,
 		})
 
 		count++
@@ -135,6 +144,7 @@ func BuildMatrices(ratings []Rating) *DataSet {
 
 	ds := &DataSet{
 		UserRatingsMap: make(map[int]UserRatings),
+		UserTimestamps: make(map[int]map[int]int64),
 		UserAvgRatings: make(map[int]float64),
 		AllUserIDs:     make([]int, 0),
 	}
@@ -142,8 +152,10 @@ func BuildMatrices(ratings []Rating) *DataSet {
 	for _, r := range ratings {
 		if ds.UserRatingsMap[r.UserID] == nil {
 			ds.UserRatingsMap[r.UserID] = make(UserRatings)
+			ds.UserTimestamps[r.UserID] = make(map[int]int64)
 		}
 		ds.UserRatingsMap[r.UserID][r.MovieID] = r.Rating
+		ds.UserTimestamps[r.UserID][r.MovieID] = r.Timestamp
 	}
 
 	ds.TotalRatings = len(ratings)
@@ -1,26 +1,53 @@
 package main
 
 import (
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"runtime"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
+// Tamaño máximo del cuerpo de una solicitud (lo aplica datasetRouter)
+const maxRequestBody = 1 << 20 // 1 MiB
+
 type APIServer struct {
 	coordinator *DistributedCoordinator
 	db          *Database
 	metrics     *SystemMetrics
+	inflight    *requestGroup
+	activity    *activityTracker
+	adminToken  string // vacío: endpoints de administración solo desde localhost
+	traffic     *liveTraffic
 	mu          sync.RWMutex
 }
 
 type RecommendationAPIRequest struct {
-	UserID int `json:"user_id"`
-	TopN   int `json:"top_n"`
+	UserID  int             `json:"user_id"`
+	TopN    int             `json:"top_n"`
+	Ratings map[int]float64 `json:"ratings,omitempty"` // perfil anónimo en lugar de user_id
+
+	Algorithm string `json:"algorithm,omitempty"` // knn (default), content, hybrid, bpr, cooccurrence o slope_one
+
+	// Opcionales del híbrido; por defecto los de la configuración
+	Blend   string             `json:"blend,omitempty"` // weighted, rrf o switching
+	Weights map[string]float64 `json:"weights,omitempty"`
+
+	// Opcionales: recomendaciones sensibles al tiempo
+	AsOf         int64   `json:"as_of,omitempty"` // segundos Unix
+	HalfLifeDays float64 `json:"half_life_days,omitempty"`
+	Mode         string  `json:"mode,omitempty"` // "recent"
+}
+
+func (req RecommendationAPIRequest) timeOptions() TimeOptions {
+	return TimeOptions{AsOf: req.AsOf, HalfLifeDays: req.HalfLifeDays, Mode: req.Mode}
 }
 
 type RecommendationAPIResponse struct {
@@ -32,10 +59,48 @@ type RecommendationAPIResponse struct {
 	Metrics         APIMetrics           `json:"metrics"`
 }
 
+type OnboardingAPIRequest struct {
+	Ratings map[int]float64 `json:"ratings"` // movieID -> rating, no se almacenan
+	Genre   string          `json:"genre"`
+	TopN    int             `json:"top_n"`
+}
+
+type RatingAPIRequest struct {
+	UserID    int     `json:"user_id"`
+	MovieID   int     `json:"movie_id"`
+	Rating    float64 `json:"rating"`
+	Timestamp int64   `json:"timestamp"` // segundos Unix; por defecto ahora
+}
+
+type FeedbackAPIRequest struct {
+	MovieID int    `json:"movie_id"`
+	Type    string `json:"type"` // dismiss, not_interested o already_seen
+}
+
+type CacheAdminResponse struct {
+	Stats   CacheStats       `json:"stats"`
+	Entries []CacheEntryInfo `json:"entries"`
+}
+
+type GroupRecommendationAPIRequest struct {
+	UserIDs  []int  `json:"user_ids"`
+	Strategy string `json:"strategy"` // average, least_misery, most_pleasure, fairness
+	TopN     int    `json:"top_n"`
+}
+
+type GroupRecommendationAPIResponse struct {
+	UserIDs         []int                `json:"user_ids"`
+	Strategy        string               `json:"strategy"`
+	Recommendations []RecommendationItem `json:"recommendations"`
+	ProcessTimeMS   float64              `json:"process_time_ms"`
+	NodesUsed       int                  `json:"nodes_used"`
+}
+
 type RecommendationItem struct {
 	MovieID        int     `json:"movie_id"`
 	Title          string  `json:"title"`
 	PredictedScore float64 `json:"predicted_score"`
+	Source         string  `json:"source,omitempty"` // algoritmo que aportó el ítem (híbrido)
 }
 
 type APIMetrics struct {
@@ -73,6 +138,7 @@ type SystemHealthMetrics struct {
 	CPUUsagePercent float64 `json:"cpu_usage_percent"`
 	MemoryUsageMB   uint64  `json:"memory_usage_mb"`
 	CacheHitRate    float64 `json:"cache_hit_rate"`
+	Coalesced       int     `json:"coalesced_requests"`
 }
 
 type MetricsResponse struct {
@@ -101,7 +167,7 @@ type MetricsComparison struct {
 func enableCORS(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
 
 		if r.Method == "OPTIONS" {
@@ -113,6 +179,34 @@ func enableCORS(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// Endpoints de administración: token Bearer si se configuró -admin-token,
+// si no solo clientes locales. Sin CORS: un navegador no puede llamarlos
+// desde otra página.
+func (api *APIServer) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if api.adminToken != "" {
+			token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(token), []byte(api.adminToken)) != 1 {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+		} else if !isLoopback(r.RemoteAddr) {
+			http.Error(w, "Admin endpoints are only available from localhost", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func isLoopback(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
 // Registro de Middleware
 func loggingMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -139,45 +233,135 @@ func (api *APIServer) handleRecommendations(w http.ResponseWriter, r *http.Reque
 	if req.TopN <= 0 {
 		req.TopN = 10
 	}
+	if !validAlgorithm(req.Algorithm) {
+		http.Error(w, fmt.Sprintf("Unknown algorithm: %s", req.Algorithm), http.StatusBadRequest)
+		return
+	}
+	// Las recomendaciones temporales son de k-NN
+	if req.timeOptions().Enabled() && req.Algorithm != "" && req.Algorithm != AlgorithmKNN {
+		http.Error(w, fmt.Sprintf("Algorithm %s cannot be combined with as_of, half_life_days or mode (only knn)", req.Algorithm), http.StatusBadRequest)
+		return
+	}
+
+	// Visitantes sin cuenta: ratings en la solicitud, sin caché ni persistencia.
+	// El perfil anónimo solo se recomienda con k-NN / cold start
+	if req.UserID == 0 && len(req.Ratings) > 0 {
+		if (req.Algorithm != "" && req.Algorithm != AlgorithmKNN) || req.timeOptions().Enabled() || req.Blend != "" || len(req.Weights) > 0 {
+			http.Error(w, "Anonymous recommendations (ratings without user_id) only support the knn algorithm, without as_of, half_life_days, mode, blend or weights", http.StatusBadRequest)
+			return
+		}
+		api.handleAnonymousRecommendations(w, req)
+		return
+	}
 
 	startTime := time.Now()
+	api.beginLiveRequest()
+	defer api.endLiveRequest()
 
-	// Verificar caché en base de datos
-	cacheHit := false
-	cachedRecs, err := api.db.GetCachedRecommendations(req.UserID, req.TopN)
+	// La clave de caché incluye algoritmo, top_n y parámetros
+	key := recommendationCacheKey(req)
+	recommendations, nodesUsed, cacheHit, err := api.cachedRecommendations(key, func() ([]RecommendationItem, int, error) {
+		return api.computeRecommendations(req)
+	})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error getting recommendations: %v", err), errorStatus(err))
+		return
+	}
 
-	var recommendations []RecommendationItem
-	var nodesUsed int
+	// Usuario activo, candidato al precalentamiento de caché
+	api.activity.Record(req)
+	api.writeRecommendationResponse(w, req.UserID, recommendations, nodesUsed, cacheHit, startTime)
+}
 
-	if err == nil && len(cachedRecs) > 0 {
-		// Cache hit
-		cacheHit = true
-		recommendations = cachedRecs
-		nodesUsed = 0
-		log.Printf("[API] Cache hit para usuario %d", req.UserID)
-	} else {
-		// Cache miss - calcular recomendaciones distribuidas
-		distRecs, nodes, distErr := api.coordinator.GetDistributedRecommendations(req.UserID, req.TopN)
-		if distErr != nil {
-			http.Error(w, fmt.Sprintf("Error getting recommendations: %v", distErr), http.StatusInternalServerError)
-			return
+// Los parámetros inválidos (requestError) son errores del cliente, un modelo
+// en preparación es temporal; el resto, como los fallos de los workers, son
+// del servidor
+func errorStatus(err error) int {
+	var invalid requestError
+	if errors.As(err, &invalid) {
+		return http.StatusBadRequest
+	}
+	if errors.Is(err, errModelNotReady) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// Buscar en caché y, si no está, calcular y guardar. Las solicitudes
+// idénticas que llegan mientras otra calcula esperan su resultado.
+func (api *APIServer) cachedRecommendations(key CacheKey, compute func() ([]RecommendationItem, int, error)) ([]RecommendationItem, int, bool, error) {
+	if cachedRecs, err := api.db.GetCachedRecommendations(key); err == nil {
+		api.metrics.RecordCacheHit()
+		log.Printf("[API] Cache hit para usuario %d", key.UserID)
+		return cachedRecs, 0, true, nil
+	}
+
+	recommendations, nodesUsed, coalesced, err := api.inflight.Do(key, func() ([]RecommendationItem, int, error) {
+		generation := api.db.CacheGeneration(key.UserID)
+		recommendations, nodesUsed, err := compute()
+		if err == nil && len(recommendations) > 0 {
+			api.db.CacheRecommendations(key, recommendations, generation)
 		}
+		return recommendations, nodesUsed, err
+	})
+	if coalesced {
+		api.metrics.RecordCoalescedRequest()
+	} else {
+		api.metrics.RecordCacheMiss()
+	}
+	if err != nil {
+		return nil, nodesUsed, false, err
+	}
+	return recommendations, nodesUsed, false, nil
+}
 
-		recommendations = distRecs
-		nodesUsed = nodes
+// Calcular recomendaciones según algoritmo y parámetros de la solicitud
+func (api *APIServer) computeRecommendations(req RecommendationAPIRequest) ([]RecommendationItem, int, error) {
+	// Recomendaciones con as_of, decaimiento temporal o modo "recent"
+	if timeOpts := req.timeOptions(); timeOpts.Enabled() {
+		return api.coordinator.WithFeedback(req.UserID, req.TopN, func(n int) ([]RecommendationItem, int, error) {
+			return api.coordinator.GetTimeAwareRecommendations(req.UserID, n, timeOpts)
+		})
+	}
 
-		// Guardar en caché
-		go api.db.CacheRecommendations(req.UserID, recommendations)
+	// Híbrido con pesos o combinación de la solicitud
+	if req.Algorithm == AlgorithmHybrid {
+		opts := HybridOptions{Blend: req.Blend, Weights: req.Weights}
+		return api.coordinator.WithFeedback(req.UserID, req.TopN, func(n int) ([]RecommendationItem, int, error) {
+			return api.coordinator.GetHybridRecommendations(req.UserID, n, opts)
+		})
 	}
 
+	return api.coordinator.GetRecommendations(req.UserID, req.TopN, req.Algorithm)
+}
+
+// Recomendaciones para un perfil anónimo enviado en POST /api/recommendations
+func (api *APIServer) handleAnonymousRecommendations(w http.ResponseWriter, req RecommendationAPIRequest) {
+	startTime := time.Now()
+
+	recommendations, nodesUsed, err := api.coordinator.GetAnonymousRecommendations(req.Ratings, "", req.TopN)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error getting recommendations: %v", err), errorStatus(err))
+		return
+	}
+
+	api.writeAnonymousResponse(w, recommendations, nodesUsed, startTime)
+}
+
+// Respuesta común para perfiles anónimos (sin user_id ni caché)
+func (api *APIServer) writeAnonymousResponse(w http.ResponseWriter, recommendations []RecommendationItem, nodesUsed int, startTime time.Time) {
+	api.writeRecommendationResponse(w, 0, recommendations, nodesUsed, false, startTime)
+}
+
+// Respuesta de POST /api/recommendations con métricas de la solicitud
+func (api *APIServer) writeRecommendationResponse(w http.ResponseWriter, userID int, recommendations []RecommendationItem, nodesUsed int, cacheHit bool, startTime time.Time) {
 	processTime := time.Since(startTime).Milliseconds()
 
-	// Obtener métricas actuales
 	var memStats runtime.MemStats
 	runtime.ReadMemStats(&memStats)
 
 	response := RecommendationAPIResponse{
-		UserID:          req.UserID,
+		UserID:          userID,
 		Recommendations: recommendations,
 		ProcessTimeMS:   float64(processTime),
 		NodesUsed:       nodesUsed,
@@ -189,7 +373,113 @@ func (api *APIServer) handleRecommendations(w http.ResponseWriter, r *http.Reque
 		},
 	}
 
-	// Registrar métricas
+	api.metrics.RecordRequest(float64(processTime), nodesUsed > 0)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// Handler: POST /api/recommendations/onboarding
+func (api *APIServer) handleOnboarding(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req OnboardingAPIRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.TopN <= 0 {
+		req.TopN = 10
+	}
+
+	startTime := time.Now()
+
+	recommendations, nodesUsed, err := api.coordinator.GetAnonymousRecommendations(req.Ratings, req.Genre, req.TopN)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error getting recommendations: %v", err), errorStatus(err))
+		return
+	}
+
+	api.writeAnonymousResponse(w, recommendations, nodesUsed, startTime)
+}
+
+// Handler: GET /api/recommendations/batch?user_id=&top_n=
+func (api *APIServer) handleBatchRecommendations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := queryInt(r, "user_id", 0)
+	if userID == 0 {
+		http.Error(w, "user_id is required", http.StatusBadRequest)
+		return
+	}
+
+	topN := queryInt(r, "top_n", 10)
+	if topN == 0 {
+		topN = 10
+	}
+
+	startTime := time.Now()
+	recommendations, err := api.coordinator.GetBatchRecommendations(userID, topN)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, errBatchUserNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, errNoBatchFile):
+			status = http.StatusServiceUnavailable
+		}
+		http.Error(w, fmt.Sprintf("Error getting batch recommendations: %v", err), status)
+		return
+	}
+
+	api.writeRecommendationResponse(w, userID, recommendations, 0, false, startTime)
+}
+
+// Handler: POST /api/recommendations/group
+func (api *APIServer) handleGroupRecommendations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req GroupRecommendationAPIRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.TopN <= 0 {
+		req.TopN = 10
+	}
+	if req.Strategy == "" {
+		req.Strategy = GroupAverage
+	}
+
+	startTime := time.Now()
+
+	recommendations, nodesUsed, err := api.coordinator.GetGroupRecommendations(req.UserIDs, req.Strategy, req.TopN)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error getting recommendations: %v", err), errorStatus(err))
+		return
+	}
+
+	processTime := time.Since(startTime).Milliseconds()
+
+	response := GroupRecommendationAPIResponse{
+		UserIDs:         req.UserIDs,
+		Strategy:        req.Strategy,
+		Recommendations: recommendations,
+		ProcessTimeMS:   float64(processTime),
+		NodesUsed:       nodesUsed,
+	}
+
 	api.metrics.RecordRequest(float64(processTime), nodesUsed > 0)
 
 	w.Header().Set("Content-Type", "application/json")
@@ -247,6 +537,7 @@ func (api *APIServer) handleHealth(w http.ResponseWriter, r *http.Request) {
 		CPUUsagePercent: api.metrics.GetCurrentCPU(),
 		MemoryUsageMB:   memStats.Alloc / 1024 / 1024,
 		CacheHitRate:    api.metrics.GetCacheHitRate(),
+		Coalesced:       api.metrics.GetCoalescedRequests(),
 	}
 
 	response := HealthResponse{
@@ -296,13 +587,45 @@ func (api *APIServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-// Handler: GET /api/users/:id
-func (api *APIServer) handleGetUser(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
+// Handler: POST /api/ratings
+// Es la entrada de ratings nuevos que necesitan las actualizaciones
+// incrementales de Slope One; antes el dataset solo venía de ratings.csv.
+func (api *APIServer) handleAddRating(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
+	var req RatingAPIRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.UserID <= 0 || req.MovieID <= 0 {
+		http.Error(w, "Invalid user or movie ID", http.StatusBadRequest)
+		return
+	}
+	if req.Timestamp <= 0 {
+		req.Timestamp = time.Now().Unix()
+	}
+
+	if err := api.coordinator.AddRating(req.UserID, req.MovieID, req.Rating, req.Timestamp); err != nil {
+		status := http.StatusInternalServerError
+		var reqErr requestError
+		if errors.As(err, &reqErr) {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, fmt.Sprintf("Error adding rating: %v", err), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(req)
+}
+
+// Handler: GET /api/users/:id
+func (api *APIServer) handleGetUser(w http.ResponseWriter, r *http.Request) {
 	// Extraer ID del path
 	pathParts := splitPath(r.URL.Path)
 	if len(pathParts) < 3 {
@@ -316,6 +639,30 @@ func (api *APIServer) handleGetUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// /api/users/{id}/feedback
+	if len(pathParts) >= 4 && pathParts[3] == "feedback" {
+		api.handleUserFeedback(w, r, userID)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// ?detail=full devuelve el perfil de gustos completo
+	if r.URL.Query().Get("detail") == "full" {
+		profile, err := api.db.GetUserProfile(userID)
+		if err != nil {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(profile)
+		return
+	}
+
 	user, err := api.db.GetUser(userID)
 	if err != nil {
 		http.Error(w, "User not found", http.StatusNotFound)
@@ -326,6 +673,177 @@ func (api *APIServer) handleGetUser(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(user)
 }
 
+// Handler: POST/GET /api/users/:id/feedback
+func (api *APIServer) handleUserFeedback(w http.ResponseWriter, r *http.Request, userID int) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(api.db.GetFeedback(userID))
+	case http.MethodPost:
+		var req FeedbackAPIRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		event, err := api.db.AddFeedback(userID, req.MovieID, req.Type)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error saving feedback: %v", err), errorStatus(err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(event)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// Handler: GET/DELETE /api/admin/cache[?user_id=]
+func (api *APIServer) handleAdminCache(w http.ResponseWriter, r *http.Request) {
+	userID := queryInt(r, "user_id", 0)
+	cache := api.db.cache()
+
+	switch r.Method {
+	case http.MethodGet:
+		response := CacheAdminResponse{
+			Stats:   cache.Stats(),
+			Entries: cache.Entries(userID, queryInt(r, "limit", 100)),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	case http.MethodDelete:
+		var removed int
+		if userID != 0 {
+			removed = cache.InvalidateUser(userID)
+		} else {
+			removed = cache.Purge()
+		}
+		log.Printf("[API] Caché purgada: %d entradas", removed)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]int{"removed": removed})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// Handler: GET/POST /api/admin/snapshot
+func (api *APIServer) handleAdminSnapshot(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		snapshots, err := api.db.ListSnapshots()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error listing snapshots: %v", err), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"snapshots": snapshots})
+	case http.MethodPost:
+		info, err := api.db.CreateSnapshot("manual")
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, errSnapshotsUnavailable) {
+				status = http.StatusNotImplemented
+			}
+			http.Error(w, fmt.Sprintf("Error creating snapshot: %v", err), status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(info)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// Handler: POST /api/admin/restore?version=
+func (api *APIServer) handleAdminRestore(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	version := r.URL.Query().Get("version")
+	if version == "" {
+		http.Error(w, "version is required", http.StatusBadRequest)
+		return
+	}
+
+	backup, err := api.db.RestoreSnapshot(version)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, errSnapshotNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, errRestoreRatings):
+			status = http.StatusConflict
+		case errors.Is(err, errSnapshotsUnavailable):
+			status = http.StatusNotImplemented
+		}
+		http.Error(w, fmt.Sprintf("Error restoring snapshot: %v", err), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"restored": version,
+		"backup":   backup,
+	})
+}
+
+// Handler: GET/POST /api/admin/reload?dir=&version=&force=
+func (api *APIServer) handleAdminReload(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(api.coordinator.ReloadStatus())
+	case http.MethodPost:
+		query := r.URL.Query()
+		dir := query.Get("dir")
+		if dir == "" {
+			http.Error(w, "dir is required", http.StatusBadRequest)
+			return
+		}
+
+		status, err := api.coordinator.StartReload(dir, query.Get("version"), query.Get("force") == "true")
+		if err != nil {
+			code := http.StatusBadRequest
+			if err == errReloadBusy {
+				code = http.StatusConflict
+			}
+			http.Error(w, fmt.Sprintf("Error starting reload: %v", err), code)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(status)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// Handler: POST /api/admin/reload/rollback
+func (api *APIServer) handleAdminReloadRollback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Solo falla si hay una recarga en curso o no hay versión anterior
+	status, err := api.coordinator.RollbackDataset()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error rolling back dataset: %v", err), http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(status)
+}
+
 // Handler: GET /api/movies/:id
 func (api *APIServer) handleGetMovie(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -355,6 +873,69 @@ func (api *APIServer) handleGetMovie(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(movie)
 }
 
+// Handler: GET /api/movies/trending
+func (api *APIServer) handleTrendingMovies(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	windowDays := queryInt(r, "window_days", 7)
+	limit := queryInt(r, "limit", 20)
+
+	movies := api.db.GetTrendingMovies(query.Get("genre"), windowDays, limit)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(movies)
+}
+
+// Handler: GET /api/movies/popular
+func (api *APIServer) handlePopularMovies(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	windowDays := queryInt(r, "window_days", 0)
+	minRatings := queryInt(r, "min_ratings", 10)
+	limit := queryInt(r, "limit", 20)
+
+	movies := api.db.GetPopularMovies(query.Get("genre"), windowDays, minRatings, limit)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(movies)
+}
+
+// Handler: GET /api/movies/search?q=
+func (api *APIServer) handleSearchMovies(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query().Get("q")
+	if query == "" {
+		http.Error(w, "Query parameter q required", http.StatusBadRequest)
+		return
+	}
+
+	results := api.db.SearchMovies(query, queryInt(r, "limit", 10))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+// Helper para leer parámetros enteros de la query
+func queryInt(r *http.Request, name string, defaultValue int) int {
+	value, err := strconv.Atoi(r.URL.Query().Get(name))
+	if err != nil || value < 0 {
+		return defaultValue
+	}
+	return value
+}
+
 // Helper para dividir path
 func splitPath(path string) []string {
 	parts := make([]string, 0)
@@ -385,44 +966,84 @@ func splitString(s string, sep rune) []string {
 	return parts
 }
 
-// Iniciar servidor API
-func StartAPIServer(coordinator *DistributedCoordinator, db *Database, metrics *SystemMetrics, port string) {
-	api := &APIServer{
+// Servidor API de un dataset
+func NewAPIServer(coordinator *DistributedCoordinator, db *Database, metrics *SystemMetrics) *APIServer {
+	return &APIServer{
 		coordinator: coordinator,
 		db:          db,
 		metrics:     metrics,
+		inflight:    newRequestGroup(),
+		activity:    newActivityTracker(),
+		traffic:     &liveTraffic{},
 	}
+}
 
-	// Configurar rutas
-	http.HandleFunc("/api/recommendations", loggingMiddleware(enableCORS(api.handleRecommendations)))
-	http.HandleFunc("/api/health", loggingMiddleware(enableCORS(api.handleHealth)))
-	http.HandleFunc("/api/metrics", loggingMiddleware(enableCORS(api.handleMetrics)))
-	http.HandleFunc("/api/users/", loggingMiddleware(enableCORS(api.handleGetUser)))
-	http.HandleFunc("/api/movies/", loggingMiddleware(enableCORS(api.handleGetMovie)))
+// Rutas de la API de un dataset
+func (api *APIServer) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/recommendations", loggingMiddleware(enableCORS(api.handleRecommendations)))
+	mux.HandleFunc("/api/recommendations/onboarding", loggingMiddleware(enableCORS(api.handleOnboarding)))
+	mux.HandleFunc("/api/recommendations/batch", loggingMiddleware(enableCORS(api.handleBatchRecommendations)))
+	mux.HandleFunc("/api/recommendations/group", loggingMiddleware(enableCORS(api.handleGroupRecommendations)))
+	mux.HandleFunc("/api/health", loggingMiddleware(enableCORS(api.handleHealth)))
+	mux.HandleFunc("/api/metrics", loggingMiddleware(enableCORS(api.handleMetrics)))
+	mux.HandleFunc("/api/admin/cache", loggingMiddleware(api.requireAdmin(api.handleAdminCache)))
+	mux.HandleFunc("/api/admin/snapshot", loggingMiddleware(api.requireAdmin(api.handleAdminSnapshot)))
+	mux.HandleFunc("/api/admin/restore", loggingMiddleware(api.requireAdmin(api.handleAdminRestore)))
+	mux.HandleFunc("/api/admin/reload", loggingMiddleware(api.requireAdmin(api.handleAdminReload)))
+	mux.HandleFunc("/api/admin/reload/rollback", loggingMiddleware(api.requireAdmin(api.handleAdminReloadRollback)))
+	mux.HandleFunc("/api/ratings", loggingMiddleware(enableCORS(api.handleAddRating)))
+	mux.HandleFunc("/api/users/", loggingMiddleware(enableCORS(api.handleGetUser)))
+	mux.HandleFunc("/api/movies/search", loggingMiddleware(enableCORS(api.handleSearchMovies)))
+	mux.HandleFunc("/api/movies/trending", loggingMiddleware(enableCORS(api.handleTrendingMovies)))
+	mux.HandleFunc("/api/movies/popular", loggingMiddleware(enableCORS(api.handlePopularMovies)))
+	mux.HandleFunc("/api/movies/", loggingMiddleware(enableCORS(api.handleGetMovie)))
+	return mux
+}
 
-	// Ruta raíz
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path != "/" {
-			http.NotFound(w, r)
-			return
-		}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]string{
-			"service": "Movie Recommendation System API",
-			"version": "1.0.0",
-			"status":  "running",
-		})
-	})
+// Iniciar servidor API con todos los datasets
+func StartAPIServer(tenants []*Tenant, port string, warmInterval time.Duration) {
+	// El precalentamiento de un dataset espera también al tráfico de los demás
+	traffic := &liveTraffic{}
+	for _, tenant := range tenants {
+		tenant.api.traffic = traffic
+		tenant.api.StartCacheWarming(warmInterval)
+	}
+	router := newDatasetRouter(tenants)
 
 	log.Printf("[API] Servidor iniciado en %s", port)
 	log.Printf("[API] Endpoints disponibles:")
+	log.Printf("[API]   GET    /api/datasets")
 	log.Printf("[API]   POST   /api/recommendations")
+	log.Printf("[API]   POST   /api/recommendations/onboarding")
+	log.Printf("[API]   GET    /api/recommendations/batch")
+	log.Printf("[API]   POST   /api/recommendations/group")
 	log.Printf("[API]   GET    /api/health")
 	log.Printf("[API]   GET    /api/metrics")
+	log.Printf("[API]   POST   /api/ratings")
+	log.Printf("[API]   GET    /api/admin/cache")
+	log.Printf("[API]   DELETE /api/admin/cache")
+	log.Printf("[API]   GET    /api/admin/snapshot")
+	log.Printf("[API]   POST   /api/admin/snapshot")
+	log.Printf("[API]   POST   /api/admin/restore")
+	log.Printf("[API]   GET    /api/admin/reload")
+	log.Printf("[API]   POST   /api/admin/reload?dir=")
+	log.Printf("[API]   POST   /api/admin/reload/rollback")
 	log.Printf("[API]   GET    /api/users/{id}")
+	log.Printf("[API]   POST   /api/users/{id}/feedback")
 	log.Printf("[API]   GET    /api/movies/{id}")
+	log.Printf("[API]   GET    /api/movies/search?q=")
+	log.Printf("[API]   GET    /api/movies/trending")
+	log.Printf("[API]   GET    /api/movies/popular")
+	for _, tenant := range tenants {
+		if tenant.Default {
+			log.Printf("[API] Dataset %s (%s): /api/... y /datasets/%s/api/...", tenant.Name, tenant.Dir, tenant.Name)
+		} else {
+			log.Printf("[API] Dataset %s (%s): /datasets/%s/api/... o ?dataset=%s", tenant.Name, tenant.Dir, tenant.Name, tenant.Name)
+		}
+	}
 
-	if err := http.ListenAndServe(port, nil); err != nil {
+	if err := http.ListenAndServe(port, router); err != nil {
 		log.Fatalf("[API] Error iniciando servidor: %v", err)
 	}
 }
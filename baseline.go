@@ -0,0 +1,122 @@
+package main
+
+import (
+	"log"
+	"math"
+	"time"
+)
+
+// PREDICTOR BASE - Promedio global + sesgo de usuario + sesgo de película
+// ============================================================================
+// b_ui = μ + b_u + b_i, ajustado con mínimos cuadrados alternados y
+// regularización (los sesgos de películas con pocos ratings se encogen
+// hacia 0). Se usa para centrar las desviaciones de los vecinos y como
+// predicción cuando ningún vecino valoró la película.
+const (
+	baselineIterations = 5
+	baselineUserReg    = 10.0
+	baselineItemReg    = 25.0
+)
+
+type BaselineModel struct {
+	GlobalMean float64
+	userBias   map[int]float64
+	itemBias   map[int]float64
+}
+
+// Ajustar los sesgos sobre todos los ratings del dataset local
+func (dc *DistributedCoordinator) fitBaseline() {
+	start := time.Now()
+
+	dc.localDataset.mu.RLock()
+	ratingsMap := dc.localDataset.UserRatingsMap
+	model := &BaselineModel{
+		GlobalMean: dc.localDataset.GlobalAvgRating,
+		userBias:   make(map[int]float64, len(ratingsMap)),
+		itemBias:   make(map[int]float64),
+	}
+
+	for iter := 0; iter < baselineIterations; iter++ {
+		// b_i = sum(r - μ - b_u) / (λ_i + n_i)
+		itemSums := make(map[int]float64, len(model.itemBias))
+		itemCounts := make(map[int]int, len(model.itemBias))
+		for userID, userRatings := range ratingsMap {
+			bu := model.userBias[userID]
+			for movieID, rating := range userRatings {
+				itemSums[movieID] += rating - model.GlobalMean - bu
+				itemCounts[movieID]++
+			}
+		}
+		for movieID, sum := range itemSums {
+			model.itemBias[movieID] = sum / (baselineItemReg + float64(itemCounts[movieID]))
+		}
+
+		// b_u = sum(r - μ - b_i) / (λ_u + n_u)
+		for userID, userRatings := range ratingsMap {
+			model.userBias[userID] = model.FitUserBias(userRatings)
+		}
+	}
+
+	// Error de entrenamiento, solo informativo
+	squaredError := 0.0
+	count := 0
+	for userID, userRatings := range ratingsMap {
+		bu := model.userBias[userID]
+		for movieID, rating := range userRatings {
+			diff := rating - model.Predict(bu, movieID)
+			squaredError += diff * diff
+			count++
+		}
+	}
+	dc.localDataset.mu.RUnlock()
+
+	rmse := 0.0
+	if count > 0 {
+		rmse = math.Sqrt(squaredError / float64(count))
+	}
+
+	dc.mu.Lock()
+	dc.baseline = model
+	dc.mu.Unlock()
+
+	log.Printf("[COORD] Predictor base ajustado: μ=%.3f, %d usuarios, %d películas, RMSE %.4f (%v)",
+		model.GlobalMean, len(model.userBias), len(model.itemBias), rmse, time.Since(start))
+}
+
+// Sesgo de un usuario del dataset
+func (b *BaselineModel) UserBias(userID int) float64 {
+	return b.userBias[userID]
+}
+
+// Sesgo de una película (0 si no tiene ratings)
+func (b *BaselineModel) ItemBias(movieID int) float64 {
+	return b.itemBias[movieID]
+}
+
+// Sesgo para un perfil arbitrario (anónimo, ventana temporal...) con los
+// sesgos de película ya ajustados
+func (b *BaselineModel) FitUserBias(ratings map[int]float64) float64 {
+	sum := 0.0
+	for movieID, rating := range ratings {
+		sum += rating - b.GlobalMean - b.itemBias[movieID]
+	}
+	return sum / (baselineUserReg + float64(len(ratings)))
+}
+
+// μ + b_u + b_i
+func (b *BaselineModel) Predict(userBias float64, movieID int) float64 {
+	return b.GlobalMean + userBias + b.itemBias[movieID]
+}
+
+// Predicción base para un perfil, o su promedio si no hay modelo
+func (dc *DistributedCoordinator) baselineFor(ratings map[int]float64, avg float64) func(movieID int) float64 {
+	dc.mu.RLock()
+	model := dc.baseline
+	dc.mu.RUnlock()
+
+	if model == nil {
+		return func(int) float64 { return avg }
+	}
+	bias := model.FitUserBias(ratings)
+	return func(movieID int) float64 { return model.Predict(bias, movieID) }
+}
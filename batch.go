@@ -0,0 +1,654 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GENERACIÓN BATCH - Recomendaciones offline para todos los usuarios
+// ============================================================================
+// Los usuarios se procesan en bloques con un pool de goroutines propio (el
+// mismo esquema que ParallelRecommendations) y cada bloque se escribe en
+// orden. Tras cada bloque se guarda un checkpoint con los usuarios
+// completados y el tamaño del archivo, así que una ejecución interrumpida
+// continúa donde quedó si la lista de usuarios no cambió (hash de los IDs
+// en orden y, con archivo de usuarios, su tamaño y fecha de modificación).
+// El formato binario incluye un índice y la API lo sirve directamente.
+const (
+	ModeServer      = "server"
+	ModeDistributed = "distributed" // alias de server (Dockerfile)
+	ModeBatch       = "batch"
+
+	BatchFormatCSV    = "csv"
+	BatchFormatJSONL  = "jsonl"
+	BatchFormatBinary = "bin"
+
+	batchChunkSize = 1000 // usuarios entre checkpoints
+)
+
+// Formato binario (little endian):
+//
+//	cabecera  "RECB" + versión uint32
+//	registro  userID int32, n uint16, n × (movieID int32, score float32)
+//	índice    m × (userID int32, offset uint64), ordenado por userID
+//	pie       offset del índice uint64, m uint32, "RECI"
+const (
+	batchBinaryMagic    = "RECB"
+	batchIndexMagic     = "RECI"
+	batchBinaryVersion  = 1
+	batchHeaderSize     = 8
+	batchFooterSize     = 16
+	batchIndexEntrySize = 12
+)
+
+type BatchOptions struct {
+	UsersFile   string // un userID por línea; vacío = todos los usuarios
+	Output      string
+	Format      string
+	TopN        int
+	Algorithm   string
+	Concurrency int
+}
+
+// Progreso de una ejecución, guardado en <salida>.checkpoint
+type BatchCheckpoint struct {
+	Format      string    `json:"format"`
+	TopN        int       `json:"top_n"`
+	Algorithm   string    `json:"algorithm"`
+	UsersFile   string    `json:"users_file"`
+	UsersSize   int64     `json:"users_size"`
+	UsersMTime  time.Time `json:"users_mtime"`
+	TotalUsers  int       `json:"total_users"`
+	UsersHash   string    `json:"users_hash"` // FNV-64a de los userIDs en orden
+	Completed   int       `json:"completed"`
+	OutputBytes int64     `json:"output_bytes"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Un checkpoint solo se reanuda con los mismos parámetros y la misma lista
+// de usuarios: sin archivo, todos los del dataset, que cambian con una
+// recarga o con usuarios nuevos aunque el total coincida
+func (c BatchCheckpoint) sameJob(other BatchCheckpoint) bool {
+	return c.Format == other.Format && c.TopN == other.TopN && c.Algorithm == other.Algorithm &&
+		c.UsersFile == other.UsersFile && c.UsersSize == other.UsersSize &&
+		c.UsersMTime.Equal(other.UsersMTime) && c.TotalUsers == other.TotalUsers &&
+		c.UsersHash == other.UsersHash
+}
+
+// Huella de la lista ordenada de usuarios del lote
+func batchUsersHash(userIDs []int) string {
+	hash := fnv.New64a()
+	var buf [8]byte
+	for _, userID := range userIDs {
+		binary.LittleEndian.PutUint64(buf[:], uint64(userID))
+		hash.Write(buf[:])
+	}
+	return fmt.Sprintf("%016x", hash.Sum64())
+}
+
+// Generar las recomendaciones de todos los usuarios indicados
+func (dc *DistributedCoordinator) RunBatch(opts BatchOptions) error {
+	switch opts.Format {
+	case BatchFormatCSV, BatchFormatJSONL, BatchFormatBinary:
+	default:
+		return fmt.Errorf("formato de salida desconocido: %s", opts.Format)
+	}
+	if opts.TopN <= 0 {
+		opts.TopN = 10
+	}
+	if opts.TopN > math.MaxUint16 {
+		return fmt.Errorf("top_n demasiado grande: %d", opts.TopN)
+	}
+	if opts.Algorithm == "" {
+		opts.Algorithm = AlgorithmKNN
+	}
+	if !validAlgorithm(opts.Algorithm) {
+		return fmt.Errorf("algoritmo desconocido: %s", opts.Algorithm)
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = dc.numWorkers
+	}
+
+	userIDs, err := dc.batchUsers(opts.UsersFile)
+	if err != nil {
+		return err
+	}
+	if err := dc.prepareBatchModels(opts.Algorithm); err != nil {
+		return err
+	}
+
+	checkpointPath := opts.Output + ".checkpoint"
+	checkpoint := BatchCheckpoint{
+		Format:     opts.Format,
+		TopN:       opts.TopN,
+		Algorithm:  opts.Algorithm,
+		UsersFile:  opts.UsersFile,
+		TotalUsers: len(userIDs),
+		UsersHash:  batchUsersHash(userIDs),
+	}
+	if opts.UsersFile != "" {
+		info, err := os.Stat(opts.UsersFile)
+		if err != nil {
+			return err
+		}
+		checkpoint.UsersSize, checkpoint.UsersMTime = info.Size(), info.ModTime().UTC()
+	}
+	if previous, err := loadBatchCheckpoint(checkpointPath); err == nil {
+		if previous.sameJob(checkpoint) {
+			checkpoint = previous
+			log.Printf("[BATCH] Reanudando desde el checkpoint: %d/%d usuarios", checkpoint.Completed, len(userIDs))
+		} else {
+			log.Println("[BATCH] Checkpoint de otra ejecución, empezando de cero")
+		}
+	}
+
+	file, err := os.OpenFile(opts.Output, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// Lo escrito después del último checkpoint se descarta
+	if err := file.Truncate(checkpoint.OutputBytes); err != nil {
+		return err
+	}
+	if _, err := file.Seek(checkpoint.OutputBytes, io.SeekStart); err != nil {
+		return err
+	}
+
+	writer := newBatchWriter(file, opts.Format)
+	if checkpoint.OutputBytes == 0 {
+		if err := writer.WriteHeader(); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("[BATCH] %d usuarios, algoritmo %s, top %d, formato %s, %d en paralelo",
+		len(userIDs), opts.Algorithm, opts.TopN, opts.Format, opts.Concurrency)
+
+	start := time.Now()
+	resumedAt := checkpoint.Completed
+	failed := 0
+	for chunkStart := checkpoint.Completed; chunkStart < len(userIDs); chunkStart += batchChunkSize {
+		chunkEnd := chunkStart + batchChunkSize
+		if chunkEnd > len(userIDs) {
+			chunkEnd = len(userIDs)
+		}
+		chunk := userIDs[chunkStart:chunkEnd]
+
+		results, chunkFailed := dc.batchChunk(chunk, opts)
+		failed += chunkFailed
+		for i, userID := range chunk {
+			if results[i] == nil {
+				continue
+			}
+			if err := writer.Write(userID, results[i]); err != nil {
+				return err
+			}
+		}
+		if err := writer.Flush(); err != nil {
+			return err
+		}
+		if err := file.Sync(); err != nil {
+			return err
+		}
+
+		offset, err := file.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return err
+		}
+		checkpoint.Completed = chunkEnd
+		checkpoint.OutputBytes = offset
+		if err := saveBatchCheckpoint(checkpointPath, checkpoint); err != nil {
+			return err
+		}
+
+		done := chunkEnd - resumedAt
+		rate := float64(done) / time.Since(start).Seconds()
+		eta := time.Duration(float64(len(userIDs)-chunkEnd)/rate) * time.Second
+		log.Printf("[BATCH] %d/%d usuarios (%.0f/s, faltan ~%v)", chunkEnd, len(userIDs), rate, eta)
+	}
+
+	if opts.Format == BatchFormatBinary {
+		if err := writeBatchIndex(file, checkpoint.OutputBytes); err != nil {
+			return err
+		}
+		if err := file.Sync(); err != nil {
+			return err
+		}
+	}
+	os.Remove(checkpointPath)
+
+	log.Printf("[BATCH] Terminado: %d usuarios, %d errores, %s (%v)",
+		len(userIDs), failed, opts.Output, time.Since(start))
+	return nil
+}
+
+// Usuarios del archivo (uno por línea; se ignoran vacías, comentarios, una
+// cabecera y los repetidos) o todos los del dataset
+func (dc *DistributedCoordinator) batchUsers(path string) ([]int, error) {
+	if path == "" {
+		dc.localDataset.mu.RLock()
+		userIDs := append([]int(nil), dc.localDataset.AllUserIDs...)
+		dc.localDataset.mu.RUnlock()
+		sort.Ints(userIDs)
+		return userIDs, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	userIDs := make([]int, 0)
+	seen := make(map[int]bool)
+	duplicates := 0
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		userID, err := strconv.Atoi(strings.Split(line, ",")[0])
+		if err != nil {
+			if lineNumber == 1 {
+				continue // cabecera
+			}
+			return nil, fmt.Errorf("%s:%d: userID inválido %q", path, lineNumber, line)
+		}
+		if seen[userID] {
+			duplicates++
+			continue
+		}
+		seen[userID] = true
+		userIDs = append(userIDs, userID)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if duplicates > 0 {
+		log.Printf("[BATCH] %s: %d usuarios repetidos ignorados", path, duplicates)
+	}
+	return userIDs, nil
+}
+
+// Los modelos que en modo servidor se entrenan en segundo plano aquí se
+// construyen antes de empezar
+func (dc *DistributedCoordinator) prepareBatchModels(algorithm string) error {
+	switch algorithm {
+	case AlgorithmBPR:
+		if model := dc.trainBPR(); model != nil {
+			dc.mu.Lock()
+			dc.bpr = model
+			dc.mu.Unlock()
+		}
+	case AlgorithmSlopeOne:
+		model, err := dc.buildSlopeOne()
+		if err != nil {
+			return fmt.Errorf("error construyendo Slope One: %v", err)
+		}
+		dc.mu.RLock()
+		generation := dc.datasetGen
+		dc.mu.RUnlock()
+		dc.publishSlopeOne(model, generation)
+	}
+	return nil
+}
+
+// Recomendaciones de un bloque en paralelo; nil para los usuarios con error
+func (dc *DistributedCoordinator) batchChunk(userIDs []int, opts BatchOptions) ([][]RecommendationItem, int) {
+	results := make([][]RecommendationItem, len(userIDs))
+	jobs := make(chan int, len(userIDs))
+	for i := range userIDs {
+		jobs <- i
+	}
+	close(jobs)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	failed := 0
+	for w := 0; w < opts.Concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				recommendations, _, err := dc.GetRecommendations(userIDs[i], opts.TopN, opts.Algorithm)
+				if err != nil {
+					log.Printf("[BATCH] Usuario %d: %v", userIDs[i], err)
+					mu.Lock()
+					failed++
+					mu.Unlock()
+					continue
+				}
+				results[i] = recommendations
+			}
+		}()
+	}
+	wg.Wait()
+
+	return results, failed
+}
+
+func loadBatchCheckpoint(path string) (BatchCheckpoint, error) {
+	var checkpoint BatchCheckpoint
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return checkpoint, err
+	}
+	err = json.Unmarshal(data, &checkpoint)
+	return checkpoint, err
+}
+
+// Escribir el checkpoint en un temporal y renombrar, para no dejarlo a medias
+func saveBatchCheckpoint(path string, checkpoint BatchCheckpoint) error {
+	checkpoint.UpdatedAt = time.Now()
+	data, err := json.MarshalIndent(checkpoint, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// ESCRITURA
+
+type batchWriter struct {
+	format string
+	buf    *bufio.Writer
+	csv    *csv.Writer
+}
+
+func newBatchWriter(w io.Writer, format string) *batchWriter {
+	buf := bufio.NewWriter(w)
+	return &batchWriter{format: format, buf: buf, csv: csv.NewWriter(buf)}
+}
+
+func (bw *batchWriter) WriteHeader() error {
+	switch bw.format {
+	case BatchFormatCSV:
+		return bw.csv.Write([]string{"user_id", "rank", "movie_id", "title", "predicted_score"})
+	case BatchFormatBinary:
+		header := make([]byte, batchHeaderSize)
+		copy(header, batchBinaryMagic)
+		binary.LittleEndian.PutUint32(header[4:], batchBinaryVersion)
+		_, err := bw.buf.Write(header)
+		return err
+	}
+	return nil
+}
+
+func (bw *batchWriter) Write(userID int, recommendations []RecommendationItem) error {
+	switch bw.format {
+	case BatchFormatCSV:
+		for rank, rec := range recommendations {
+			record := []string{
+				strconv.Itoa(userID),
+				strconv.Itoa(rank + 1),
+				strconv.Itoa(rec.MovieID),
+				rec.Title,
+				strconv.FormatFloat(rec.PredictedScore, 'f', 4, 64),
+			}
+			if err := bw.csv.Write(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	case BatchFormatJSONL:
+		line, err := json.Marshal(struct {
+			UserID          int                  `json:"user_id"`
+			Recommendations []RecommendationItem `json:"recommendations"`
+		}{userID, recommendations})
+		if err != nil {
+			return err
+		}
+		_, err = bw.buf.Write(append(line, '\n'))
+		return err
+	default:
+		record := make([]byte, 6+8*len(recommendations))
+		binary.LittleEndian.PutUint32(record[0:], uint32(int32(userID)))
+		binary.LittleEndian.PutUint16(record[4:], uint16(len(recommendations)))
+		for i, rec := range recommendations {
+			offset := 6 + 8*i
+			binary.LittleEndian.PutUint32(record[offset:], uint32(int32(rec.MovieID)))
+			binary.LittleEndian.PutUint32(record[offset+4:], math.Float32bits(float32(rec.PredictedScore)))
+		}
+		_, err := bw.buf.Write(record)
+		return err
+	}
+}
+
+func (bw *batchWriter) Flush() error {
+	bw.csv.Flush()
+	if err := bw.csv.Error(); err != nil {
+		return err
+	}
+	return bw.buf.Flush()
+}
+
+type batchIndexEntry struct {
+	userID int32
+	offset uint64
+}
+
+// Recorrer los registros escritos y añadir el índice y el pie
+func writeBatchIndex(file *os.File, recordsEnd int64) error {
+	reader := bufio.NewReader(io.NewSectionReader(file, batchHeaderSize, recordsEnd-batchHeaderSize))
+	entries := make([]batchIndexEntry, 0)
+	header := make([]byte, 6)
+	for offset := int64(batchHeaderSize); offset < recordsEnd; {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			return fmt.Errorf("registro incompleto en offset %d: %v", offset, err)
+		}
+		userID := int32(binary.LittleEndian.Uint32(header))
+		count := int64(binary.LittleEndian.Uint16(header[4:]))
+		if _, err := reader.Discard(int(8 * count)); err != nil {
+			return fmt.Errorf("registro incompleto en offset %d: %v", offset, err)
+		}
+		entries = append(entries, batchIndexEntry{userID, uint64(offset)})
+		offset += 6 + 8*count
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].userID < entries[j].userID })
+
+	if _, err := file.Seek(recordsEnd, io.SeekStart); err != nil {
+		return err
+	}
+	buf := bufio.NewWriter(file)
+	entry := make([]byte, batchIndexEntrySize)
+	for _, e := range entries {
+		binary.LittleEndian.PutUint32(entry, uint32(e.userID))
+		binary.LittleEndian.PutUint64(entry[4:], e.offset)
+		if _, err := buf.Write(entry); err != nil {
+			return err
+		}
+	}
+	footer := make([]byte, batchFooterSize)
+	binary.LittleEndian.PutUint64(footer, uint64(recordsEnd))
+	binary.LittleEndian.PutUint32(footer[8:], uint32(len(entries)))
+	copy(footer[12:], batchIndexMagic)
+	if _, err := buf.Write(footer); err != nil {
+		return err
+	}
+	return buf.Flush()
+}
+
+// LECTURA
+
+// Archivo binario abierto para consultas; el índice se mantiene en memoria
+// y los registros se leen del disco
+type BatchStore struct {
+	Path    string
+	Created time.Time
+	file    *os.File
+	index   []batchIndexEntry
+}
+
+func OpenBatchStore(path string) (*BatchStore, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	store, err := readBatchStore(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	store.Path = path
+	return store, nil
+}
+
+func readBatchStore(file *os.File) (*BatchStore, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := info.Size()
+	if size < batchHeaderSize+batchFooterSize {
+		return nil, fmt.Errorf("archivo batch incompleto")
+	}
+
+	header := make([]byte, batchHeaderSize)
+	if _, err := file.ReadAt(header, 0); err != nil {
+		return nil, err
+	}
+	if string(header[:4]) != batchBinaryMagic || binary.LittleEndian.Uint32(header[4:]) != batchBinaryVersion {
+		return nil, fmt.Errorf("no es un archivo batch binario (versión %d)", batchBinaryVersion)
+	}
+
+	footer := make([]byte, batchFooterSize)
+	if _, err := file.ReadAt(footer, size-batchFooterSize); err != nil {
+		return nil, err
+	}
+	if string(footer[12:]) != batchIndexMagic {
+		return nil, fmt.Errorf("archivo batch sin índice (¿ejecución sin terminar?)")
+	}
+	indexOffset := int64(binary.LittleEndian.Uint64(footer))
+	count := int64(binary.LittleEndian.Uint32(footer[8:]))
+	if indexOffset+count*batchIndexEntrySize+batchFooterSize != size {
+		return nil, fmt.Errorf("índice batch inconsistente")
+	}
+
+	raw := make([]byte, count*batchIndexEntrySize)
+	if _, err := file.ReadAt(raw, indexOffset); err != nil {
+		return nil, err
+	}
+	index := make([]batchIndexEntry, count)
+	for i := range index {
+		entry := raw[i*batchIndexEntrySize:]
+		index[i] = batchIndexEntry{
+			userID: int32(binary.LittleEndian.Uint32(entry)),
+			offset: binary.LittleEndian.Uint64(entry[4:]),
+		}
+	}
+
+	return &BatchStore{Created: info.ModTime(), file: file, index: index}, nil
+}
+
+func (s *BatchStore) Users() int {
+	return len(s.index)
+}
+
+func (s *BatchStore) Close() error {
+	return s.file.Close()
+}
+
+// Recomendaciones precalculadas de un usuario (sin títulos)
+func (s *BatchStore) Lookup(userID int) ([]RecommendationItem, bool, error) {
+	k := sort.Search(len(s.index), func(i int) bool { return int(s.index[i].userID) >= userID })
+	if k == len(s.index) || int(s.index[k].userID) != userID {
+		return nil, false, nil
+	}
+	offset := int64(s.index[k].offset)
+
+	header := make([]byte, 6)
+	if _, err := s.file.ReadAt(header, offset); err != nil {
+		return nil, false, err
+	}
+	count := int(binary.LittleEndian.Uint16(header[4:]))
+	data := make([]byte, 8*count)
+	if _, err := s.file.ReadAt(data, offset+6); err != nil {
+		return nil, false, err
+	}
+
+	recommendations := make([]RecommendationItem, count)
+	for i := range recommendations {
+		recommendations[i] = RecommendationItem{
+			MovieID:        int(int32(binary.LittleEndian.Uint32(data[8*i:]))),
+			PredictedScore: float64(math.Float32frombits(binary.LittleEndian.Uint32(data[8*i+4:]))),
+		}
+	}
+	return recommendations, true, nil
+}
+
+// Cargar el archivo batch que sirve la API, reemplazando el anterior
+func (dc *DistributedCoordinator) LoadBatchFile(path string) error {
+	store, err := OpenBatchStore(path)
+	if err != nil {
+		return err
+	}
+
+	dc.mu.Lock()
+	previous := dc.batch
+	dc.batch = store
+	dc.mu.Unlock()
+	if previous != nil {
+		previous.Close()
+	}
+
+	log.Printf("[COORD] Archivo batch cargado: %s, %d usuarios (generado %s)",
+		path, store.Users(), store.Created.Format(time.RFC3339))
+	return nil
+}
+
+var (
+	errNoBatchFile       = fmt.Errorf("no hay archivo batch cargado")
+	errBatchUserNotFound = fmt.Errorf("usuario no encontrado en el archivo batch")
+)
+
+// Recomendaciones precalculadas con título
+func (dc *DistributedCoordinator) GetBatchRecommendations(userID int, topN int) ([]RecommendationItem, error) {
+	dc.mu.RLock()
+	store := dc.batch
+	dc.mu.RUnlock()
+
+	if store == nil {
+		return nil, errNoBatchFile
+	}
+	recommendations, found, err := store.Lookup(userID)
+	if err != nil {
+		return nil, fmt.Errorf("error leyendo el archivo batch: %v", err)
+	}
+	if !found {
+		return nil, errBatchUserNotFound
+	}
+	if len(recommendations) > topN {
+		recommendations = recommendations[:topN]
+	}
+
+	dc.localDataset.mu.RLock()
+	for i := range recommendations {
+		recommendations[i].Title = "Unknown"
+		if title, exists := dc.localDataset.Movies[recommendations[i].MovieID]; exists {
+			recommendations[i].Title = title
+		}
+	}
+	dc.localDataset.mu.RUnlock()
+
+	return recommendations, nil
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PRECALENTAMIENTO DE CACHÉ - Recomendaciones listas para usuarios activos
+// ============================================================================
+// Se registra la última solicitud de cada usuario y cuántas hizo. En cada
+// ciclo se recalculan, en los periodos sin solicitudes en curso (de ningún
+// dataset: todos usan los mismos workers) y a ritmo limitado, las del 10% de
+// usuarios más frecuentes y el 10% más recientes cuya entrada falta o
+// vencería antes del próximo ciclo.
+const (
+	defaultWarmInterval = 5 * time.Minute
+	warmFraction        = 0.10                   // fracción de usuarios por criterio
+	warmMaxUsers        = 1000                   // máximo de usuarios por criterio y ciclo
+	warmTrackedUsers    = 100000                 // usuarios recordados como máximo
+	warmRate            = 200 * time.Millisecond // pausa mínima entre cálculos
+	warmIdleGap         = time.Second            // sin solicitudes durante este tiempo = inactivo
+)
+
+type userActivity struct {
+	request  RecommendationAPIRequest // última solicitud, ya normalizada
+	requests float64                  // solicitudes, con decaimiento por ciclo
+	lastSeen time.Time
+}
+
+type activityTracker struct {
+	users map[int]*userActivity
+	mu    sync.Mutex
+}
+
+func newActivityTracker() *activityTracker {
+	return &activityTracker{users: make(map[int]*userActivity)}
+}
+
+// Registrar una solicitud de un usuario registrado
+func (t *activityTracker) Record(req RecommendationAPIRequest) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	activity, exists := t.users[req.UserID]
+	if !exists {
+		if len(t.users) >= warmTrackedUsers {
+			t.evictOldest(warmTrackedUsers / 10)
+		}
+		activity = &userActivity{}
+		t.users[req.UserID] = activity
+	}
+	activity.request = req
+	activity.requests++
+	activity.lastSeen = time.Now()
+}
+
+// Olvidar los n usuarios vistos hace más tiempo
+func (t *activityTracker) evictOldest(n int) {
+	userIDs := make([]int, 0, len(t.users))
+	for userID := range t.users {
+		userIDs = append(userIDs, userID)
+	}
+	sort.Slice(userIDs, func(i, j int) bool {
+		return t.users[userIDs[i]].lastSeen.Before(t.users[userIDs[j]].lastSeen)
+	})
+	for _, userID := range userIDs[:n] {
+		delete(t.users, userID)
+	}
+}
+
+// Solicitudes de los usuarios más frecuentes y más recientes; después
+// reduce los contadores a la mitad para que la frecuencia refleje el uso
+// reciente
+func (t *activityTracker) Candidates() []RecommendationAPIRequest {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	userIDs := make([]int, 0, len(t.users))
+	for userID := range t.users {
+		userIDs = append(userIDs, userID)
+	}
+	if len(userIDs) == 0 {
+		return nil
+	}
+	limit := int(float64(len(userIDs))*warmFraction + 0.5)
+	if limit < 1 {
+		limit = 1
+	}
+	if limit > warmMaxUsers {
+		limit = warmMaxUsers
+	}
+
+	selected := make(map[int]bool)
+	candidates := make([]RecommendationAPIRequest, 0, 2*limit)
+	pick := func(less func(a, b *userActivity) bool) {
+		sort.Slice(userIDs, func(i, j int) bool {
+			return less(t.users[userIDs[i]], t.users[userIDs[j]])
+		})
+		for _, userID := range userIDs[:limit] {
+			if !selected[userID] {
+				selected[userID] = true
+				candidates = append(candidates, t.users[userID].request)
+			}
+		}
+	}
+	pick(func(a, b *userActivity) bool {
+		if a.requests != b.requests {
+			return a.requests > b.requests
+		}
+		return a.lastSeen.After(b.lastSeen)
+	})
+	pick(func(a, b *userActivity) bool { return a.lastSeen.After(b.lastSeen) })
+
+	for _, activity := range t.users {
+		activity.requests /= 2
+	}
+	return candidates
+}
+
+// Solicitudes de recomendaciones en vivo, compartido por los datasets del
+// servidor (StartAPIServer)
+type liveTraffic struct {
+	requests int64 // en curso (atómico)
+	last     int64 // UnixNano de la última (atómico)
+}
+
+// Marcar el inicio y fin de una solicitud de recomendaciones en vivo
+func (api *APIServer) beginLiveRequest() {
+	atomic.AddInt64(&api.traffic.requests, 1)
+	atomic.StoreInt64(&api.traffic.last, time.Now().UnixNano())
+}
+
+func (api *APIServer) endLiveRequest() {
+	atomic.AddInt64(&api.traffic.requests, -1)
+	atomic.StoreInt64(&api.traffic.last, time.Now().UnixNano())
+}
+
+// Sin solicitudes en curso ni recientes en ningún dataset
+func (api *APIServer) idle() bool {
+	last := time.Unix(0, atomic.LoadInt64(&api.traffic.last))
+	return atomic.LoadInt64(&api.traffic.requests) == 0 && time.Since(last) >= warmIdleGap
+}
+
+// Precalentar la caché periódicamente (interval <= 0 lo desactiva)
+func (api *APIServer) StartCacheWarming(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			api.warmCache(interval)
+		}
+	}()
+}
+
+func (api *APIServer) warmCache(interval time.Duration) {
+	start := time.Now()
+	deadline := start.Add(interval / 2)
+	candidates := api.activity.Candidates()
+	cache := api.db.cache()
+
+	limiter := time.NewTicker(warmRate)
+	defer limiter.Stop()
+
+	warmed, failed := 0, 0
+	for _, req := range candidates {
+		key := recommendationCacheKey(req)
+		if cache.Remaining(key) > interval {
+			continue
+		}
+
+		// Solo en periodos inactivos; si el tráfico no baja, se deja para el próximo ciclo
+		for !api.idle() {
+			if time.Now().After(deadline) {
+				log.Printf("[API] Precalentamiento interrumpido por tráfico: %d usuarios listos", warmed)
+				return
+			}
+			time.Sleep(warmIdleGap)
+		}
+		<-limiter.C
+
+		_, _, _, err := api.inflight.Do(key, func() ([]RecommendationItem, int, error) {
+			generation := api.db.CacheGeneration(key.UserID)
+			recommendations, nodesUsed, err := api.computeRecommendations(req)
+			if err == nil && len(recommendations) > 0 {
+				api.db.CacheRecommendations(key, recommendations, generation)
+			}
+			return recommendations, nodesUsed, err
+		})
+		if err != nil {
+			failed++
+			continue
+		}
+		warmed++
+	}
+
+	if warmed > 0 || failed > 0 {
+		log.Printf("[API] Caché precalentada: %d de %d usuarios (%d errores) en %v",
+			warmed, len(candidates), failed, time.Since(start))
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CATÁLOGO EXTENDIDO - links.csv, tags.csv y genome de MovieLens
+// ============================================================================
+// Archivos opcionales junto a movies.csv. Si no existen se registra un aviso
+// y el catálogo queda solo con títulos y géneros.
+const (
+	movieTagsShown   = 20
+	genomeTagsShown  = 10
+	csvReadBufferKiB = 1024
+)
+
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+type GenomeScore struct {
+	TagID     int     `json:"tag_id"`
+	Tag       string  `json:"tag"`
+	Relevance float64 `json:"relevance"`
+}
+
+// Cargar los archivos opcionales del directorio del dataset. Se llama con db.mu tomado.
+func (db *Database) loadCatalogExtras(dir string) {
+	loaders := []struct {
+		file string
+		load func(path string) (int, error)
+	}{
+		{"links.csv", db.loadLinks},
+		{"tags.csv", db.loadTags},
+		{"genome-tags.csv", db.loadGenomeTags},
+		{"genome-scores.csv", db.loadGenomeScores},
+	}
+
+	for _, loader := range loaders {
+		path := filepath.Join(dir, loader.file)
+		count, err := loader.load(path)
+		if err != nil {
+			log.Printf("[DB] %s no cargado: %v", loader.file, err)
+			continue
+		}
+		log.Printf("[DB] %s cargado: %d filas", loader.file, count)
+	}
+}
+
+// Leer un CSV con encabezado, llamando a fn por cada fila
+func readCatalogCSV(path string, fn func(record []string)) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(bufio.NewReaderSize(file, csvReadBufferKiB*1024))
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	reader.Read()
+
+	count := 0
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			continue
+		}
+		fn(record)
+		count++
+	}
+	return count, nil
+}
+
+// links.csv: movieId,imdbId,tmdbId
+func (db *Database) loadLinks(path string) (int, error) {
+	return readCatalogCSV(path, func(record []string) {
+		if len(record) < 3 {
+			return
+		}
+		movie, exists := db.Movies[parseInt(record[0])]
+		if !exists {
+			return
+		}
+		if record[1] != "" {
+			movie.ImdbID = "tt" + record[1]
+		}
+		movie.TmdbID = parseInt(record[2])
+	})
+}
+
+// tags.csv: userId,movieId,tag,timestamp
+func (db *Database) loadTags(path string) (int, error) {
+	counts := make(map[int]map[string]int)
+	count, err := readCatalogCSV(path, func(record []string) {
+		if len(record) < 3 {
+			return
+		}
+		movieID := parseInt(record[1])
+		tag := strings.ToLower(strings.TrimSpace(record[2]))
+		if movieID == 0 || tag == "" {
+			return
+		}
+		if counts[movieID] == nil {
+			counts[movieID] = make(map[string]int)
+		}
+		counts[movieID][tag]++
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for movieID, tagCounts := range counts {
+		movie, exists := db.Movies[movieID]
+		if !exists {
+			continue
+		}
+		tags := make([]TagCount, 0, len(tagCounts))
+		for tag, c := range tagCounts {
+			tags = append(tags, TagCount{Tag: tag, Count: c})
+		}
+		sort.Slice(tags, func(i, j int) bool {
+			if tags[i].Count != tags[j].Count {
+				return tags[i].Count > tags[j].Count
+			}
+			return tags[i].Tag < tags[j].Tag
+		})
+		movie.Tags = tags
+	}
+	return count, nil
+}
+
+// genome-tags.csv: tagId,tag
+func (db *Database) loadGenomeTags(path string) (int, error) {
+	names := make([]string, 0, 1128)
+	count, err := readCatalogCSV(path, func(record []string) {
+		if len(record) < 2 {
+			return
+		}
+		tagID := parseInt(record[0])
+		if tagID <= 0 {
+			return
+		}
+		for len(names) < tagID {
+			names = append(names, "")
+		}
+		names[tagID-1] = record[1]
+	})
+	if err != nil {
+		return 0, err
+	}
+	db.GenomeTags = names
+	return count, nil
+}
+
+// genome-scores.csv: movieId,tagId,relevance. Requiere genome-tags.csv.
+func (db *Database) loadGenomeScores(path string) (int, error) {
+	numTags := len(db.GenomeTags)
+	if numTags == 0 {
+		return 0, os.ErrNotExist
+	}
+
+	return readCatalogCSV(path, func(record []string) {
+		if len(record) < 3 {
+			return
+		}
+		movie, exists := db.Movies[parseInt(record[0])]
+		tagID := parseInt(record[1])
+		if !exists || tagID <= 0 || tagID > numTags {
+			return
+		}
+		relevance, err := strconv.ParseFloat(record[2], 32)
+		if err != nil {
+			return
+		}
+		if movie.Genome == nil {
+			movie.Genome = make([]float32, numTags)
+		}
+		movie.Genome[tagID-1] = float32(relevance)
+	})
+}
+
+// Tags del genome con mayor relevancia para una película. Se llama con db.mu tomado.
+func (db *Database) topGenomeScores(movie *Movie, n int) []GenomeScore {
+	if len(movie.Genome) == 0 {
+		return nil
+	}
+
+	scores := make([]GenomeScore, 0, len(movie.Genome))
+	for i, relevance := range movie.Genome {
+		tag := ""
+		if i < len(db.GenomeTags) {
+			tag = db.GenomeTags[i]
+		}
+		scores = append(scores, GenomeScore{TagID: i + 1, Tag: tag, Relevance: float64(relevance)})
+	}
+	sort.Slice(scores, func(i, j int) bool {
+		return scores[i].Relevance > scores[j].Relevance
+	})
+
+	if len(scores) > n {
+		scores = scores[:n]
+	}
+	return scores
+}
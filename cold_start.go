@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+// COLD START - Usuarios nuevos o con pocos ratings
+// ============================================================================
+// Por debajo de este número de ratings las predicciones k-NN se mezclan con
+// la popularidad; con 0 ratings solo se usa popularidad.
+const coldStartThreshold = 20
+
+// ID usado para perfiles anónimos en las solicitudes a los workers
+const anonymousUserID = 0
+
+// Películas populares (promedio bayesiano) de un género que el usuario aún no ha visto
+func (dc *DistributedCoordinator) GetPopularRecommendations(genre string, seen map[int]float64, topN int) []RecommendationItem {
+	if dc.db == nil {
+		return []RecommendationItem{}
+	}
+
+	scores := make(map[int]float64)
+	for _, movie := range dc.db.GetPopularMovies(genre, 0, 0, topN+len(seen)) {
+		if _, rated := seen[movie.MovieID]; rated {
+			continue
+		}
+		scores[movie.MovieID] = movie.BayesianAvg
+		if len(scores) >= topN {
+			break
+		}
+	}
+
+	return dc.rankPredictions(scores, topN)
+}
+
+// Recomendaciones para un perfil anónimo (ratings enviados en la solicitud,
+// no se almacenan)
+func (dc *DistributedCoordinator) GetAnonymousRecommendations(ratings map[int]float64, genre string, topN int) ([]RecommendationItem, int, error) {
+	for movieID, rating := range ratings {
+		if !ratingInRange(rating) || !halfStar(rating) {
+			return nil, 0, requestError{fmt.Errorf("rating inválido para película %d: %v (se esperan medias estrellas entre 0.5 y 5)", movieID, rating)}
+		}
+	}
+
+	if len(ratings) == 0 {
+		return dc.GetPopularRecommendations(genre, nil, topN), 0, nil
+	}
+
+	sum := 0.0
+	for _, rating := range ratings {
+		sum += rating
+	}
+	avg := sum / float64(len(ratings))
+
+	// ID 0 no existe en MovieLens, así que los workers no lo excluyen
+	return dc.recommendForProfile(anonymousUserID, ratings, avg, topN)
+}
+
+// Mezclar k-NN con popularidad según cuántos ratings tiene el usuario
+func (dc *DistributedCoordinator) coldStartRecommendations(userID int, userRatings map[int]float64, userAvg float64, topN int) ([]RecommendationItem, int, error) {
+	if len(userRatings) == 0 {
+		log.Printf("[COORD] Usuario %d sin ratings: recomendaciones por popularidad", userID)
+		return dc.GetPopularRecommendations("", nil, topN), 0, nil
+	}
+
+	// Con menos de 3 ratings no hay vecinos posibles (mínimo de películas en común)
+	var predictions map[int]float64
+	activeWorkers := 0
+	if len(userRatings) >= 3 {
+		var similarUsers []SimilarityResult
+		similarUsers, activeWorkers = dc.findNeighbours(userID, userRatings, userAvg)
+		predictions = dc.predictScores(userRatings, userAvg, similarUsers)
+	}
+
+	alpha := float64(len(userRatings)) / float64(coldStartThreshold)
+	if alpha > 1 {
+		alpha = 1
+	}
+
+	// Candidatos: predicciones k-NN + populares no vistos
+	baseline := dc.baselineFor(userRatings, userAvg)
+	candidates := make(map[int]float64, len(predictions))
+	for movieID, score := range predictions {
+		candidates[movieID] = score
+	}
+	if dc.db != nil {
+		for _, movie := range dc.db.GetPopularMovies("", 0, 0, topN*5+len(userRatings)) {
+			if _, rated := userRatings[movie.MovieID]; rated {
+				continue
+			}
+			if _, exists := candidates[movie.MovieID]; !exists {
+				// Sin apoyo de vecinos la desviación es 0: se usa el predictor base
+				candidates[movie.MovieID] = baseline(movie.MovieID)
+			}
+		}
+	}
+
+	blended := make(map[int]float64, len(candidates))
+	for movieID, knnScore := range candidates {
+		popularScore := userAvg
+		if dc.db != nil {
+			if bayes, exists := dc.db.MovieStats.BayesianAverage(movieID); exists {
+				popularScore = bayes
+			}
+		}
+		blended[movieID] = alpha*knnScore + (1-alpha)*popularScore
+	}
+
+	log.Printf("[COORD] Cold-start usuario %d: %d ratings, peso k-NN %.2f", userID, len(userRatings), alpha)
+
+	return dc.rankPredictions(blended, topN), activeWorkers, nil
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+)
+
+// RECOMENDADOR POR CONTENIDO - Géneros y tag genome
+// ============================================================================
+// Cada película se representa con sus géneros (one-hot) y, si existe, su
+// vector de relevancia del genome centrado en la media de cada tag. El perfil
+// del usuario es la suma de los vectores de las películas que valoró por
+// encima de su promedio, ponderada por esa diferencia; si todos sus ratings
+// son iguales se compara con el punto medio de la escala. El score es el
+// coseno entre el perfil y cada película no vista (0-1).
+const (
+	genreFeatureWeight     = 1.0
+	genomeFeatureWeight    = 1.0
+	minNeighboursForKNN    = 5   // con menos vecinos fiables se completa con contenido
+	minNeighbourSimilarity = 0.1 // por debajo el vecino apenas informa la predicción
+	minContentProfileLen   = 1
+	neutralRating          = 2.75 // punto medio de la escala 0.5-5
+)
+
+type ContentModel struct {
+	genreIndex map[string]int
+	tagMeans   []float64
+}
+
+// Construir el modelo a partir del catálogo (géneros y medias del genome)
+func (dc *DistributedCoordinator) buildContentModel() {
+	if dc.db == nil {
+		return
+	}
+
+	dc.db.mu.RLock()
+	genreIndex := make(map[string]int)
+	numTags := len(dc.db.GenomeTags)
+	tagSums := make([]float64, numTags)
+	withGenome := 0
+
+	for _, movie := range dc.db.Movies {
+		for _, genre := range movie.Genres {
+			if _, exists := genreIndex[genre]; !exists {
+				genreIndex[genre] = len(genreIndex)
+			}
+		}
+		if len(movie.Genome) == numTags && numTags > 0 {
+			for i, relevance := range movie.Genome {
+				tagSums[i] += float64(relevance)
+			}
+			withGenome++
+		}
+	}
+	dc.db.mu.RUnlock()
+
+	tagMeans := make([]float64, numTags)
+	if withGenome > 0 {
+		for i := range tagSums {
+			tagMeans[i] = tagSums[i] / float64(withGenome)
+		}
+	}
+
+	dc.mu.Lock()
+	dc.content = &ContentModel{
+		genreIndex: genreIndex,
+		tagMeans:   tagMeans,
+	}
+	dc.mu.Unlock()
+
+	log.Printf("[COORD] Modelo de contenido: %d géneros, %d tags de genome (%d películas)",
+		len(genreIndex), numTags, withGenome)
+}
+
+// Sumar weight * features(movie) al vector dense
+func (m *ContentModel) addFeatures(dense []float64, movie *Movie, weight float64) {
+	for _, genre := range movie.Genres {
+		if idx, exists := m.genreIndex[genre]; exists {
+			dense[idx] += weight * genreFeatureWeight
+		}
+	}
+	if len(movie.Genome) == len(m.tagMeans) {
+		offset := len(m.genreIndex)
+		for i, relevance := range movie.Genome {
+			dense[offset+i] += weight * genomeFeatureWeight * (float64(relevance) - m.tagMeans[i])
+		}
+	}
+}
+
+// Producto punto entre el perfil y features(movie), y norma de features(movie)
+func (m *ContentModel) dot(profile []float64, movie *Movie) (float64, float64) {
+	dot := 0.0
+	normSq := 0.0
+	for _, genre := range movie.Genres {
+		if idx, exists := m.genreIndex[genre]; exists {
+			dot += profile[idx] * genreFeatureWeight
+			normSq += genreFeatureWeight * genreFeatureWeight
+		}
+	}
+	if len(movie.Genome) == len(m.tagMeans) {
+		offset := len(m.genreIndex)
+		for i, relevance := range movie.Genome {
+			value := genomeFeatureWeight * (float64(relevance) - m.tagMeans[i])
+			dot += profile[offset+i] * value
+			normSq += value * value
+		}
+	}
+	return dot, math.Sqrt(normSq)
+}
+
+// Suma de las películas valoradas por encima de reference, ponderadas por la
+// diferencia. Requiere dc.db.mu tomado.
+func (dc *DistributedCoordinator) contentProfile(model *ContentModel, userRatings map[int]float64, reference float64) ([]float64, int) {
+	profile := make([]float64, len(model.genreIndex)+len(model.tagMeans))
+	profileMovies := 0
+	for movieID, rating := range userRatings {
+		weight := rating - reference
+		if weight <= 0 {
+			continue
+		}
+		if movie, exists := dc.db.Movies[movieID]; exists {
+			model.addFeatures(profile, movie, weight)
+			profileMovies++
+		}
+	}
+	return profile, profileMovies
+}
+
+// Similitud de contenido de cada película no vista con el perfil del usuario
+func (dc *DistributedCoordinator) contentScores(userRatings map[int]float64, userAvg float64) map[int]float64 {
+	dc.mu.RLock()
+	model := dc.content
+	dc.mu.RUnlock()
+
+	scores := make(map[int]float64)
+	if model == nil || dc.db == nil {
+		return scores
+	}
+
+	dc.db.mu.RLock()
+	defer dc.db.mu.RUnlock()
+
+	profile, profileMovies := dc.contentProfile(model, userRatings, userAvg)
+	if profileMovies == 0 {
+		// Sin varianza nada supera el promedio: se usa el punto medio
+		profile, profileMovies = dc.contentProfile(model, userRatings, neutralRating)
+	}
+	if profileMovies < minContentProfileLen {
+		return scores
+	}
+
+	profileNorm := 0.0
+	for _, v := range profile {
+		profileNorm += v * v
+	}
+	profileNorm = math.Sqrt(profileNorm)
+	if profileNorm == 0 {
+		return scores
+	}
+
+	for movieID, movie := range dc.db.Movies {
+		if _, seen := userRatings[movieID]; seen {
+			continue
+		}
+		dot, norm := model.dot(profile, movie)
+		if norm == 0 || dot <= 0 {
+			continue
+		}
+		scores[movieID] = dot / (profileNorm * norm)
+	}
+
+	return scores
+}
+
+// Recomendaciones por contenido para un usuario registrado
+func (dc *DistributedCoordinator) GetContentRecommendations(userID int, topN int) ([]RecommendationItem, error) {
+	dc.localDataset.mu.RLock()
+	userRatings := dc.localDataset.UserRatingsMap[userID]
+	userAvg := dc.localDataset.UserAvgRatings[userID]
+	dc.localDataset.mu.RUnlock()
+
+	if len(userRatings) == 0 {
+		return nil, requestError{fmt.Errorf("usuario no encontrado")}
+	}
+
+	return dc.rankPredictions(dc.contentScores(userRatings, userAvg), topN), nil
+}
+
+// Vecinos con similitud suficiente para confiar en su predicción
+func reliableNeighbours(similarities []SimilarityResult) int {
+	count := 0
+	for _, sim := range similarities {
+		if sim.Similarity >= minNeighbourSimilarity {
+			count++
+		}
+	}
+	return count
+}
+
+// Mezclar k-NN con contenido cuando los vecinos son pocos o débiles: se
+// mantienen primero las mejores keep de k-NN, después contenido y, si falta,
+// el resto de k-NN. La similitud (0-1) se lleva a la escala de ratings para
+// que predicted_score sea comparable; source indica el origen.
+func (dc *DistributedCoordinator) fillWithContent(recommendations []RecommendationItem, keep int, userRatings map[int]float64, userAvg float64, topN int) []RecommendationItem {
+	dc.labelSource(recommendations, SourceKNN)
+	keep = min(keep, len(recommendations), topN)
+	result := append([]RecommendationItem(nil), recommendations[:keep]...)
+
+	included := make(map[int]bool, len(recommendations))
+	for _, rec := range result {
+		included[rec.MovieID] = true
+	}
+
+	content := dc.rankPredictions(dc.contentScores(userRatings, userAvg), topN+keep)
+	for _, rec := range content {
+		if len(result) >= topN {
+			break
+		}
+		if included[rec.MovieID] {
+			continue
+		}
+		rec.PredictedScore = contentRating(rec.PredictedScore)
+		rec.Source = SourceContent
+		result = append(result, rec)
+		included[rec.MovieID] = true
+	}
+
+	for _, rec := range recommendations[keep:] {
+		if len(result) >= topN {
+			break
+		}
+		if !included[rec.MovieID] {
+			result = append(result, rec)
+		}
+	}
+
+	return result
+}
+
+// Similitud de contenido (0-1) en la escala de ratings (0.5-5)
+func contentRating(similarity float64) float64 {
+	return 0.5 + 4.5*similarity
+}
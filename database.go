@@ -5,19 +5,40 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"slices"
+	"sort"
 	"sync"
 	"time"
 )
 
 // DATABASE - ETAPA 6
-// Base de datos en memoria con persistencia JSON
+// Base de datos en memoria; usuarios, catálogo, ratings y feedback en vivo y
+// caché se persisten en un Store (ver store.go)
 type Database struct {
-	Users               map[int]*User
 	Movies              map[int]*Movie
 	Ratings             map[int]map[int]float64 // userID -> movieID -> rating
-	RecommendationCache map[int][]RecommendationItem
+	RecommendationCache *RecommendationCache
+	Feedback            map[int]map[int]FeedbackEvent // userID -> movieID -> feedback
+	GenomeTags          []string                      // nombre de cada tag del genome, por tagId-1
+	MovieStats          *MovieStatsIndex
+	Trends              *MovieTrends
+	Search              *SearchIndex
 	mu                  sync.RWMutex
-	persistPath         string
+	usersMu             sync.Mutex        // serializa lectura-modificación-escritura de usuarios
+	accessed            map[int]time.Time // última consulta por usuario, pendiente de guardar en Save
+	accessMu            sync.Mutex
+	dataDir             string // directorio de db_snapshot.json y los snapshots
+	store               Store
+	saveMu              sync.Mutex   // serializa Save, snapshots y restauraciones
+	ratingsMu           sync.RWMutex // ratings en vivo contra restauraciones
+	snapshotDir         string
+	snapshotKeep        int
+	ratingsVersions     map[int]uint64 // versión de los ratings de cada usuario modificado (con mu)
+	ratingsBase         uint64         // versión de los demás usuarios, cambia al recargar
+	ratingsClock        uint64         // última versión asignada
+	userStats           map[int]userStatsEntry
+	statsMu             sync.Mutex
 }
 
 type User struct {
@@ -34,24 +55,32 @@ type Movie struct {
 	Genres        []string `json:"genres"`
 	RatingsCount  int      `json:"ratings_count"`
 	AverageRating float64  `json:"average_rating"`
+
+	// Catálogo extendido (links.csv, tags.csv, genome)
+	ImdbID     string        `json:"imdb_id,omitempty"`
+	TmdbID     int           `json:"tmdb_id,omitempty"`
+	Tags       []TagCount    `json:"tags,omitempty"`
+	Genome     []float32     `json:"-"` // relevancia por tagId-1
+	GenomeTags []GenomeScore `json:"genome_tags,omitempty"`
+
+	// Calculados desde MovieStatsIndex al consultar la película
+	BayesianAvg  float64            `json:"bayesian_average,omitempty"`
+	RatingStdDev float64            `json:"rating_stddev,omitempty"`
+	Histogram    map[string]int     `json:"histogram,omitempty"`
+	Percentiles  map[string]float64 `json:"percentiles,omitempty"`
 }
 
+// Formato del antiguo db_snapshot.json, solo para migrarlo
 type DatabaseSnapshot struct {
-	Users   map[int]*User                `json:"users"`
-	Movies  map[int]*Movie               `json:"movies"`
-	Cache   map[int][]RecommendationItem `json:"cache"`
-	Updated time.Time                    `json:"updated"`
+	Users    map[int]*User                 `json:"users"`
+	Movies   map[int]*Movie                `json:"movies"`
+	Feedback map[int]map[int]FeedbackEvent `json:"feedback,omitempty"`
+	Updated  time.Time                     `json:"updated"`
 }
 
-// Crear nueva base de datos
-func NewDatabase(persistPath string) *Database {
-	db := &Database{
-		Users:               make(map[int]*User),
-		Movies:              make(map[int]*Movie),
-		Ratings:             make(map[int]map[int]float64),
-		RecommendationCache: make(map[int][]RecommendationItem),
-		persistPath:         persistPath,
-	}
+// Crear nueva base de datos sobre un almacenamiento
+func NewDatabase(store Store, dataDir string) *Database {
+	db := newDatabase(store, dataDir)
 
 	// Cargar datos si existen
 	if err := db.Load(); err != nil {
@@ -61,12 +90,43 @@ func NewDatabase(persistPath string) *Database {
 	return db
 }
 
+// Base de datos vacía, sin cargar el almacenamiento
+func newDatabase(store Store, dataDir string) *Database {
+	db := &Database{
+		Movies:              make(map[int]*Movie),
+		Ratings:             make(map[int]map[int]float64),
+		RecommendationCache: NewRecommendationCache(defaultCacheCapacity, defaultCacheTTL),
+		Feedback:            make(map[int]map[int]FeedbackEvent),
+		accessed:            make(map[int]time.Time),
+		ratingsVersions:     make(map[int]uint64),
+		userStats:           make(map[int]userStatsEntry),
+		MovieStats:          NewMovieStatsIndex(),
+		Search:              NewSearchIndex(),
+		dataDir:             dataDir,
+		store:               store,
+		snapshotDir:         filepath.Join(dataDir, "snapshots"),
+		snapshotKeep:        defaultSnapshotKeep,
+	}
+	db.Trends = NewMovieTrends(db.MovieStats)
+	return db
+}
+
 // Cargar películas del CSV
-func (db *Database) LoadMovies(filepath string) error {
+func (db *Database) LoadMovies(path string) error {
+	if err := db.loadMovies(path); err != nil {
+		return err
+	}
+
+	// El catálogo de los CSV reemplaza al guardado
+	db.persistCatalog()
+	return nil
+}
+
+func (db *Database) loadMovies(path string) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
-	file, err := os.Open(filepath)
+	file, err := os.Open(path)
 	if err != nil {
 		return err
 	}
@@ -104,155 +164,318 @@ func (db *Database) LoadMovies(filepath string) error {
 	}
 
 	log.Printf("[DB] Películas cargadas: %d", count)
+
+	// Links, tags y genome del mismo directorio (opcionales)
+	db.loadCatalogExtras(filepath.Dir(path))
+
+	// Índice de búsqueda por título
+	db.Search.Build(db.Movies)
 	return nil
 }
 
-// Agregar o actualizar usuario
-func (db *Database) UpsertUser(userID int, ratingsCount int, avgRating float64) {
-	db.mu.Lock()
-	defer db.mu.Unlock()
+// Guardar en el almacenamiento las películas nuevas o distintas de las
+// guardadas. Solo la copia de la lista se hace con db.mu: las películas no
+// cambian una vez cargadas y la escritura (lenta con SQL) no bloquea a los
+// lectores ni a AddRating.
+func (db *Database) persistCatalog() {
+	db.mu.RLock()
+	movies := make([]*Movie, 0, len(db.Movies))
+	for _, movie := range db.Movies {
+		movies = append(movies, movie)
+	}
+	genomeTags := db.GenomeTags
+	db.mu.RUnlock()
 
-	if user, exists := db.Users[userID]; exists {
-		user.RatingsCount = ratingsCount
-		user.AverageRating = avgRating
-		user.LastAccessed = time.Now()
-	} else {
-		db.Users[userID] = &User{
-			UserID:        userID,
-			RatingsCount:  ratingsCount,
-			AverageRating: avgRating,
-			LastAccessed:  time.Now(),
+	stored, err := db.store.Movies()
+	if err != nil {
+		log.Printf("[DB] Error leyendo el catálogo de %s: %v", db.store, err)
+		return
+	}
+	storedByID := make(map[int]*Movie, len(stored))
+	for _, movie := range stored {
+		storedByID[movie.MovieID] = movie
+	}
+	changed := make([]*Movie, 0)
+	for _, movie := range movies {
+		if previous := storedByID[movie.MovieID]; previous == nil || !sameCatalogEntry(previous, movie) {
+			changed = append(changed, movie)
+		}
+	}
+	if len(changed) > 0 {
+		if err := db.store.UpsertMovies(changed); err != nil {
+			log.Printf("[DB] Error guardando el catálogo en %s: %v", db.store, err)
+		} else {
+			log.Printf("[DB] Catálogo: %d películas nuevas o modificadas guardadas", len(changed))
+		}
+	}
+
+	if len(genomeTags) > 0 {
+		previous, err := db.store.GenomeTags()
+		if err == nil && slices.Equal(previous, genomeTags) {
+			return
+		}
+		if err := db.store.PutGenomeTags(genomeTags); err != nil {
+			log.Printf("[DB] Error guardando los tags del genome en %s: %v", db.store, err)
 		}
 	}
 }
 
-// Obtener usuario
-func (db *Database) GetUser(userID int) (*User, error) {
+// Campos del catálogo que guarda el almacenamiento (las estadísticas se
+// calculan al consultar). nil y vacío son iguales: SQL no los distingue.
+func sameCatalogEntry(a, b *Movie) bool {
+	return a.Title == b.Title && a.ImdbID == b.ImdbID && a.TmdbID == b.TmdbID &&
+		slices.Equal(a.Genres, b.Genres) && slices.Equal(a.Tags, b.Tags) && slices.Equal(a.Genome, b.Genome)
+}
+
+// Registrar un rating (carga inicial o ingesta en vivo, live). previousTimestamp
+// es el del rating que se reemplaza, si el usuario ya había valorado la película.
+func (db *Database) AddRating(userID int, movieID int, rating float64, timestamp, previousTimestamp int64, live bool) {
 	db.mu.Lock()
-	defer db.mu.Unlock()
+	if db.Ratings[userID] == nil {
+		db.Ratings[userID] = make(map[int]float64)
+	}
+	previous, rerated := db.Ratings[userID][movieID]
+	db.Ratings[userID][movieID] = rating
+	db.ratingsClock++
+	db.ratingsVersions[userID] = db.ratingsClock
+	db.mu.Unlock()
+
+	if rerated {
+		db.MovieStats.Replace(movieID, previous, rating)
+		db.Trends.Replace(movieID, previous, previousTimestamp, rating, timestamp, live)
+	} else {
+		db.MovieStats.Record(movieID, rating)
+		db.Trends.Record(movieID, rating, timestamp, live)
+	}
+	if live {
+		db.refreshUser(userID)
+	}
+}
 
-	// Si el usuario ya existe en caché, devolverlo
-	user, exists := db.Users[userID]
-	if exists {
-		user.LastAccessed = time.Now()
-		return user, nil
+// Películas en tendencia, opcionalmente filtradas por género
+func (db *Database) GetTrendingMovies(genre string, windowDays int, limit int) []TrendingMovie {
+	movies := db.Trends.Trending(windowDays, db.genreFilter(genre), limit)
+	db.describeTrending(movies)
+	return movies
+}
+
+// Películas mejor valoradas, opcionalmente filtradas por género
+func (db *Database) GetPopularMovies(genre string, windowDays int, minRatings int, limit int) []TrendingMovie {
+	movies := db.Trends.Popular(windowDays, minRatings, db.genreFilter(genre), limit)
+	db.describeTrending(movies)
+	return movies
+}
+
+func (db *Database) genreFilter(genre string) func(movieID int) bool {
+	if genre == "" {
+		return nil
 	}
+	return func(movieID int) bool {
+		db.mu.RLock()
+		defer db.mu.RUnlock()
+		movie, exists := db.Movies[movieID]
+		if !exists {
+			return false
+		}
+		for _, g := range movie.Genres {
+			if g == genre {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func (db *Database) describeTrending(movies []TrendingMovie) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	for i := range movies {
+		if movie, exists := db.Movies[movies[i].MovieID]; exists {
+			movies[i].Title = movie.Title
+			movies[i].Genres = movie.Genres
+		}
+	}
+}
+
+// Buscar películas por título, ordenadas por relevancia × popularidad
+func (db *Database) SearchMovies(query string, limit int) []SearchResult {
+	results := db.Search.Search(query, limit, func(movieID int) int {
+		stats, _ := db.MovieStats.Get(movieID)
+		return stats.Count
+	})
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	for i := range results {
+		if movie, exists := db.Movies[results[i].MovieID]; exists {
+			results[i].Genres = movie.Genres
+		}
+	}
+	return results
+}
 
+// Obtener usuario. Leer un usuario existente no escribe en el
+// almacenamiento: la última consulta se guarda en lote en Save.
+func (db *Database) GetUser(userID int) (*User, error) {
+	user, err := db.store.GetUser(userID)
+	if err == errStoreNotFound {
+		// Crearlo una sola vez aunque lleguen varias consultas a la vez
+		db.usersMu.Lock()
+		defer db.usersMu.Unlock()
+		user, err = db.store.GetUser(userID)
+		if err == errStoreNotFound {
+			return db.createUser(userID)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	db.mu.RLock()
+	db.touchUser(user)
+	db.mu.RUnlock()
+	db.accessMu.Lock()
+	db.accessed[userID] = user.LastAccessed
+	db.accessMu.Unlock()
+	return user, nil
+}
+
+// Crear un usuario a partir de sus ratings. Se llama con usersMu tomado.
+func (db *Database) createUser(userID int) (*User, error) {
 	// Si no existe, buscar en Ratings y crearlo
+	db.mu.RLock()
 	userRatings, hasRatings := db.Ratings[userID]
 	if !hasRatings || len(userRatings) == 0 {
+		db.mu.RUnlock()
 		return nil, fmt.Errorf("usuario no encontrado")
 	}
 
 	// Crear usuario con estadísticas calculadas
-	sum := 0.0
-	count := 0
-	for _, rating := range userRatings {
-		sum += rating
-		count++
-	}
+	newUser := &User{UserID: userID, LastAccessed: time.Now()}
+	db.setUserStats(newUser, userRatings)
+	db.mu.RUnlock()
 
-	newUser := &User{
-		UserID:        userID,
-		RatingsCount:  count,
-		AverageRating: sum / float64(count),
-		TopGenres:     []string{},
-		LastAccessed:  time.Now(),
-	}
-
-	db.Users[userID] = newUser
+	db.storeUser(newUser)
 	return newUser, nil
 }
 
-// Obtener película
-func (db *Database) GetMovie(movieID int) (*Movie, error) {
-	db.mu.Lock()
-	defer db.mu.Unlock()
+// Recalcular y guardar el perfil de un usuario existente tras un rating en
+// vivo. Si aún no existe, se crea con sus ratings al consultarlo.
+func (db *Database) refreshUser(userID int) {
+	db.usersMu.Lock()
+	defer db.usersMu.Unlock()
 
-	movie, exists := db.Movies[movieID]
-	if !exists {
-		return nil, fmt.Errorf("película no encontrada")
+	user, err := db.store.GetUser(userID)
+	if err != nil {
+		if err != errStoreNotFound {
+			log.Printf("[DB] Error leyendo usuario %d: %v", userID, err)
+		}
+		return
+	}
+	db.mu.RLock()
+	db.setUserStats(user, db.Ratings[userID])
+	db.mu.RUnlock()
+	db.storeUser(user)
+}
+
+// Un error al guardar no impide responder con el usuario
+func (db *Database) storeUser(user *User) {
+	if err := db.store.UpsertUser(user); err != nil {
+		log.Printf("[DB] Error guardando usuario %d: %v", user.UserID, err)
 	}
+}
 
-	// Calcular estadísticas de ratings si aún no están calculadas
-	if movie.RatingsCount == 0 {
-		sum := 0.0
-		count := 0
+// Obtener película con sus estadísticas de ratings
+func (db *Database) GetMovie(movieID int) (*Movie, error) {
+	var movie Movie
+	db.mu.RLock()
+	stored, exists := db.Movies[movieID]
+	if exists {
+		movie = *stored
+		if len(movie.Tags) > movieTagsShown {
+			movie.Tags = movie.Tags[:movieTagsShown]
+		}
+		movie.GenomeTags = db.topGenomeScores(stored, genomeTagsShown)
+	}
+	db.mu.RUnlock()
 
-		// Recorrer todos los usuarios para encontrar ratings de esta película
-		for _, userRatings := range db.Ratings {
-			if rating, exists := userRatings[movieID]; exists {
-				sum += rating
-				count++
-			}
+	// Películas agregadas al almacenamiento después de cargar el catálogo
+	if !exists {
+		fromStore, err := db.store.GetMovie(movieID)
+		if err != nil {
+			return nil, fmt.Errorf("película no encontrada")
 		}
+		movie = *fromStore
+	}
 
-		if count > 0 {
-			movie.RatingsCount = count
-			movie.AverageRating = sum / float64(count)
+	// Las estadísticas salen del índice, sin recorrer los ratings
+	if stats, hasStats := db.MovieStats.Get(movieID); hasStats && stats.Count > 0 {
+		movie.RatingsCount = stats.Count
+		movie.AverageRating = stats.Average()
+		movie.BayesianAvg = bayesianAverage(stats.Sum, stats.Count, db.MovieStats.GlobalAverage())
+		movie.RatingStdDev = stats.StdDev()
+		movie.Histogram = stats.HistogramMap()
+		movie.Percentiles = map[string]float64{
+			"p25": stats.Percentile(25),
+			"p50": stats.Percentile(50),
+			"p75": stats.Percentile(75),
+			"p90": stats.Percentile(90),
 		}
 	}
 
-	return movie, nil
+	return &movie, nil
 }
 
-// Cachear recomendaciones
-func (db *Database) CacheRecommendations(userID int, recommendations []RecommendationItem) {
-	db.mu.Lock()
-	defer db.mu.Unlock()
+func (db *Database) cache() *RecommendationCache {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.RecommendationCache
+}
 
-	db.RecommendationCache[userID] = recommendations
-	log.Printf("[DB] Recomendaciones cacheadas para usuario %d", userID)
+// Generación de la caché del usuario, a leer antes de calcular sus recomendaciones
+func (db *Database) CacheGeneration(userID int) uint64 {
+	return db.cache().Generation(userID)
+}
 
-	// Persistir asíncronamente cada 100 inserciones
-	if len(db.RecommendationCache)%100 == 0 {
-		go db.Save()
+// Cachear recomendaciones, salvo si el usuario se invalidó después de leer
+// generation (un rating o feedback llegó mientras se calculaban)
+func (db *Database) CacheRecommendations(key CacheKey, recommendations []RecommendationItem, generation uint64) {
+	if !db.cache().SetIfGeneration(key, recommendations, generation) {
+		log.Printf("[DB] Recomendaciones no cacheadas, usuario invalidado durante el cálculo: %s", key)
+		return
 	}
+	log.Printf("[DB] Recomendaciones cacheadas: %s", key)
 }
 
 // Obtener recomendaciones cacheadas
-func (db *Database) GetCachedRecommendations(userID int, topN int) ([]RecommendationItem, error) {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
-
-	recs, exists := db.RecommendationCache[userID]
+func (db *Database) GetCachedRecommendations(key CacheKey) ([]RecommendationItem, error) {
+	recs, exists := db.cache().Get(key)
 	if !exists {
 		return nil, fmt.Errorf("no cache found")
 	}
-
-	// Retornar solo topN
-	if len(recs) > topN {
-		return recs[:topN], nil
-	}
-
 	return recs, nil
 }
 
-// Limpiar caché antiguo (más de 1 hora)
-func (db *Database) CleanOldCache() {
-	db.mu.Lock()
-	defer db.mu.Unlock()
-
-	cutoff := time.Now().Add(-1 * time.Hour)
-	cleaned := 0
-
-	for userID, user := range db.Users {
-		if user.LastAccessed.Before(cutoff) {
-			delete(db.RecommendationCache, userID)
-			cleaned++
-		}
-	}
+// Descartar las recomendaciones cacheadas de un usuario
+func (db *Database) InvalidateRecommendations(userID int) {
+	db.cache().InvalidateUser(userID)
+}
 
-	if cleaned > 0 {
+// Limpiar entradas vencidas de la caché
+func (db *Database) CleanOldCache() {
+	if cleaned := db.cache().RemoveExpired(); cleaned > 0 {
 		log.Printf("[DB] Cache limpiado: %d entradas eliminadas", cleaned)
 	}
 }
 
 // Obtener estadísticas
 func (db *Database) GetUserCount() int {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
-	return len(db.Users)
+	count, err := db.store.UserCount()
+	if err != nil {
+		log.Printf("[DB] Error contando usuarios: %v", err)
+	}
+	return count
 }
 
 func (db *Database) GetMovieCount() int {
@@ -262,42 +485,168 @@ func (db *Database) GetMovieCount() int {
 }
 
 func (db *Database) GetCacheSize() int {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
-	return len(db.RecommendationCache)
+	return db.cache().Len()
 }
 
-// Persistir a disco
+// PERSISTENCIA
+// Usuarios, catálogo, ratings y feedback se escriben al recibirlos; la caché
+// y la última consulta de cada usuario, en Save.
+const legacySnapshotPath = "db_snapshot.json"
+
+type StoredRating struct {
+	UserID    int     `json:"user_id"`
+	MovieID   int     `json:"movie_id"`
+	Rating    float64 `json:"rating"`
+	Timestamp int64   `json:"timestamp"`
+}
+
+type StoredFeedback struct {
+	UserID int `json:"user_id"`
+	FeedbackEvent
+}
+
+// Persistir lo pendiente y reemplazar la caché guardada.
+// Los guardados y snapshots se serializan.
 func (db *Database) Save() error {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
+	db.saveMu.Lock()
+	defer db.saveMu.Unlock()
+	return db.saveLocked()
+}
+
+func (db *Database) saveLocked() error {
+	if err := db.flushAccessed(); err != nil {
+		return err
+	}
+	if err := db.store.Save(db.cache().Snapshot()); err != nil {
+		return err
+	}
+
+	log.Printf("[DB] Base de datos guardada en %s", db.store)
+	return nil
+}
 
-	snapshot := DatabaseSnapshot{
-		Users:   db.Users,
-		Movies:  db.Movies,
-		Cache:   db.RecommendationCache,
-		Updated: time.Now(),
+// Guardar en un lote la última consulta de los usuarios leídos desde el
+// último Save; si falla, quedan pendientes para el siguiente
+func (db *Database) flushAccessed() error {
+	db.accessMu.Lock()
+	accessed := db.accessed
+	db.accessed = make(map[int]time.Time)
+	db.accessMu.Unlock()
+	if len(accessed) == 0 {
+		return nil
 	}
 
-	file, err := os.Create(db.persistPath)
+	if err := db.store.TouchUsers(accessed); err != nil {
+		db.accessMu.Lock()
+		for userID, at := range accessed {
+			if newer, exists := db.accessed[userID]; !exists || at.After(newer) {
+				db.accessed[userID] = at
+			}
+		}
+		db.accessMu.Unlock()
+		return fmt.Errorf("error guardando accesos de usuarios: %v", err)
+	}
+	return nil
+}
+
+// Guardar un rating recibido en vivo antes de aplicarlo
+func (db *Database) PersistRating(userID, movieID int, rating float64, timestamp int64) error {
+	db.ratingsMu.RLock()
+	defer db.ratingsMu.RUnlock()
+	return db.store.PutRating(StoredRating{userID, movieID, rating, timestamp})
+}
+
+// Ratings recibidos en vivo, para volver a aplicarlos al iniciar
+func (db *Database) StoredRatings() ([]StoredRating, error) {
+	return db.store.Ratings()
+}
+
+// Cargar desde el almacenamiento; si está vacío se migra el snapshot JSON
+func (db *Database) Load() error {
+	users, err := db.store.UserCount()
 	if err != nil {
 		return err
 	}
-	defer file.Close()
+	movies, err := db.store.MovieCount()
+	if err != nil {
+		return err
+	}
+	if users == 0 && movies == 0 {
+		legacyPath := filepath.Join(db.dataDir, legacySnapshotPath)
+		if err := db.migrateSnapshot(legacyPath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error migrando %s: %v", legacyPath, err)
+		}
+	}
+	return db.loadState()
+}
 
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	if err := encoder.Encode(snapshot); err != nil {
+// Reemplazar feedback y caché por los del almacenamiento. Las películas solo
+// se agregan si faltan: el catálogo cargado de los CSV (con genome) tiene
+// prioridad. Los usuarios se leen del almacenamiento al consultarlos.
+func (db *Database) loadState() error {
+	movies, err := db.store.Movies()
+	if err != nil {
+		return err
+	}
+	events, err := db.store.Feedback()
+	if err != nil {
+		return err
+	}
+	cached, err := db.store.LoadCache()
+	if err != nil {
 		return err
 	}
+	users, err := db.store.UserCount()
+	if err != nil {
+		return err
+	}
+	genomeTags, err := db.store.GenomeTags()
+	if err != nil {
+		return err
+	}
+
+	feedback := make(map[int]map[int]FeedbackEvent)
+	for _, event := range events {
+		if feedback[event.UserID] == nil {
+			feedback[event.UserID] = make(map[int]FeedbackEvent)
+		}
+		feedback[event.UserID][event.MovieID] = event.FeedbackEvent
+	}
+
+	// El orden LRU guardado no se conserva: primero las más recientes
+	sort.Slice(cached, func(i, j int) bool {
+		return cached[i].CreatedAt.After(cached[j].CreatedAt)
+	})
+
+	db.mu.Lock()
+	for _, movie := range movies {
+		if _, exists := db.Movies[movie.MovieID]; !exists {
+			db.Movies[movie.MovieID] = movie
+		}
+	}
+	if len(db.GenomeTags) == 0 {
+		db.GenomeTags = genomeTags
+	}
+	db.Feedback = feedback
+	cache := db.RecommendationCache
+	db.mu.Unlock()
+	restored := cache.Restore(cached)
+
+	log.Printf("[DB] Base de datos cargada desde %s", db.store)
+	log.Printf("[DB] Usuarios: %d, Películas: %d, Feedback: %d usuarios, Caché: %d entradas",
+		users, len(movies), len(feedback), restored)
 
-	log.Printf("[DB] Base de datos guardada en %s", db.persistPath)
 	return nil
 }
 
-// Cargar desde disco
-func (db *Database) Load() error {
-	file, err := os.Open(db.persistPath)
+// Cerrar el almacenamiento
+func (db *Database) Close() error {
+	return db.store.Close()
+}
+
+// Importar el snapshot JSON anterior al almacenamiento
+func (db *Database) migrateSnapshot(path string) error {
+	file, err := os.Open(path)
 	if err != nil {
 		return err
 	}
@@ -308,17 +657,32 @@ func (db *Database) Load() error {
 		return err
 	}
 
-	db.mu.Lock()
-	defer db.mu.Unlock()
-
-	db.Users = snapshot.Users
-	db.Movies = snapshot.Movies
-	db.RecommendationCache = snapshot.Cache
-
-	log.Printf("[DB] Base de datos cargada desde %s", db.persistPath)
-	log.Printf("[DB] Usuarios: %d, Películas: %d, Cache: %d",
-		len(db.Users), len(db.Movies), len(db.RecommendationCache))
-
+	users := make([]*User, 0, len(snapshot.Users))
+	for userID, user := range snapshot.Users {
+		user.UserID = userID
+		users = append(users, user)
+	}
+	if err := db.store.UpsertUsers(users); err != nil {
+		return err
+	}
+	movies := make([]*Movie, 0, len(snapshot.Movies))
+	for movieID, movie := range snapshot.Movies {
+		movie.MovieID = movieID
+		movies = append(movies, movie)
+	}
+	if err := db.store.UpsertMovies(movies); err != nil {
+		return err
+	}
+	for userID, events := range snapshot.Feedback {
+		for movieID, event := range events {
+			event.MovieID = movieID
+			if err := db.store.PutFeedback(StoredFeedback{userID, event}); err != nil {
+				return err
+			}
+		}
+	}
+	log.Printf("[DB] Migrado %s: %d usuarios, %d películas, feedback de %d usuarios",
+		path, len(snapshot.Users), len(snapshot.Movies), len(snapshot.Feedback))
 	return nil
 }
 
@@ -397,6 +761,25 @@ func parseCSVLine(line string) []string {
 	return parts
 }
 
+// Año de estreno al final del título, p. ej. "Matrix, The (1999)" -> 1999
+func movieYear(title string) int {
+	end := len(title)
+	for end > 0 && title[end-1] == ' ' {
+		end--
+	}
+	if end < 6 || title[end-1] != ')' || title[end-6] != '(' {
+		return 0
+	}
+	year := 0
+	for _, c := range title[end-5 : end-1] {
+		if c < '0' || c > '9' {
+			return 0
+		}
+		year = year*10 + int(c-'0')
+	}
+	return year
+}
+
 func parseInt(s string) int {
 	result := 0
 	for _, c := range s {
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DATASETS - Varios catálogos servidos por un mismo clúster
+// ============================================================================
+// Cada dataset con nombre tiene su propio coordinador, base de datos, caché,
+// métricas y modelos; los workers guardan una partición de cada uno (flags
+// --dataset y --datasets del worker) y eligen por el campo "dataset" de la
+// solicitud, que siempre lleva el nombre: no hay dataset implícito. El
+// primero de -datasets es el por defecto: conserva las rutas /api/... y el
+// almacenamiento de -db/-store-dsn. Los demás se sirven bajo
+// /datasets/<nombre>/api/..., con ?dataset=<nombre> o con "dataset" en el
+// cuerpo JSON, y guardan sus datos en un subdirectorio (o esquema) propio.
+const defaultDatasets = "ml-25m=data_25M"
+
+// Postgres trunca los identificadores a 63 bytes: el nombre más "dataset_"
+var datasetNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,54}$`)
+
+type DatasetConfig struct {
+	Name string `json:"name"`
+	Dir  string `json:"dir"`
+}
+
+// Leer -datasets: nombre=directorio separados por comas
+func ParseDatasets(spec string) ([]DatasetConfig, error) {
+	datasets := make([]DatasetConfig, 0)
+	seen := make(map[string]string) // esquema -> nombre
+	for _, entry := range strings.Split(spec, ",") {
+		parts := strings.SplitN(strings.TrimSpace(entry), "=", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			return nil, fmt.Errorf("dataset inválido: %q (nombre=directorio)", entry)
+		}
+		name := parts[0]
+		if !datasetNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("nombre de dataset inválido: %q (minúsculas, dígitos, - y _, hasta 55 caracteres)", name)
+		}
+		// Dos nombres con el mismo esquema compartirían usuarios y ratings
+		schema := datasetSchema(name)
+		if other, exists := seen[schema]; exists {
+			if other == name {
+				return nil, fmt.Errorf("dataset repetido: %s", name)
+			}
+			return nil, fmt.Errorf("los datasets %s y %s usarían el mismo esquema %s", other, name, schema)
+		}
+		seen[schema] = name
+		datasets = append(datasets, DatasetConfig{Name: name, Dir: parts[1]})
+	}
+	return datasets, nil
+}
+
+// Opciones comunes a todos los datasets
+type TenantOptions struct {
+	Store         StoreConfig
+	SnapshotDir   string
+	SnapshotKeep  int
+	CacheSize     int
+	CacheTTL      time.Duration
+	Workers       []string
+	HybridBlend   string
+	HybridWeights map[string]float64
+	LoadMode      string // lenient o strict
+	AdminToken    string // vacío: /api/admin/* solo desde localhost
+	DataRoot      string // raíz de los directorios de recarga
+}
+
+// Un dataset servido por el clúster
+type Tenant struct {
+	DatasetConfig
+	Default     bool
+	coordinator *DistributedCoordinator
+	db          *Database
+	metrics     *SystemMetrics
+	api         *APIServer
+	handler     http.Handler
+}
+
+// Abrir el almacenamiento y cargar catálogo y ratings de un dataset
+func OpenTenant(cfg DatasetConfig, isDefault bool, opts TenantOptions) (*Tenant, error) {
+	storeCfg := opts.Store
+	snapshotDir := opts.SnapshotDir
+	if !isDefault {
+		var err error
+		if storeCfg, err = storeCfg.ForDataset(cfg.Name); err != nil {
+			return nil, err
+		}
+		snapshotDir = filepath.Join(snapshotDir, cfg.Name)
+	}
+
+	log.Printf("[COORD] Dataset %s: %s", cfg.Name, cfg.Dir)
+	store, err := OpenStore(storeCfg)
+	if err != nil {
+		if storeCfg.Kind != StoreLog {
+			return nil, fmt.Errorf("no se pudo abrir el almacenamiento %s: %v", storeCfg.Kind, err)
+		}
+		log.Printf("[DB] ⚠️  No se pudo abrir %s, los datos no se persistirán: %v", storeCfg.Path, err)
+		store = NewMemoryStore()
+	}
+	db := NewDatabase(store, filepath.Dir(storeCfg.Path))
+	db.ConfigureCache(opts.CacheSize, opts.CacheTTL)
+	db.ConfigureSnapshots(snapshotDir, opts.SnapshotKeep)
+	if err := db.LoadMovies(filepath.Join(cfg.Dir, "movies.csv")); err != nil {
+		log.Printf("[WARN] No se pudieron cargar películas de %s: %v", cfg.Name, err)
+	}
+
+	metrics := NewSystemMetrics()
+
+	// Cada worker tiene la partición i+1 del dataset
+	partitions := make([]string, len(opts.Workers))
+	for i := range opts.Workers {
+		partitions[i] = filepath.Join(cfg.Dir, fmt.Sprintf("ratings_part%d.csv", i+1))
+	}
+	coordinator := NewDistributedCoordinator(opts.Workers, partitions, 8)
+	coordinator.db = db
+	coordinator.metrics = metrics
+	coordinator.dataset = cfg.Name
+	coordinator.loadMode = opts.LoadMode
+	coordinator.dataRoot = opts.DataRoot
+	coordinator.hybrid.Blend = opts.HybridBlend
+	if opts.HybridWeights != nil {
+		coordinator.hybrid.Weights = opts.HybridWeights
+	}
+
+	if err := coordinator.LoadLocalData(filepath.Join(cfg.Dir, "ratings.csv"), filepath.Join(cfg.Dir, "movies.csv")); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("no se pudieron cargar los datos de %s: %v", cfg.Name, err)
+	}
+	coordinator.InitDatasetVersion(cfg.Dir)
+
+	// Ratings recibidos por la API en ejecuciones anteriores
+	if err := coordinator.ReplayStoredRatings(); err != nil {
+		log.Printf("[WARN] No se pudieron restaurar los ratings en vivo de %s: %v", cfg.Name, err)
+	}
+
+	api := NewAPIServer(coordinator, db, metrics)
+	api.adminToken = opts.AdminToken
+	return &Tenant{
+		DatasetConfig: cfg,
+		Default:       isDefault,
+		coordinator:   coordinator,
+		db:            db,
+		metrics:       metrics,
+		api:           api,
+		handler:       api.routes(),
+	}, nil
+}
+
+// Tareas de fondo del modo servidor
+func (t *Tenant) Start(snapshotInterval time.Duration) {
+	t.db.StartCleanupTask()
+	t.db.StartSnapshotTask(snapshotInterval)
+	t.metrics.StartMonitoring()
+
+	// Feedback implícito y Slope One (disponibles al terminar)
+	t.coordinator.StartBPRTraining()
+	t.coordinator.StartSlopeOneBuild()
+}
+
+// Guardar y cerrar la base de datos
+func (t *Tenant) Close() {
+	if err := t.db.Save(); err != nil {
+		log.Printf("[WARN] No se pudo guardar la base de datos de %s: %v", t.Name, err)
+	}
+	t.db.Close()
+}
+
+// Resumen de un dataset para GET /api/datasets
+type DatasetInfo struct {
+	DatasetConfig
+	Default bool           `json:"default"`
+	Prefix  string         `json:"prefix"`
+	Current DatasetVersion `json:"current"`
+	Reload  string         `json:"reload_state"`
+}
+
+// Enrutador: elige el dataset y pasa la solicitud a su API
+type datasetRouter struct {
+	tenants  []*Tenant
+	byName   map[string]*Tenant
+	fallback *Tenant
+}
+
+func newDatasetRouter(tenants []*Tenant) *datasetRouter {
+	router := &datasetRouter{tenants: tenants, byName: make(map[string]*Tenant)}
+	for _, tenant := range tenants {
+		router.byName[tenant.Name] = tenant
+		if tenant.Default {
+			router.fallback = tenant
+		}
+	}
+	return router
+}
+
+func (rt *datasetRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Un único límite para bodyDataset y los handlers
+	if r.Body != nil {
+		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBody)
+	}
+
+	path := r.URL.Path
+	switch {
+	case path == "/":
+		rt.handleRoot(w, r)
+	case path == "/api/datasets":
+		loggingMiddleware(enableCORS(rt.handleDatasets))(w, r)
+	case strings.HasPrefix(path, "/datasets/"):
+		name := strings.SplitN(strings.TrimPrefix(path, "/datasets/"), "/", 2)[0]
+		tenant, exists := rt.byName[name]
+		if !exists {
+			http.Error(w, fmt.Sprintf("Dataset %s not found", name), http.StatusNotFound)
+			return
+		}
+		http.StripPrefix("/datasets/"+name, tenant.handler).ServeHTTP(w, r)
+	default:
+		name := r.URL.Query().Get("dataset")
+		if name == "" {
+			var err error
+			if name, err = bodyDataset(r); err != nil {
+				http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+		}
+		tenant := rt.fallback
+		if name != "" {
+			var exists bool
+			if tenant, exists = rt.byName[name]; !exists {
+				http.Error(w, fmt.Sprintf("Dataset %s not found", name), http.StatusNotFound)
+				return
+			}
+		}
+		tenant.handler.ServeHTTP(w, r)
+	}
+}
+
+// Campo "dataset" de un cuerpo JSON; el cuerpo se restaura para el handler.
+// Falla solo si el cuerpo supera maxRequestBody.
+func bodyDataset(r *http.Request) (string, error) {
+	if r.Method != http.MethodPost || r.Body == nil {
+		return "", nil
+	}
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return "", err
+	}
+
+	var fields struct {
+		Dataset string `json:"dataset"`
+	}
+	json.Unmarshal(body, &fields)
+	return fields.Dataset, nil
+}
+
+// Ruta raíz
+func (rt *datasetRouter) handleRoot(w http.ResponseWriter, r *http.Request) {
+	names := make([]string, 0, len(rt.tenants))
+	for _, tenant := range rt.tenants {
+		names = append(names, tenant.Name)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"service":  "Movie Recommendation System API",
+		"version":  "1.0.0",
+		"status":   "running",
+		"datasets": names,
+	})
+}
+
+// Handler: GET /api/datasets
+func (rt *datasetRouter) handleDatasets(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	datasets := make([]DatasetInfo, 0, len(rt.tenants))
+	for _, tenant := range rt.tenants {
+		status := tenant.coordinator.ReloadStatus()
+		datasets = append(datasets, DatasetInfo{
+			DatasetConfig: tenant.DatasetConfig,
+			Default:       tenant.Default,
+			Prefix:        "/datasets/" + tenant.Name,
+			Current:       status.Current,
+			Reload:        status.State,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"datasets": datasets})
+}
@@ -11,10 +11,12 @@ import (
 	"math/rand"
 	"net"
 	"os"
+	"os/signal"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 )
 
@@ -24,7 +26,19 @@ type DistributedCoordinator struct {
 	localDataset *LocalDataSet
 	db           *Database
 	metrics      *SystemMetrics
+	content      *ContentModel
+	baseline     *BaselineModel
+	bpr          *BPRModel
+	slopeOne     *SlopeOneModel
+	slopeOneErr  error       // último fallo al construir Slope One
+	batch        *BatchStore // recomendaciones precalculadas (-batch-file)
+	hybrid       HybridOptions
 	numWorkers   int
+	dataset      string // nombre del dataset en los workers (campo "dataset" de cada solicitud)
+	loadMode     string // lenient o strict (ver ratings_loader.go)
+	dataRoot     string // directorio del que se aceptan recargas
+	datasetGen   int    // aumenta con cada recarga; descarta modelos de una versión anterior
+	reload       datasetReload
 	mu           sync.RWMutex
 }
 
@@ -34,12 +48,21 @@ type WorkerNode struct {
 	Active    bool
 }
 
+// Los mapas por usuario de UserRatingsMap y UserTimestamps no se modifican
+// una vez publicados (applyRating los reemplaza por una copia): basta leer
+// la referencia con mu y usarla después sin el bloqueo
 type LocalDataSet struct {
 	UserRatingsMap  map[int]map[int]float64
+	UserTimestamps  map[int]map[int]int64 // userID -> movieID -> timestamp
+	MaxTimestamp    int64
+	MovieRaters     map[int][]int // muestra de usuarios por película (item-based)
+	MovieCounts     map[int]int   // interacciones por película
 	Movies          map[int]string
 	UserAvgRatings  map[int]float64
 	GlobalAvgRating float64
 	AllUserIDs      []int
+	Ratings         int                     // ratings cargados de ratings.csv
+	LiveBase        map[int]map[int]float64 // ratings.csv de los usuarios con ratings en vivo
 	mu              sync.RWMutex
 }
 
@@ -49,6 +72,12 @@ type WorkerStats struct {
 	Ratings int
 }
 
+// Parámetros de la búsqueda de vecinos
+const (
+	neighbourCount   = 30
+	workerSampleSize = 5000 // Tamaño de muestra por worker
+)
+
 // Crear nuevo coordinador
 func NewDistributedCoordinator(workerAddresses []string, partitions []string, numWorkers int) *DistributedCoordinator {
 	workers := make([]WorkerNode, 0)
@@ -61,14 +90,22 @@ func NewDistributedCoordinator(workerAddresses []string, partitions []string, nu
 	}
 
 	return &DistributedCoordinator{
-		workers:    workers,
-		numWorkers: numWorkers,
-		localDataset: &LocalDataSet{
-			UserRatingsMap: make(map[int]map[int]float64),
-			Movies:         make(map[int]string),
-			UserAvgRatings: make(map[int]float64),
-			AllUserIDs:     make([]int, 0),
-		},
+		workers:      workers,
+		numWorkers:   numWorkers,
+		localDataset: newLocalDataSet(),
+	}
+}
+
+func newLocalDataSet() *LocalDataSet {
+	return &LocalDataSet{
+		UserRatingsMap: make(map[int]map[int]float64),
+		UserTimestamps: make(map[int]map[int]int64),
+		MovieRaters:    make(map[int][]int),
+		MovieCounts:    make(map[int]int),
+		Movies:         make(map[int]string),
+		UserAvgRatings: make(map[int]float64),
+		AllUserIDs:     make([]int, 0),
+		LiveBase:       make(map[int]map[int]float64),
 	}
 }
 
@@ -77,22 +114,28 @@ func (dc *DistributedCoordinator) LoadLocalData(ratingsPath, moviesPath string)
 	log.Println("[COORD] Cargando datos locales...")
 
 	// Cargar películas
-	if err := dc.loadMovies(moviesPath); err != nil {
+	if err := dc.localDataset.loadMovies(moviesPath); err != nil {
 		return fmt.Errorf("error cargando películas: %v", err)
 	}
 
 	// Cargar ratings
-	if err := dc.loadRatings(ratingsPath); err != nil {
+	if err := dc.localDataset.loadRatings(ratingsPath, dc.db, dc.loadMode); err != nil {
 		return fmt.Errorf("error cargando ratings: %v", err)
 	}
 
 	log.Printf("[COORD] Datos locales cargados: %d usuarios, %d películas",
 		len(dc.localDataset.UserRatingsMap), len(dc.localDataset.Movies))
 
+	// Sesgos de usuario y película para centrar las predicciones
+	dc.fitBaseline()
+
+	// Features de contenido para el recomendador por géneros/genome
+	dc.buildContentModel()
+
 	return nil
 }
 
-func (dc *DistributedCoordinator) loadMovies(filepath string) error {
+func (ds *LocalDataSet) loadMovies(filepath string) error {
 	file, err := os.Open(filepath)
 	if err != nil {
 		return err
@@ -116,80 +159,242 @@ func (dc *DistributedCoordinator) loadMovies(filepath string) error {
 			continue
 		}
 
-		dc.localDataset.Movies[movieID] = record[1]
+		ds.Movies[movieID] = record[1]
 	}
 
 	return nil
 }
 
-func (dc *DistributedCoordinator) loadRatings(filepath string) error {
-	file, err := os.Open(filepath)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	reader := csv.NewReader(file)
-	reader.Read()
-
+// Los ratings también se registran en db (si no es nil) para las consultas
+func (ds *LocalDataSet) loadRatings(filepath string, db *Database, mode string) error {
 	totalRating := 0.0
 	count := 0
 
-	for {
-		record, err := reader.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil || len(record) < 3 {
-			continue
+	opts := LoadOptions{
+		Mode: mode,
+		KnownMovie: func(movieID int) bool {
+			_, exists := ds.Movies[movieID]
+			return exists
+		},
+	}
+	seen := func(row RatingRow) bool {
+		_, duplicate := ds.UserRatingsMap[row.UserID][row.MovieID]
+		return duplicate
+	}
+	report, err := LoadRatingsCSV(filepath, opts, seen, func(row RatingRow, duplicate bool) {
+		userID, movieID := row.UserID, row.MovieID
+		if ds.UserRatingsMap[userID] == nil {
+			ds.UserRatingsMap[userID] = make(map[int]float64)
+			ds.UserTimestamps[userID] = make(map[int]int64)
+			ds.AllUserIDs = append(ds.AllUserIDs, userID)
 		}
 
-		userID, err1 := strconv.Atoi(record[0])
-		movieID, err2 := strconv.Atoi(record[1])
-		rating, err3 := strconv.ParseFloat(record[2], 64)
-
-		if err1 != nil || err2 != nil || err3 != nil {
-			continue
+		previousRating := ds.UserRatingsMap[userID][movieID]
+		previousTimestamp := ds.UserTimestamps[userID][movieID]
+		ds.UserRatingsMap[userID][movieID] = row.Rating
+		ds.UserTimestamps[userID][movieID] = row.Timestamp
+		if row.Timestamp > ds.MaxTimestamp {
+			ds.MaxTimestamp = row.Timestamp
 		}
-
-		if dc.localDataset.UserRatingsMap[userID] == nil {
-			dc.localDataset.UserRatingsMap[userID] = make(map[int]float64)
-			dc.localDataset.AllUserIDs = append(dc.localDataset.AllUserIDs, userID)
+		// Un par repetido reemplaza el rating: no cuenta otra vez ni vuelve
+		// a entrar en la muestra de la película
+		if duplicate {
+			totalRating += row.Rating - previousRating
+		} else {
+			totalRating += row.Rating
+			count++
+			ds.sampleMovieRater(movieID, userID)
 		}
 
-		dc.localDataset.UserRatingsMap[userID][movieID] = rating
-		totalRating += rating
-		count++
-
 		// Agregar también a la base de datos para consultas
-		if dc.db != nil {
-			dc.db.mu.Lock()
-			if dc.db.Ratings[userID] == nil {
-				dc.db.Ratings[userID] = make(map[int]float64)
-			}
-			dc.db.Ratings[userID][movieID] = rating
-			dc.db.mu.Unlock()
+		if db != nil {
+			db.AddRating(userID, movieID, row.Rating, row.Timestamp, previousTimestamp, false)
 		}
 
 		if count%1000000 == 0 {
 			log.Printf("[COORD] Procesados: %dM ratings...", count/1000000)
 		}
+	})
+	if err != nil {
+		return err
 	}
+	log.Printf("[COORD] %s: %s", filepath, report.Summary())
 
 	// Calcular promedios
-	dc.localDataset.GlobalAvgRating = totalRating / float64(count)
+	ds.Ratings = count
+	ds.GlobalAvgRating = totalRating / float64(count)
 
-	for userID, userRatings := range dc.localDataset.UserRatingsMap {
+	for userID, userRatings := range ds.UserRatingsMap {
 		sum := 0.0
 		for _, rating := range userRatings {
 			sum += rating
 		}
-		dc.localDataset.UserAvgRatings[userID] = sum / float64(len(userRatings))
+		ds.UserAvgRatings[userID] = sum / float64(len(userRatings))
 	}
 
 	return nil
 }
 
+// Registrar un rating recibido en vivo: se valida como en la carga del CSV,
+// se guarda en disco y después se aplica al dataset local, las tablas
+// incrementales y la base de datos
+func (dc *DistributedCoordinator) AddRating(userID, movieID int, rating float64, timestamp int64) error {
+	if !ratingInRange(rating) || !halfStar(rating) {
+		return requestError{fmt.Errorf("rating inválido: %v (se esperan medias estrellas entre 0.5 y 5)", rating)}
+	}
+	dc.localDataset.mu.RLock()
+	_, known := dc.localDataset.Movies[movieID]
+	dc.localDataset.mu.RUnlock()
+	if !known {
+		return requestError{fmt.Errorf("película desconocida: %d", movieID)}
+	}
+
+	if dc.db != nil {
+		if err := dc.db.PersistRating(userID, movieID, rating, timestamp); err != nil {
+			return fmt.Errorf("error guardando rating: %v", err)
+		}
+	}
+	dc.applyRating(userID, movieID, rating, timestamp)
+	return nil
+}
+
+// Volver a aplicar los ratings en vivo guardados (tras cargar los CSV)
+func (dc *DistributedCoordinator) ReplayStoredRatings() error {
+	if dc.db == nil {
+		return nil
+	}
+	ratings, err := dc.db.StoredRatings()
+	if err != nil {
+		return err
+	}
+	for _, r := range ratings {
+		dc.applyRating(r.UserID, r.MovieID, r.Rating, r.Timestamp)
+	}
+	if len(ratings) > 0 {
+		log.Printf("[COORD] %d ratings en vivo restaurados", len(ratings))
+	}
+	return nil
+}
+
+func (dc *DistributedCoordinator) applyRating(userID, movieID int, rating float64, timestamp int64) {
+	dc.localDataset.mu.Lock()
+	ds := dc.localDataset
+	previous := ds.UserRatingsMap[userID]
+	if previous == nil {
+		previous = make(map[int]float64)
+		ds.AllUserIDs = append(ds.AllUserIDs, userID)
+	}
+
+	// Copia del perfil: los lectores usan los mapas publicados sin el bloqueo,
+	// así que nunca se modifican; se reemplazan por la copia al final
+	userRatings := make(map[int]float64, len(previous)+1)
+	for id, r := range previous {
+		userRatings[id] = r
+	}
+	timestamps := make(map[int]int64, len(previous)+1)
+	for id, t := range ds.UserTimestamps[userID] {
+		timestamps[id] = t
+	}
+
+	// Los workers solo conocen ratings.csv: una tabla Slope One nueva suma
+	// la diferencia entre estos ratings y los actuales (ver publishSlopeOne)
+	if _, tracked := ds.LiveBase[userID]; !tracked {
+		ds.LiveBase[userID] = previous
+	}
+
+	// Slope One necesita los ratings previos del usuario. Se lee con el
+	// dataset bloqueado para no perder ratings mientras se publica una tabla.
+	dc.mu.RLock()
+	slopeOne := dc.slopeOne
+	dc.mu.RUnlock()
+	if slopeOne != nil {
+		slopeOne.AddRating(previous, movieID, rating)
+	}
+
+	if _, rerated := previous[movieID]; !rerated {
+		ds.sampleMovieRater(movieID, userID)
+	}
+	previousTimestamp := timestamps[movieID]
+	userRatings[movieID] = rating
+	timestamps[movieID] = timestamp
+	ds.UserRatingsMap[userID] = userRatings
+	ds.UserTimestamps[userID] = timestamps
+	// MaxTimestamp es el "ahora" del dataset (referencia de time_aware.go):
+	// un rating en vivo no lo mueve, igual que en las tendencias
+
+	sum := 0.0
+	for _, r := range userRatings {
+		sum += r
+	}
+	ds.UserAvgRatings[userID] = sum / float64(len(userRatings))
+	dc.localDataset.mu.Unlock()
+
+	if dc.db != nil {
+		dc.db.AddRating(userID, movieID, rating, timestamp, previousTimestamp, true)
+		dc.db.InvalidateRecommendations(userID)
+	}
+}
+
+// Algoritmos disponibles en /api/recommendations
+const (
+	AlgorithmKNN     = "knn"
+	AlgorithmContent = "content"
+	AlgorithmHybrid  = "hybrid"
+
+	// Feedback implícito: cualquier rating es una interacción
+	AlgorithmBPR          = "bpr"
+	AlgorithmCooccurrence = "cooccurrence"
+
+	AlgorithmSlopeOne = "slope_one"
+)
+
+// Algoritmo conocido ("" equivale a knn)
+func validAlgorithm(algorithm string) bool {
+	switch algorithm {
+	case "", AlgorithmKNN, AlgorithmContent, AlgorithmHybrid, AlgorithmBPR, AlgorithmCooccurrence, AlgorithmSlopeOne:
+		return true
+	}
+	return false
+}
+
+// Error causado por los parámetros de la solicitud: la API responde 400 (el
+// resto de los errores son del servidor)
+type requestError struct{ error }
+
+// Modelo que aún se entrena o construye en segundo plano: la API responde
+// 503 y el cliente puede reintentar
+var errModelNotReady = fmt.Errorf("modelo aún no disponible, reintentar más tarde")
+
+// Obtener recomendaciones con el algoritmo indicado
+func (dc *DistributedCoordinator) GetRecommendations(userID int, topN int, algorithm string) ([]RecommendationItem, int, error) {
+	return dc.WithFeedback(userID, topN, func(n int) ([]RecommendationItem, int, error) {
+		return dc.recommendWith(userID, n, algorithm)
+	})
+}
+
+func (dc *DistributedCoordinator) recommendWith(userID int, topN int, algorithm string) ([]RecommendationItem, int, error) {
+	switch algorithm {
+	case "", AlgorithmKNN:
+		return dc.GetDistributedRecommendations(userID, topN)
+	case AlgorithmContent:
+		recommendations, err := dc.GetContentRecommendations(userID, topN)
+		return recommendations, 0, err
+	case AlgorithmHybrid:
+		return dc.GetHybridRecommendations(userID, topN, HybridOptions{})
+	case AlgorithmBPR:
+		recommendations, err := dc.GetBPRRecommendations(userID, topN)
+		return recommendations, 0, err
+	case AlgorithmCooccurrence:
+		recommendations, err := dc.GetCooccurrenceRecommendations(userID, topN)
+		return recommendations, 0, err
+	case AlgorithmSlopeOne:
+		recommendations, err := dc.GetSlopeOneRecommendations(userID, topN)
+		return recommendations, 0, err
+	default:
+		return nil, 0, fmt.Errorf("algoritmo desconocido: %s", algorithm)
+	}
+}
+
 // Obtener recomendaciones distribuidas
 func (dc *DistributedCoordinator) GetDistributedRecommendations(userID int, topN int) ([]RecommendationItem, int, error) {
 	dc.localDataset.mu.RLock()
@@ -197,13 +402,39 @@ func (dc *DistributedCoordinator) GetDistributedRecommendations(userID int, topN
 	userAvg := dc.localDataset.UserAvgRatings[userID]
 	dc.localDataset.mu.RUnlock()
 
-	if len(userRatings) == 0 {
-		return nil, 0, fmt.Errorf("usuario no encontrado")
+	return dc.recommendForProfile(userID, userRatings, userAvg, topN)
+}
+
+// Recomendaciones para un perfil de ratings (registrado o anónimo)
+func (dc *DistributedCoordinator) recommendForProfile(userID int, userRatings map[int]float64, userAvg float64, topN int) ([]RecommendationItem, int, error) {
+	// Usuarios nuevos o con pocos ratings: ruta cold-start
+	if len(userRatings) < coldStartThreshold {
+		return dc.coldStartRecommendations(userID, userRatings, userAvg, topN)
+	}
+
+	allSimilarities, activeWorkers := dc.findNeighbours(userID, userRatings, userAvg)
+
+	// Generar recomendaciones
+	scores := dc.predictScores(userRatings, userAvg, allSimilarities)
+	recommendations := dc.rankPredictions(scores, topN)
+
+	// Gustos poco comunes: con pocos vecinos fiables k-NN conserva una parte
+	// proporcional de la lista y el resto viene de contenido
+	if reliable := reliableNeighbours(allSimilarities); reliable < minNeighboursForKNN {
+		log.Printf("[COORD] Usuario %d con %d vecinos fiables de %d: completando con contenido",
+			userID, reliable, len(allSimilarities))
+		keep := topN * reliable / minNeighboursForKNN
+		recommendations = dc.fillWithContent(recommendations, keep, userRatings, userAvg, topN)
 	}
 
+	return recommendations, activeWorkers, nil
+}
+
+// Buscar los k vecinos más similares en todos los workers
+func (dc *DistributedCoordinator) findNeighbours(userID int, userRatings map[int]float64, userAvg float64) ([]SimilarityResult, int) {
 	// Preparar solicitud para workers
-	k := 30
-	sampleSize := 5000 // Tamaño de muestra por worker
+	k := neighbourCount
+	sampleSize := workerSampleSize
 
 	req := SimilarityRequest{
 		TargetUserID:  userID,
@@ -212,6 +443,65 @@ func (dc *DistributedCoordinator) GetDistributedRecommendations(userID int, topN
 		K:             k,
 		SampleSize:    sampleSize,
 	}
+	return dc.searchNeighbours(req)
+}
+
+// Vecinos de una solicitud en los workers o, sin workers configurados (p. ej.
+// batch local), en el dataset local
+func (dc *DistributedCoordinator) searchNeighbours(req SimilarityRequest) ([]SimilarityResult, int) {
+	similarities, activeWorkers := dc.queryWorkers(req)
+	if activeWorkers == 0 {
+		return dc.localNeighbours(req), 0
+	}
+	return similarities, activeWorkers
+}
+
+// Vecinos calculados en el coordinador con la misma similitud que los
+// workers (también la temporal), muestreando tantos usuarios como todos los
+// workers juntos
+func (dc *DistributedCoordinator) localNeighbours(req SimilarityRequest) []SimilarityResult {
+	dc.localDataset.mu.RLock()
+	defer dc.localDataset.mu.RUnlock()
+
+	sampleSize := req.SampleSize * dc.numWorkers
+	candidateUserIDs := dc.localDataset.AllUserIDs
+	step := 1
+	if sampleSize > 0 && len(candidateUserIDs) > sampleSize {
+		step = len(candidateUserIDs) / sampleSize
+	}
+
+	similarities := make([]SimilarityResult, 0)
+	for i := 0; i < len(candidateUserIDs); i += step {
+		userID := candidateUserIDs[i]
+		if userID == req.TargetUserID {
+			continue
+		}
+		var similarity float64
+		var commonCount int
+		if req.Time != nil {
+			similarity, commonCount = TimeWeightedCosine(req.TargetRatings, req.TargetTimestamps,
+				dc.localDataset.UserRatingsMap[userID], dc.localDataset.UserTimestamps[userID], req.TargetAvg, req.Time)
+		} else {
+			similarity, commonCount = CenteredCosine(req.TargetRatings, dc.localDataset.UserRatingsMap[userID],
+				req.TargetAvg, dc.localDataset.UserAvgRatings[userID])
+		}
+		if similarity > 0 && commonCount >= 3 {
+			similarities = append(similarities, SimilarityResult{UserID: userID, Similarity: similarity})
+		}
+	}
+
+	sort.Slice(similarities, func(i, j int) bool {
+		return similarities[i].Similarity > similarities[j].Similarity
+	})
+	if len(similarities) > req.K {
+		similarities = similarities[:req.K]
+	}
+	return similarities
+}
+
+// Enviar la solicitud a todos los workers activos y combinar los top-k
+func (dc *DistributedCoordinator) queryWorkers(req SimilarityRequest) ([]SimilarityResult, int) {
+	k := req.K
 
 	// Enviar a todos los workers en paralelo
 	var wg sync.WaitGroup
@@ -261,52 +551,116 @@ func (dc *DistributedCoordinator) GetDistributedRecommendations(userID int, topN
 		allSimilarities = allSimilarities[:k]
 	}
 
-	// Generar recomendaciones
-	recommendations := dc.generateRecommendations(userID, allSimilarities, topN)
-
-	return recommendations, activeWorkers, nil
+	return allSimilarities, activeWorkers
 }
 
 // Generar recomendaciones a partir de usuarios similares
 func (dc *DistributedCoordinator) generateRecommendations(targetUserID int, similarUsers []SimilarityResult, topN int) []RecommendationItem {
 	dc.localDataset.mu.RLock()
-	defer dc.localDataset.mu.RUnlock()
-
 	targetRatings := dc.localDataset.UserRatingsMap[targetUserID]
 	targetAvg := dc.localDataset.UserAvgRatings[targetUserID]
+	dc.localDataset.mu.RUnlock()
+
+	scores := dc.predictScores(targetRatings, targetAvg, similarUsers)
+	return dc.rankPredictions(scores, topN)
+}
+
+// Predecir el rating de cada película no vista a partir de los vecinos
+func (dc *DistributedCoordinator) predictScores(targetRatings map[int]float64, targetAvg float64, similarUsers []SimilarityResult) map[int]float64 {
+	return dc.predictScoresAt(targetRatings, targetAvg, similarUsers, nil)
+}
+
+// Igual que predictScores, pero ignorando ratings posteriores a tc.AsOf y
+// ponderando cada rating de los vecinos por su antigüedad.
+// Las desviaciones se centran en el predictor base (μ + b_u + b_i).
+func (dc *DistributedCoordinator) predictScoresAt(targetRatings map[int]float64, targetAvg float64, similarUsers []SimilarityResult, tc *TimeContext) map[int]float64 {
+	dc.mu.RLock()
+	baseline := dc.baseline
+	dc.mu.RUnlock()
+
+	targetBase := dc.baselineFor(targetRatings, targetAvg)
+
+	dc.localDataset.mu.RLock()
+	defer dc.localDataset.mu.RUnlock()
 
 	candidateScores := make(map[int]float64)
 	candidateWeights := make(map[int]float64)
 
 	for _, simUser := range similarUsers {
 		userRatings := dc.localDataset.UserRatingsMap[simUser.UserID]
+		userTimestamps := dc.localDataset.UserTimestamps[simUser.UserID]
 		userAvg := dc.localDataset.UserAvgRatings[simUser.UserID]
 
+		// Con as_of el sesgo (o promedio) del vecino se recalcula dentro de la ventana
+		var windowRatings map[int]float64
+		if tc != nil && tc.AsOf > 0 {
+			windowRatings = make(map[int]float64)
+			sum := 0.0
+			for movieID, rating := range userRatings {
+				if tc.Includes(userTimestamps[movieID]) {
+					windowRatings[movieID] = rating
+					sum += rating
+				}
+			}
+			if len(windowRatings) == 0 {
+				continue
+			}
+			userAvg = sum / float64(len(windowRatings))
+		}
+
+		neighbourBase := func(int) float64 { return userAvg }
+		if baseline != nil {
+			bias := baseline.UserBias(simUser.UserID)
+			if windowRatings != nil {
+				bias = baseline.FitUserBias(windowRatings)
+			}
+			neighbourBase = func(movieID int) float64 { return baseline.Predict(bias, movieID) }
+		}
+
 		for movieID, rating := range userRatings {
-			if _, seen := targetRatings[movieID]; !seen {
-				candidateScores[movieID] += simUser.Similarity * (rating - userAvg)
-				candidateWeights[movieID] += math.Abs(simUser.Similarity)
+			if _, seen := targetRatings[movieID]; seen {
+				continue
+			}
+			ts := userTimestamps[movieID]
+			if !tc.Includes(ts) {
+				continue
 			}
+			weight := tc.Weight(ts)
+			candidateScores[movieID] += weight * simUser.Similarity * (rating - neighbourBase(movieID))
+			candidateWeights[movieID] += weight * math.Abs(simUser.Similarity)
 		}
 	}
 
-	recommendations := make([]RecommendationItem, 0)
+	predictions := make(map[int]float64)
 	for movieID, scoreSum := range candidateScores {
 		weightSum := candidateWeights[movieID]
 		if weightSum > 0 {
-			predictedScore := targetAvg + (scoreSum / weightSum)
+			predictions[movieID] = targetBase(movieID) + (scoreSum / weightSum)
+		} else {
+			predictions[movieID] = targetBase(movieID)
+		}
+	}
 
-			title := "Unknown"
-			if movieTitle, exists := dc.localDataset.Movies[movieID]; exists {
-				title = movieTitle
-			}
+	return predictions
+}
 
-			recommendations = append(recommendations, RecommendationItem{
-				MovieID:        movieID,
-				Title:          title,
-				PredictedScore: predictedScore,
-			})
+// Ordenar predicciones y devolver las topN con su título
+func (dc *DistributedCoordinator) rankPredictions(scores map[int]float64, topN int) []RecommendationItem {
+	dc.localDataset.mu.RLock()
+	defer dc.localDataset.mu.RUnlock()
+
+	recommendations := make([]RecommendationItem, 0, len(scores))
+	for movieID, predictedScore := range scores {
+		title := "Unknown"
+		if movieTitle, exists := dc.localDataset.Movies[movieID]; exists {
+			title = movieTitle
 		}
+
+		recommendations = append(recommendations, RecommendationItem{
+			MovieID:        movieID,
+			Title:          title,
+			PredictedScore: predictedScore,
+		})
 	}
 
 	sort.Slice(recommendations, func(i, j int) bool {
@@ -329,6 +683,7 @@ func (dc *DistributedCoordinator) sendToWorker(address string, req SimilarityReq
 	defer conn.Close()
 
 	// Enviar solicitud
+	req.Dataset = dc.dataset
 	encoder := json.NewEncoder(conn)
 	if err := encoder.Encode(req); err != nil {
 		return SimilarityResponse{}, err
@@ -366,54 +721,145 @@ func (dc *DistributedCoordinator) GetWorkerStats(address string) WorkerStats {
 func main() {
 	rand.Seed(time.Now().UnixNano())
 
+	mode := flag.String("mode", ModeServer, "Modo de ejecución: server (API), batch (recomendaciones offline) o validate (informe de calidad de los datos)")
 	apiPort := flag.String("api", ":8080", "Puerto de la API")
+	datasetsSpec := flag.String("datasets", defaultDatasets, "Datasets servidos: nombre=directorio separados por comas (el primero es el por defecto)")
+	dbPath := flag.String("db", "db/recsys.db", "Archivo de la base de datos (log de solo anexado)")
+	storeKind := flag.String("store", StoreLog, "Almacenamiento de la base de datos: log, memory, sqlite o postgres")
+	storeDSN := flag.String("store-dsn", os.Getenv("STORE_DSN"), "Conexión SQL para -store sqlite/postgres")
+	snapshotDir := flag.String("snapshot-dir", "db/snapshots", "Directorio de snapshots de la base de datos")
+	snapshotKeep := flag.Int("snapshot-keep", defaultSnapshotKeep, "Snapshots que se conservan")
+	snapshotInterval := flag.Duration("snapshot-interval", defaultSnapshotInterval, "Intervalo entre snapshots automáticos (0 = desactivado)")
+	hybridWeights := flag.String("hybrid-weights", os.Getenv("HYBRID_WEIGHTS"), "Pesos del recomendador híbrido (knn=0.4,item=0.3,content=0.2,popular=0.1)")
+	cacheSize := flag.Int("cache-size", defaultCacheCapacity, "Entradas máximas de la caché de recomendaciones")
+	cacheTTL := flag.Duration("cache-ttl", defaultCacheTTL, "Vigencia de cada entrada de la caché")
+	warmInterval := flag.Duration("warm-interval", defaultWarmInterval, "Intervalo de precalentamiento de la caché (0 = desactivado)")
+	hybridBlend := flag.String("hybrid-blend", BlendWeighted, "Combinación del híbrido: weighted, rrf o switching")
+	batchFile := flag.String("batch-file", "", "Archivo batch binario que sirve la API")
+	batchUsers := flag.String("batch-users", "", "Batch: archivo con un userID por línea (default: todos)")
+	batchOutput := flag.String("batch-output", "", "Batch: archivo de salida (default: recommendations.<formato>)")
+	batchFormat := flag.String("batch-format", BatchFormatCSV, "Batch: formato csv, jsonl o bin")
+	batchTopN := flag.Int("batch-top-n", 10, "Batch: recomendaciones por usuario")
+	batchAlgorithm := flag.String("batch-algorithm", AlgorithmKNN, "Batch: algoritmo (knn, content, hybrid, bpr, cooccurrence, slope_one)")
+	batchConcurrency := flag.Int("batch-concurrency", 0, "Batch: usuarios en paralelo (default: número de workers)")
+	batchLocal := flag.Bool("batch-local", false, "Batch: buscar vecinos en el coordinador sin usar los workers")
+	strict := flag.Bool("strict", false, "Rechazar un dataset con cualquier fila inválida o anómala (carga, recarga y validate)")
+	validateReport := flag.String("validate-report", "", "Validate: archivo JSON con el informe")
+	adminToken := flag.String("admin-token", os.Getenv("ADMIN_TOKEN"), "Token de /api/admin/* (Authorization: Bearer); sin token solo se aceptan clientes locales")
+	dataRoot := flag.String("data-root", "datasets", "Directorio del que /api/admin/reload puede cargar datasets")
 	flag.Parse()
 
+	switch *mode {
+	case ModeServer, ModeDistributed, ModeBatch, ModeValidate:
+	default:
+		log.Fatalf("[ERROR] Modo desconocido: %s", *mode)
+	}
+
 	log.Println(strings.Repeat("=", 70))
 	log.Println("🎬 SISTEMA DE RECOMENDACIÓN DISTRIBUIDO - DOCKER")
 	log.Println(strings.Repeat("=", 70))
 
-	// Inicializar base de datos
-	db := NewDatabase("db_snapshot.json")
-	if err := db.LoadMovies("data_25M/movies.csv"); err != nil {
-		log.Printf("[WARN] No se pudieron cargar películas: %v", err)
+	datasets, err := ParseDatasets(*datasetsSpec)
+	if err != nil {
+		log.Fatalf("[ERROR] -datasets: %v", err)
+	}
+	loadMode := LoadLenient
+	if *strict {
+		loadMode = LoadStrict
 	}
-	db.StartCleanupTask()
-
-	// Inicializar métricas
-	metrics := NewSystemMetrics()
-	metrics.StartMonitoring()
 
-	// Modo distribuido con workers (Docker)
-	log.Println("\n[MODE] Distribuido con 8 workers")
+	if *mode == ModeValidate {
+		log.Println("\n[MODE] Validación de datasets")
+		if err := RunValidate(datasets, loadMode, *validateReport); err != nil {
+			log.Fatalf("[ERROR] Validación: %v", err)
+		}
+		return
+	}
 
 	// Configurar workers desde variable de entorno
 	workersEnv := os.Getenv("WORKERS")
-	if workersEnv == "" {
-		log.Fatal("[ERROR] Variable WORKERS no configurada. Debe ejecutarse con Docker.")
+	workerAddresses := make([]string, 0)
+	if *mode == ModeBatch {
+		log.Println("\n[MODE] Batch")
+		if workersEnv != "" && !*batchLocal {
+			workerAddresses = strings.Split(workersEnv, ",")
+		} else {
+			log.Println("[BATCH] Sin workers: vecinos calculados en el coordinador")
+		}
+		// El batch procesa solo el dataset por defecto
+		datasets = datasets[:1]
+	} else {
+		// Modo distribuido con workers (Docker)
+		log.Println("\n[MODE] Distribuido con 8 workers")
+		if workersEnv == "" {
+			log.Fatal("[ERROR] Variable WORKERS no configurada. Debe ejecutarse con Docker.")
+		}
+		workerAddresses = strings.Split(workersEnv, ",")
 	}
-
-	workerAddresses := strings.Split(workersEnv, ",")
 	log.Printf("[COORD] Workers desde env: %v", workerAddresses)
 
-	partitions := []string{
-		"data_25M/ratings_part1.csv",
-		"data_25M/ratings_part2.csv",
-		"data_25M/ratings_part3.csv",
-		"data_25M/ratings_part4.csv",
-		"data_25M/ratings_part5.csv",
-		"data_25M/ratings_part6.csv",
-		"data_25M/ratings_part7.csv",
-		"data_25M/ratings_part8.csv",
+	// Configuración del recomendador híbrido
+	var weights map[string]float64
+	if *hybridWeights != "" {
+		weights, err = ParseHybridWeights(*hybridWeights)
+		if err != nil {
+			log.Fatalf("[ERROR] Pesos híbridos inválidos: %v", err)
+		}
 	}
 
-	coordinator := NewDistributedCoordinator(workerAddresses, partitions, 8)
-	coordinator.db = db
-	coordinator.metrics = metrics
+	// Base de datos, catálogo y ratings de cada dataset
+	options := TenantOptions{
+		Store:         StoreConfig{Kind: *storeKind, Path: *dbPath, DSN: *storeDSN},
+		SnapshotDir:   *snapshotDir,
+		SnapshotKeep:  *snapshotKeep,
+		CacheSize:     *cacheSize,
+		CacheTTL:      *cacheTTL,
+		Workers:       workerAddresses,
+		HybridBlend:   *hybridBlend,
+		HybridWeights: weights,
+		LoadMode:      loadMode,
+		AdminToken:    *adminToken,
+		DataRoot:      *dataRoot,
+	}
+	tenants := make([]*Tenant, 0, len(datasets))
+	for i, dataset := range datasets {
+		tenant, err := OpenTenant(dataset, i == 0, options)
+		if err != nil {
+			log.Fatalf("[ERROR] Dataset %s: %v", dataset.Name, err)
+		}
+		tenants = append(tenants, tenant)
+	}
+	coordinator := tenants[0].coordinator
 
-	// Cargar datos locales para coordinación
-	if err := coordinator.LoadLocalData("data_25M/ratings.csv", "data_25M/movies.csv"); err != nil {
-		log.Fatalf("[ERROR] No se pudieron cargar datos: %v", err)
+	if *mode == ModeBatch {
+		output := *batchOutput
+		if output == "" {
+			output = "recommendations." + *batchFormat
+		}
+		err := coordinator.RunBatch(BatchOptions{
+			UsersFile:   *batchUsers,
+			Output:      output,
+			Format:      *batchFormat,
+			TopN:        *batchTopN,
+			Algorithm:   *batchAlgorithm,
+			Concurrency: *batchConcurrency,
+		})
+		if err != nil {
+			log.Fatalf("[ERROR] Batch: %v", err)
+		}
+		return
+	}
+
+	// Recomendaciones precalculadas servidas por la API
+	if *batchFile != "" {
+		if err := coordinator.LoadBatchFile(*batchFile); err != nil {
+			log.Printf("[WARN] No se pudo cargar el archivo batch: %v", err)
+		}
+	}
+
+	// Limpieza, snapshots, métricas y modelos en segundo plano
+	for _, tenant := range tenants {
+		tenant.Start(*snapshotInterval)
 	}
 
 	log.Println("\n[INFO] Verificando workers...")
@@ -426,11 +872,18 @@ func main() {
 	}
 
 	// Iniciar API REST
-	go StartAPIServer(coordinator, db, metrics, *apiPort)
+	go StartAPIServer(tenants, *apiPort, *warmInterval)
 
 	log.Printf("\n[INFO] Sistema distribuido listo")
 	log.Printf("[INFO] API disponible en http://localhost%s", *apiPort)
 	log.Println("\n[INFO] Presiona Ctrl+C para detener")
 
-	select {}
+	// Guardar el estado al detener el contenedor (SIGTERM) o con Ctrl+C
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	<-signals
+	log.Println("[INFO] Deteniendo: guardando bases de datos...")
+	for _, tenant := range tenants {
+		tenant.Close()
+	}
 }
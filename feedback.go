@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"time"
+)
+
+// FEEDBACK NEGATIVO - "No me interesa", descartar, ya la vi
+// ============================================================================
+// Las películas con feedback nunca vuelven a recomendarse. Descartar y "no me
+// interesa" además penalizan a las películas parecidas (coseno de géneros y
+// genome), restando penalización * similitud máxima * |score|.
+const (
+	FeedbackDismiss       = "dismiss"
+	FeedbackNotInterested = "not_interested"
+	FeedbackAlreadySeen   = "already_seen"
+
+	feedbackPenalty        = 0.5
+	feedbackSimilarMovies  = 50 // rechazos más recientes usados para penalizar
+	feedbackCandidateExtra = 20 // candidatos extra pedidos para compensar exclusiones
+)
+
+type FeedbackEvent struct {
+	MovieID   int       `json:"movie_id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func validFeedbackType(feedbackType string) bool {
+	switch feedbackType {
+	case FeedbackDismiss, FeedbackNotInterested, FeedbackAlreadySeen:
+		return true
+	}
+	return false
+}
+
+// Registrar feedback de un usuario, persistirlo e invalidar su caché
+func (db *Database) AddFeedback(userID, movieID int, feedbackType string) (FeedbackEvent, error) {
+	if !validFeedbackType(feedbackType) {
+		return FeedbackEvent{}, requestError{fmt.Errorf("tipo de feedback desconocido: %s", feedbackType)}
+	}
+
+	db.mu.Lock()
+	if _, exists := db.Movies[movieID]; !exists && len(db.Movies) > 0 {
+		db.mu.Unlock()
+		return FeedbackEvent{}, requestError{fmt.Errorf("película no encontrada")}
+	}
+	db.mu.Unlock()
+
+	// Se guarda antes de confirmarlo
+	event := FeedbackEvent{MovieID: movieID, Type: feedbackType, CreatedAt: time.Now()}
+	if err := db.store.PutFeedback(StoredFeedback{userID, event}); err != nil {
+		return FeedbackEvent{}, fmt.Errorf("error guardando feedback: %v", err)
+	}
+
+	db.mu.Lock()
+	if db.Feedback[userID] == nil {
+		db.Feedback[userID] = make(map[int]FeedbackEvent)
+	}
+	db.Feedback[userID][movieID] = event
+	db.mu.Unlock()
+
+	db.InvalidateRecommendations(userID)
+
+	log.Printf("[DB] Feedback %s de usuario %d para película %d", feedbackType, userID, movieID)
+	return event, nil
+}
+
+// Feedback de un usuario, más reciente primero
+func (db *Database) GetFeedback(userID int) []FeedbackEvent {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	events := make([]FeedbackEvent, 0, len(db.Feedback[userID]))
+	for _, event := range db.Feedback[userID] {
+		events = append(events, event)
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].CreatedAt.After(events[j].CreatedAt)
+	})
+	return events
+}
+
+// Películas que no deben recomendarse al usuario
+func (db *Database) FeedbackExclusions(userID int) map[int]bool {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	excluded := make(map[int]bool, len(db.Feedback[userID]))
+	for movieID := range db.Feedback[userID] {
+		excluded[movieID] = true
+	}
+	return excluded
+}
+
+// Pedir candidatos extra al algoritmo, quitar las películas con feedback y
+// penalizar las parecidas a las rechazadas
+func (dc *DistributedCoordinator) WithFeedback(userID, topN int, recommend func(n int) ([]RecommendationItem, int, error)) ([]RecommendationItem, int, error) {
+	if dc.db == nil || userID == anonymousUserID {
+		return recommend(topN)
+	}
+	events := dc.db.GetFeedback(userID)
+	if len(events) == 0 {
+		return recommend(topN)
+	}
+
+	recommendations, nodesUsed, err := recommend(topN + len(events) + feedbackCandidateExtra)
+	if err != nil {
+		return nil, nodesUsed, err
+	}
+
+	rejected := make([]int, 0, feedbackSimilarMovies)
+	excluded := make(map[int]bool, len(events))
+	for _, event := range events {
+		excluded[event.MovieID] = true
+		if event.Type != FeedbackAlreadySeen && len(rejected) < feedbackSimilarMovies {
+			rejected = append(rejected, event.MovieID)
+		}
+	}
+
+	filtered := make([]RecommendationItem, 0, len(recommendations))
+	for _, rec := range recommendations {
+		if !excluded[rec.MovieID] {
+			filtered = append(filtered, rec)
+		}
+	}
+
+	if len(rejected) > 0 {
+		similarity := dc.similarityToMovies(rejected)
+		for i := range filtered {
+			if sim := similarity(filtered[i].MovieID); sim > 0 {
+				filtered[i].PredictedScore -= feedbackPenalty * sim * math.Abs(filtered[i].PredictedScore)
+			}
+		}
+		sort.SliceStable(filtered, func(i, j int) bool {
+			return filtered[i].PredictedScore > filtered[j].PredictedScore
+		})
+	}
+
+	if len(filtered) > topN {
+		filtered = filtered[:topN]
+	}
+	return filtered, nodesUsed, nil
+}
+
+// Similitud de contenido máxima de una película con cualquiera de las dadas
+func (dc *DistributedCoordinator) similarityToMovies(movieIDs []int) func(movieID int) float64 {
+	dc.mu.RLock()
+	model := dc.content
+	dc.mu.RUnlock()
+
+	if model == nil || dc.db == nil {
+		return func(int) float64 { return 0 }
+	}
+
+	type featureVector struct {
+		dense []float64
+		norm  float64
+	}
+
+	dc.db.mu.RLock()
+	vectors := make([]featureVector, 0, len(movieIDs))
+	for _, movieID := range movieIDs {
+		movie, exists := dc.db.Movies[movieID]
+		if !exists {
+			continue
+		}
+		dense := make([]float64, len(model.genreIndex)+len(model.tagMeans))
+		model.addFeatures(dense, movie, 1)
+		norm := 0.0
+		for _, v := range dense {
+			norm += v * v
+		}
+		if norm > 0 {
+			vectors = append(vectors, featureVector{dense, math.Sqrt(norm)})
+		}
+	}
+	dc.db.mu.RUnlock()
+
+	return func(movieID int) float64 {
+		dc.db.mu.RLock()
+		defer dc.db.mu.RUnlock()
+
+		movie, exists := dc.db.Movies[movieID]
+		if !exists {
+			return 0
+		}
+
+		best := 0.0
+		for _, vector := range vectors {
+			dot, norm := model.dot(vector.dense, movie)
+			if norm > 0 {
+				best = math.Max(best, dot/(vector.norm*norm))
+			}
+		}
+		return best
+	}
+}
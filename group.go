@@ -0,0 +1,240 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"sync"
+)
+
+// RECOMENDACIONES GRUPALES - "Ver juntos"
+// ============================================================================
+// Estrategias de agregación de las predicciones de cada miembro
+const (
+	GroupAverage      = "average"       // promedio de las predicciones
+	GroupLeastMisery  = "least_misery"  // la predicción más baja del grupo
+	GroupMostPleasure = "most_pleasure" // la predicción más alta del grupo
+	GroupFairness     = "fairness"      // selección voraz favoreciendo al menos satisfecho
+)
+
+const maxGroupSize = 20
+
+type groupMember struct {
+	UserID      int
+	Ratings     map[int]float64
+	Avg         float64
+	Baseline    func(movieID int) float64
+	Excluded    map[int]bool // películas con feedback negativo o ya vistas
+	Predictions map[int]float64
+	Nodes       int
+}
+
+// Obtener recomendaciones para varios usuarios a la vez
+func (dc *DistributedCoordinator) GetGroupRecommendations(userIDs []int, strategy string, topN int) ([]RecommendationItem, int, error) {
+	if len(userIDs) == 0 {
+		return nil, 0, requestError{fmt.Errorf("el grupo no tiene usuarios")}
+	}
+	if len(userIDs) > maxGroupSize {
+		return nil, 0, requestError{fmt.Errorf("el grupo supera el máximo de %d usuarios", maxGroupSize)}
+	}
+	if strategy == "" {
+		strategy = GroupAverage
+	}
+	switch strategy {
+	case GroupAverage, GroupLeastMisery, GroupMostPleasure, GroupFairness:
+	default:
+		return nil, 0, requestError{fmt.Errorf("estrategia desconocida: %s", strategy)}
+	}
+
+	members := make([]*groupMember, 0, len(userIDs))
+	seenIDs := make(map[int]bool)
+
+	dc.localDataset.mu.RLock()
+	for _, userID := range userIDs {
+		if seenIDs[userID] {
+			continue
+		}
+		seenIDs[userID] = true
+
+		// Sin ratings no hay predicciones que agregar para ese miembro
+		userRatings := dc.localDataset.UserRatingsMap[userID]
+		if len(userRatings) == 0 {
+			dc.localDataset.mu.RUnlock()
+			return nil, 0, requestError{fmt.Errorf("el usuario %d no tiene ratings", userID)}
+		}
+		members = append(members, &groupMember{
+			UserID:  userID,
+			Ratings: userRatings,
+			Avg:     dc.localDataset.UserAvgRatings[userID],
+		})
+	}
+	dc.localDataset.mu.RUnlock()
+
+	// Predicciones de cada miembro en paralelo
+	var wg sync.WaitGroup
+	for _, member := range members {
+		wg.Add(1)
+		go func(m *groupMember) {
+			defer wg.Done()
+			similarUsers, nodes := dc.findNeighbours(m.UserID, m.Ratings, m.Avg)
+			m.Predictions = dc.predictScores(m.Ratings, m.Avg, similarUsers)
+			m.Baseline = dc.baselineFor(m.Ratings, m.Avg)
+			if dc.db != nil {
+				m.Excluded = dc.db.FeedbackExclusions(m.UserID)
+			}
+			m.Nodes = nodes
+		}(member)
+	}
+	wg.Wait()
+
+	nodesUsed := 0
+	for _, member := range members {
+		if member.Nodes > nodesUsed {
+			nodesUsed = member.Nodes
+		}
+	}
+
+	// Candidatos: películas predichas para algún miembro que nadie ha visto
+	candidates := make(map[int][]float64)
+	for _, member := range members {
+		for movieID := range member.Predictions {
+			if _, exists := candidates[movieID]; exists || groupHasSeen(members, movieID) {
+				continue
+			}
+			scores := make([]float64, len(members))
+			for i, m := range members {
+				scores[i] = m.predict(movieID)
+			}
+			candidates[movieID] = scores
+		}
+	}
+
+	log.Printf("[COORD] Grupo de %d usuarios (%s): %d candidatos", len(members), strategy, len(candidates))
+
+	if strategy == GroupFairness {
+		order := fairnessSelection(candidates, len(members), topN)
+		return dc.groupItemsInOrder(order, candidates), nodesUsed, nil
+	}
+
+	aggregated := make(map[int]float64, len(candidates))
+	for movieID, scores := range candidates {
+		aggregated[movieID] = aggregateGroupScores(scores, strategy)
+	}
+
+	return dc.rankPredictions(aggregated, topN), nodesUsed, nil
+}
+
+// Construir items respetando el orden de selección, con el promedio del grupo como score
+func (dc *DistributedCoordinator) groupItemsInOrder(order []int, candidates map[int][]float64) []RecommendationItem {
+	dc.localDataset.mu.RLock()
+	defer dc.localDataset.mu.RUnlock()
+
+	recommendations := make([]RecommendationItem, 0, len(order))
+	for _, movieID := range order {
+		title := "Unknown"
+		if movieTitle, exists := dc.localDataset.Movies[movieID]; exists {
+			title = movieTitle
+		}
+		recommendations = append(recommendations, RecommendationItem{
+			MovieID:        movieID,
+			Title:          title,
+			PredictedScore: aggregateGroupScores(candidates[movieID], GroupAverage),
+		})
+	}
+	return recommendations
+}
+
+// Predicción del miembro, o su predictor base si los vecinos no cubren la película
+func (m *groupMember) predict(movieID int) float64 {
+	if score, exists := m.Predictions[movieID]; exists {
+		return score
+	}
+	if m.Baseline != nil {
+		return m.Baseline(movieID)
+	}
+	return m.Avg
+}
+
+func groupHasSeen(members []*groupMember, movieID int) bool {
+	for _, member := range members {
+		if _, seen := member.Ratings[movieID]; seen || member.Excluded[movieID] {
+			return true
+		}
+	}
+	return false
+}
+
+func aggregateGroupScores(scores []float64, strategy string) float64 {
+	switch strategy {
+	case GroupLeastMisery:
+		result := math.Inf(1)
+		for _, s := range scores {
+			result = math.Min(result, s)
+		}
+		return result
+	case GroupMostPleasure:
+		result := math.Inf(-1)
+		for _, s := range scores {
+			result = math.Max(result, s)
+		}
+		return result
+	default:
+		sum := 0.0
+		for _, s := range scores {
+			sum += s
+		}
+		return sum / float64(len(scores))
+	}
+}
+
+// Selección voraz: en cada paso se pondera más a los miembros menos
+// satisfechos por las películas ya elegidas. Devuelve los movieIDs en orden.
+func fairnessSelection(candidates map[int][]float64, numMembers int, topN int) []int {
+	movieIDs := make([]int, 0, len(candidates))
+	for movieID := range candidates {
+		movieIDs = append(movieIDs, movieID)
+	}
+	sort.Ints(movieIDs)
+
+	satisfaction := make([]float64, numMembers)
+	chosen := make(map[int]bool)
+	order := make([]int, 0, topN)
+
+	for len(order) < topN && len(order) < len(movieIDs) {
+		weights := make([]float64, numMembers)
+		weightSum := 0.0
+		for i := range weights {
+			weights[i] = 1.0 / (1.0 + satisfaction[i])
+			weightSum += weights[i]
+		}
+
+		bestMovie := -1
+		bestScore := math.Inf(-1)
+		for _, movieID := range movieIDs {
+			if chosen[movieID] {
+				continue
+			}
+			score := 0.0
+			for i, s := range candidates[movieID] {
+				score += weights[i] / weightSum * s
+			}
+			if score > bestScore {
+				bestScore = score
+				bestMovie = movieID
+			}
+		}
+		if bestMovie < 0 {
+			break
+		}
+
+		chosen[bestMovie] = true
+		for i, s := range candidates[bestMovie] {
+			// Satisfacción normalizada a la escala 0-5
+			satisfaction[i] += s / 5.0
+		}
+		order = append(order, bestMovie)
+	}
+
+	return order
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// RECOMENDADOR HÍBRIDO - Combinación de varios algoritmos
+// ============================================================================
+// Cada fuente (k-NN de usuarios en los workers, item-based, contenido y
+// popularidad) propone candidatos que se combinan por pesos, por Reciprocal
+// Rank Fusion o por una regla de conmutación según el número de ratings del
+// usuario. Si una fuente no devuelve nada, su peso se reparte entre el resto.
+const (
+	SourceKNN     = "knn"
+	SourceItem    = "item"
+	SourceContent = "content"
+	SourcePopular = "popular"
+
+	BlendWeighted  = "weighted"
+	BlendRRF       = "rrf"
+	BlendSwitching = "switching"
+
+	rrfConstant            = 60.0
+	hybridCandidatesFactor = 5 // candidatos por fuente = topN * factor
+)
+
+var hybridSources = []string{SourceKNN, SourceItem, SourceContent, SourcePopular}
+
+// Pesos por defecto si no se configuran con -hybrid-weights / HYBRID_WEIGHTS
+var defaultHybridWeights = map[string]float64{
+	SourceKNN:     0.4,
+	SourceItem:    0.3,
+	SourceContent: 0.2,
+	SourcePopular: 0.1,
+}
+
+type HybridOptions struct {
+	Blend   string
+	Weights map[string]float64
+}
+
+// Candidatos de una fuente, ordenados de mejor a peor
+type sourceResult struct {
+	source string
+	ranked []int
+	scores map[int]float64
+	nodes  int
+}
+
+// Interpretar pesos con formato "knn=0.5,item=0.3,popular=0.2"
+func ParseHybridWeights(value string) (map[string]float64, error) {
+	weights := make(map[string]float64)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		fields := strings.SplitN(part, "=", 2)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("peso inválido: %s", part)
+		}
+		weight, err := strconv.ParseFloat(strings.TrimSpace(fields[1]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("peso inválido para %s: %v", fields[0], err)
+		}
+		weights[strings.TrimSpace(fields[0])] = weight
+	}
+	return weights, validateHybridWeights(weights)
+}
+
+func validateHybridWeights(weights map[string]float64) error {
+	total := 0.0
+	for source, weight := range weights {
+		if _, exists := defaultHybridWeights[source]; !exists {
+			return fmt.Errorf("fuente desconocida: %s", source)
+		}
+		if weight < 0 {
+			return fmt.Errorf("peso negativo para %s", source)
+		}
+		total += weight
+	}
+	if len(weights) > 0 && total == 0 {
+		return fmt.Errorf("todos los pesos son 0")
+	}
+	return nil
+}
+
+// Completar las opciones de la solicitud con la configuración del coordinador
+func (dc *DistributedCoordinator) resolveHybridOptions(opts HybridOptions) (HybridOptions, error) {
+	dc.mu.RLock()
+	defaults := dc.hybrid
+	dc.mu.RUnlock()
+
+	if opts.Blend == "" {
+		opts.Blend = defaults.Blend
+	}
+	if opts.Blend == "" {
+		opts.Blend = BlendWeighted
+	}
+	switch opts.Blend {
+	case BlendWeighted, BlendRRF, BlendSwitching:
+	default:
+		return opts, fmt.Errorf("combinación desconocida: %s", opts.Blend)
+	}
+
+	// Los pesos de la solicitud reemplazan a los configurados
+	if len(opts.Weights) == 0 {
+		opts.Weights = defaults.Weights
+	}
+	if len(opts.Weights) == 0 {
+		opts.Weights = defaultHybridWeights
+	}
+	if err := validateHybridWeights(opts.Weights); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+// Recomendaciones híbridas para un usuario registrado
+func (dc *DistributedCoordinator) GetHybridRecommendations(userID int, topN int, opts HybridOptions) ([]RecommendationItem, int, error) {
+	opts, err := dc.resolveHybridOptions(opts)
+	if err != nil {
+		return nil, 0, requestError{err}
+	}
+
+	dc.localDataset.mu.RLock()
+	userRatings := dc.localDataset.UserRatingsMap[userID]
+	userAvg := dc.localDataset.UserAvgRatings[userID]
+	dc.localDataset.mu.RUnlock()
+
+	if opts.Blend == BlendSwitching {
+		return dc.switchingRecommendations(userID, userRatings, userAvg, topN)
+	}
+
+	results := dc.collectSources(userID, userRatings, userAvg, topN, opts.Weights)
+
+	var blended map[int]float64
+	var sources map[int]string
+	if opts.Blend == BlendRRF {
+		blended, sources = rrfBlend(results, opts.Weights)
+	} else {
+		blended, sources = weightedBlend(results, opts.Weights)
+	}
+
+	nodesUsed := 0
+	for _, result := range results {
+		nodesUsed += result.nodes
+	}
+
+	// Sin candidatos de ninguna fuente: popularidad
+	if len(blended) == 0 {
+		return dc.labelSource(dc.GetPopularRecommendations("", userRatings, topN), SourcePopular), nodesUsed, nil
+	}
+
+	recommendations := dc.rankPredictions(blended, topN)
+	for i := range recommendations {
+		recommendations[i].Source = sources[recommendations[i].MovieID]
+	}
+
+	log.Printf("[COORD] Híbrido (%s) usuario %d: %d fuentes con candidatos", opts.Blend, userID, len(results))
+
+	return recommendations, nodesUsed, nil
+}
+
+// Consultar en paralelo las fuentes con peso > 0; se omiten las vacías
+func (dc *DistributedCoordinator) collectSources(userID int, userRatings map[int]float64, userAvg float64, topN int, weights map[string]float64) []sourceResult {
+	var wg sync.WaitGroup
+	resultsChan := make(chan sourceResult, len(hybridSources))
+
+	for _, source := range hybridSources {
+		if weights[source] <= 0 {
+			continue
+		}
+		wg.Add(1)
+		go func(source string) {
+			defer wg.Done()
+			result := dc.sourceCandidates(source, userID, userRatings, userAvg, topN*hybridCandidatesFactor)
+			if len(result.ranked) > 0 {
+				resultsChan <- result
+			}
+		}(source)
+	}
+
+	wg.Wait()
+	close(resultsChan)
+
+	results := make([]sourceResult, 0, len(hybridSources))
+	for result := range resultsChan {
+		results = append(results, result)
+	}
+	return results
+}
+
+// Candidatos de una fuente para un perfil
+func (dc *DistributedCoordinator) sourceCandidates(source string, userID int, userRatings map[int]float64, userAvg float64, limit int) sourceResult {
+	result := sourceResult{source: source, scores: map[int]float64{}}
+
+	switch source {
+	case SourceKNN:
+		// Con menos de 3 ratings no hay vecinos posibles
+		if len(userRatings) >= 3 {
+			similarUsers, nodes := dc.findNeighbours(userID, userRatings, userAvg)
+			result.scores = dc.predictScores(userRatings, userAvg, similarUsers)
+			result.nodes = nodes
+		}
+	case SourceItem:
+		result.scores = dc.itemScores(userID, userRatings, userAvg)
+	case SourceContent:
+		result.scores = dc.contentScores(userRatings, userAvg)
+	case SourcePopular:
+		if dc.db != nil {
+			for _, movie := range dc.db.GetPopularMovies("", 0, 0, limit+len(userRatings)) {
+				if _, seen := userRatings[movie.MovieID]; !seen {
+					result.scores[movie.MovieID] = movie.BayesianAvg
+				}
+			}
+		}
+	}
+
+	result.ranked = make([]int, 0, len(result.scores))
+	for movieID := range result.scores {
+		result.ranked = append(result.ranked, movieID)
+	}
+	sort.Slice(result.ranked, func(i, j int) bool {
+		si, sj := result.scores[result.ranked[i]], result.scores[result.ranked[j]]
+		if si != sj {
+			return si > sj
+		}
+		return result.ranked[i] < result.ranked[j]
+	})
+	if len(result.ranked) > limit {
+		result.ranked = result.ranked[:limit]
+	}
+
+	return result
+}
+
+// Suma ponderada de scores normalizados a 0-1 por fuente (min-max). El peso
+// de las fuentes sin candidatos no cuenta en la normalización.
+func weightedBlend(results []sourceResult, weights map[string]float64) (map[int]float64, map[int]string) {
+	totalWeight := 0.0
+	for _, result := range results {
+		totalWeight += weights[result.source]
+	}
+
+	blended := make(map[int]float64)
+	sources := make(map[int]string)
+	best := make(map[int]float64)
+	if totalWeight == 0 {
+		return blended, sources
+	}
+
+	for _, result := range results {
+		minScore, maxScore := result.scores[result.ranked[len(result.ranked)-1]], result.scores[result.ranked[0]]
+		weight := weights[result.source] / totalWeight
+
+		for _, movieID := range result.ranked {
+			normalized := 1.0
+			if maxScore > minScore {
+				normalized = (result.scores[movieID] - minScore) / (maxScore - minScore)
+			}
+			contribution := weight * normalized
+			blended[movieID] += contribution
+			if _, exists := sources[movieID]; !exists || contribution > best[movieID] {
+				sources[movieID] = result.source
+				best[movieID] = contribution
+			}
+		}
+	}
+
+	return blended, sources
+}
+
+// Reciprocal Rank Fusion: sum(peso / (60 + posición))
+func rrfBlend(results []sourceResult, weights map[string]float64) (map[int]float64, map[int]string) {
+	blended := make(map[int]float64)
+	sources := make(map[int]string)
+	best := make(map[int]float64)
+
+	for _, result := range results {
+		weight := weights[result.source]
+		for rank, movieID := range result.ranked {
+			contribution := weight / (rrfConstant + float64(rank+1))
+			blended[movieID] += contribution
+			if _, exists := sources[movieID]; !exists || contribution > best[movieID] {
+				sources[movieID] = result.source
+				best[movieID] = contribution
+			}
+		}
+	}
+
+	return blended, sources
+}
+
+// Conmutación: se elige la fuente según los ratings del usuario y, si no
+// alcanza para topN, se completa con las siguientes
+func (dc *DistributedCoordinator) switchingRecommendations(userID int, userRatings map[int]float64, userAvg float64, topN int) ([]RecommendationItem, int, error) {
+	var order []string
+	switch {
+	case len(userRatings) == 0:
+		order = []string{SourcePopular}
+	case len(userRatings) < coldStartThreshold:
+		order = []string{SourceContent, SourceItem, SourcePopular}
+	default:
+		order = []string{SourceKNN, SourceItem, SourceContent, SourcePopular}
+	}
+
+	recommendations := make([]RecommendationItem, 0, topN)
+	included := make(map[int]bool)
+	nodesUsed := 0
+
+	for _, source := range order {
+		if len(recommendations) >= topN {
+			break
+		}
+		result := dc.sourceCandidates(source, userID, userRatings, userAvg, topN*hybridCandidatesFactor)
+		nodesUsed += result.nodes
+
+		items := dc.labelSource(dc.rankPredictions(result.scores, topN+len(recommendations)), source)
+		for _, item := range items {
+			if len(recommendations) >= topN {
+				break
+			}
+			if included[item.MovieID] {
+				continue
+			}
+			included[item.MovieID] = true
+			recommendations = append(recommendations, item)
+		}
+	}
+
+	log.Printf("[COORD] Híbrido (switching) usuario %d: %d ratings, fuente principal %s", userID, len(userRatings), order[0])
+
+	return recommendations, nodesUsed, nil
+}
+
+func (dc *DistributedCoordinator) labelSource(items []RecommendationItem, source string) []RecommendationItem {
+	for i := range items {
+		items[i].Source = source
+	}
+	return items
+}
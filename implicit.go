@@ -0,0 +1,285 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// FEEDBACK IMPLÍCITO - BPR y co-ocurrencia
+// ============================================================================
+// Cualquier rating cuenta como una interacción (vista/click), sin importar
+// las estrellas. BPR aprende factores de usuario y película de modo que las
+// películas con interacción queden por encima de las no vistas; la
+// co-ocurrencia puntúa cada candidato por el coseno binario con las
+// películas recientes del usuario.
+const (
+	bprFactors         = 32
+	bprEpochs          = 10
+	bprLearningRate    = 0.05
+	bprRegularization  = 0.0025
+	bprBiasReg         = 0.001
+	bprInitScale       = 0.01
+	bprMaxSamplesEpoch = 5000000 // muestras (u, i, j) por época
+
+	cooccurrenceSeeds = 50 // interacciones más recientes usadas como semilla
+)
+
+type BPRModel struct {
+	userIndex   map[int]int
+	itemIDs     []int
+	userFactors [][]float32
+	itemFactors [][]float32
+	itemBias    []float32
+}
+
+// Entrenar BPR en segundo plano; el modelo queda disponible al terminar
+func (dc *DistributedCoordinator) StartBPRTraining() {
+	dc.mu.RLock()
+	generation := dc.datasetGen
+	dc.mu.RUnlock()
+
+	go func() {
+		start := time.Now()
+		model := dc.trainBPR()
+		if model == nil {
+			return
+		}
+
+		dc.mu.Lock()
+		if dc.datasetGen != generation {
+			dc.mu.Unlock()
+			log.Println("[COORD] Modelo BPR descartado: el dataset cambió durante el entrenamiento")
+			return
+		}
+		dc.bpr = model
+		dc.mu.Unlock()
+
+		log.Printf("[COORD] Modelo BPR listo: %d usuarios, %d películas (%v)",
+			len(model.userFactors), len(model.itemIDs), time.Since(start))
+	}()
+}
+
+func (dc *DistributedCoordinator) trainBPR() *BPRModel {
+	// Copia compacta de las interacciones: usuario -> índices de película ordenados
+	dc.localDataset.mu.RLock()
+	itemIndex := make(map[int]int, len(dc.localDataset.MovieCounts))
+	itemIDs := make([]int, 0, len(dc.localDataset.MovieCounts))
+	for movieID := range dc.localDataset.MovieCounts {
+		itemIndex[movieID] = len(itemIDs)
+		itemIDs = append(itemIDs, movieID)
+	}
+
+	userIndex := make(map[int]int, len(dc.localDataset.AllUserIDs))
+	userItems := make([][]int32, 0, len(dc.localDataset.AllUserIDs))
+	interactions := 0
+	for _, userID := range dc.localDataset.AllUserIDs {
+		ratings := dc.localDataset.UserRatingsMap[userID]
+		items := make([]int32, 0, len(ratings))
+		for movieID := range ratings {
+			items = append(items, int32(itemIndex[movieID]))
+		}
+		sort.Slice(items, func(i, j int) bool { return items[i] < items[j] })
+		userIndex[userID] = len(userItems)
+		userItems = append(userItems, items)
+		interactions += len(items)
+	}
+	dc.localDataset.mu.RUnlock()
+
+	if interactions == 0 || len(itemIDs) < 2 {
+		log.Println("[COORD] BPR: sin interacciones para entrenar")
+		return nil
+	}
+
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	model := &BPRModel{
+		userIndex:   userIndex,
+		itemIDs:     itemIDs,
+		userFactors: randomFactors(rng, len(userItems)),
+		itemFactors: randomFactors(rng, len(itemIDs)),
+		itemBias:    make([]float32, len(itemIDs)),
+	}
+
+	samples := interactions
+	if samples > bprMaxSamplesEpoch {
+		samples = bprMaxSamplesEpoch
+	}
+
+	log.Printf("[COORD] Entrenando BPR: %d interacciones, %d épocas de %d muestras",
+		interactions, bprEpochs, samples)
+
+	for epoch := 1; epoch <= bprEpochs; epoch++ {
+		correct := 0
+		for s := 0; s < samples; s++ {
+			u := rng.Intn(len(userItems))
+			items := userItems[u]
+			if len(items) == 0 || len(items) == len(itemIDs) {
+				continue
+			}
+			i := int(items[rng.Intn(len(items))])
+			j := rng.Intn(len(itemIDs))
+			for containsItem(items, int32(j)) {
+				j = rng.Intn(len(itemIDs))
+			}
+			if model.update(u, i, j) > 0 {
+				correct++
+			}
+		}
+		// Fracción de pares bien ordenados antes de cada actualización (≈ AUC)
+		log.Printf("[COORD] BPR época %d/%d: AUC estimado %.3f", epoch, bprEpochs, float64(correct)/float64(samples))
+	}
+
+	return model
+}
+
+func randomFactors(rng *rand.Rand, n int) [][]float32 {
+	factors := make([][]float32, n)
+	for i := range factors {
+		factors[i] = make([]float32, bprFactors)
+		for f := range factors[i] {
+			factors[i][f] = float32(rng.NormFloat64() * bprInitScale)
+		}
+	}
+	return factors
+}
+
+func containsItem(sorted []int32, item int32) bool {
+	k := sort.Search(len(sorted), func(i int) bool { return sorted[i] >= item })
+	return k < len(sorted) && sorted[k] == item
+}
+
+// Paso SGD sobre la tripleta (u, i vista, j no vista); devuelve x_uij previo
+func (m *BPRModel) update(u, i, j int) float64 {
+	userVec, posVec, negVec := m.userFactors[u], m.itemFactors[i], m.itemFactors[j]
+
+	x := float64(m.itemBias[i] - m.itemBias[j])
+	for f := range userVec {
+		x += float64(userVec[f] * (posVec[f] - negVec[f]))
+	}
+	// Derivada de ln σ(x)
+	g := float32(1 / (1 + math.Exp(x)))
+	lr := float32(bprLearningRate)
+
+	for f := range userVec {
+		uf, pf, nf := userVec[f], posVec[f], negVec[f]
+		userVec[f] += lr * (g*(pf-nf) - bprRegularization*uf)
+		posVec[f] += lr * (g*uf - bprRegularization*pf)
+		negVec[f] += lr * (-g*uf - bprRegularization*nf)
+	}
+	m.itemBias[i] += lr * (g - bprBiasReg*m.itemBias[i])
+	m.itemBias[j] += lr * (-g - bprBiasReg*m.itemBias[j])
+
+	return x
+}
+
+// Recomendaciones BPR: score = u·v_i + b_i para las películas no vistas
+func (dc *DistributedCoordinator) GetBPRRecommendations(userID int, topN int) ([]RecommendationItem, error) {
+	dc.mu.RLock()
+	model := dc.bpr
+	dc.mu.RUnlock()
+
+	if model == nil {
+		return nil, fmt.Errorf("modelo BPR aún en entrenamiento: %w", errModelNotReady)
+	}
+	u, exists := model.userIndex[userID]
+	if !exists {
+		return nil, requestError{fmt.Errorf("usuario no encontrado")}
+	}
+
+	dc.localDataset.mu.RLock()
+	seen := dc.localDataset.UserRatingsMap[userID]
+	dc.localDataset.mu.RUnlock()
+
+	userVec := model.userFactors[u]
+	scores := make(map[int]float64, len(model.itemIDs))
+	for idx, movieID := range model.itemIDs {
+		if _, rated := seen[movieID]; rated {
+			continue
+		}
+		score := float64(model.itemBias[idx])
+		for f, value := range model.itemFactors[idx] {
+			score += float64(userVec[f] * value)
+		}
+		scores[movieID] = score
+	}
+
+	return dc.rankPredictions(scores, topN), nil
+}
+
+// Co-ocurrencia: sum_i |U_i ∩ U_j| / sqrt(|U_i|·|U_j|) sobre las interacciones
+// recientes i del usuario; la intersección se estima con la muestra de
+// usuarios de cada película
+func (dc *DistributedCoordinator) cooccurrenceScores(userID int, interactions map[int]float64, timestamps map[int]int64) map[int]float64 {
+	scores := make(map[int]float64)
+
+	seeds := make([]int, 0, len(interactions))
+	for movieID := range interactions {
+		seeds = append(seeds, movieID)
+	}
+	sort.Slice(seeds, func(i, j int) bool {
+		if timestamps[seeds[i]] != timestamps[seeds[j]] {
+			return timestamps[seeds[i]] > timestamps[seeds[j]]
+		}
+		return seeds[i] < seeds[j]
+	})
+	if len(seeds) > cooccurrenceSeeds {
+		seeds = seeds[:cooccurrenceSeeds]
+	}
+
+	dc.localDataset.mu.RLock()
+	defer dc.localDataset.mu.RUnlock()
+
+	for _, seed := range seeds {
+		raters := dc.localDataset.MovieRaters[seed]
+		seedCount := dc.localDataset.MovieCounts[seed]
+		if len(raters) == 0 || seedCount == 0 {
+			continue
+		}
+
+		co := make(map[int]int)
+		sampled := 0
+		for _, raterID := range raters {
+			if raterID == userID {
+				continue
+			}
+			sampled++
+			for movieID := range dc.localDataset.UserRatingsMap[raterID] {
+				if _, seen := interactions[movieID]; !seen {
+					co[movieID]++
+				}
+			}
+		}
+		if sampled == 0 {
+			continue
+		}
+
+		// La muestra representa a todos los usuarios de la semilla
+		scale := float64(seedCount) / float64(sampled)
+		for movieID, count := range co {
+			candidateCount := dc.localDataset.MovieCounts[movieID]
+			if candidateCount == 0 {
+				continue
+			}
+			scores[movieID] += float64(count) * scale / math.Sqrt(float64(seedCount)*float64(candidateCount))
+		}
+	}
+
+	return scores
+}
+
+// Recomendaciones por co-ocurrencia para un usuario registrado
+func (dc *DistributedCoordinator) GetCooccurrenceRecommendations(userID int, topN int) ([]RecommendationItem, error) {
+	dc.localDataset.mu.RLock()
+	interactions := dc.localDataset.UserRatingsMap[userID]
+	timestamps := dc.localDataset.UserTimestamps[userID]
+	dc.localDataset.mu.RUnlock()
+
+	if len(interactions) == 0 {
+		return nil, requestError{fmt.Errorf("usuario no encontrado")}
+	}
+
+	return dc.rankPredictions(dc.cooccurrenceScores(userID, interactions, timestamps), topN), nil
+}
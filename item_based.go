@@ -0,0 +1,124 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// RECOMENDADOR ITEM-BASED - Coseno ajustado entre películas
+// ============================================================================
+// La similitud entre una película vista y un candidato se estima sobre una
+// muestra de usuarios que valoraron la vista (reservoir sampling durante la
+// carga), centrando cada rating en el promedio de quien lo dio.
+const (
+	itemRaterSample     = 200 // usuarios muestreados por película
+	itemSeedMovies      = 50  // películas del usuario usadas como semilla
+	itemMinCoRaters     = 3
+	itemSupportShrink   = 10.0 // significance weighting
+	itemScoreDamping    = 0.5  // evita predicciones extremas con poco apoyo
+	itemMaxRatingsPerCo = 2000 // usuarios con más ratings se ignoran como co-raters
+)
+
+// Mantener una muestra uniforme de usuarios por película durante la carga
+func (ds *LocalDataSet) sampleMovieRater(movieID, userID int) {
+	ds.MovieCounts[movieID]++
+	raters := ds.MovieRaters[movieID]
+	if len(raters) < itemRaterSample {
+		ds.MovieRaters[movieID] = append(raters, userID)
+		return
+	}
+	if j := rand.Intn(ds.MovieCounts[movieID]); j < itemRaterSample {
+		raters[j] = userID
+	}
+}
+
+type itemPair struct {
+	num     float64
+	normI   float64
+	normJ   float64
+	support int
+}
+
+// Predicción item-based para las películas no vistas del perfil
+func (dc *DistributedCoordinator) itemScores(userID int, userRatings map[int]float64, userAvg float64) map[int]float64 {
+	scores := make(map[int]float64)
+	if len(userRatings) == 0 {
+		return scores
+	}
+
+	// Semillas: películas con mayor desviación respecto al promedio del usuario
+	seeds := make([]int, 0, len(userRatings))
+	for movieID := range userRatings {
+		seeds = append(seeds, movieID)
+	}
+	sort.Slice(seeds, func(i, j int) bool {
+		di := math.Abs(userRatings[seeds[i]] - userAvg)
+		dj := math.Abs(userRatings[seeds[j]] - userAvg)
+		if di != dj {
+			return di > dj
+		}
+		return seeds[i] < seeds[j]
+	})
+	if len(seeds) > itemSeedMovies {
+		seeds = seeds[:itemSeedMovies]
+	}
+
+	dc.localDataset.mu.RLock()
+	defer dc.localDataset.mu.RUnlock()
+
+	weightedSum := make(map[int]float64)
+	weightTotal := make(map[int]float64)
+
+	for _, seed := range seeds {
+		deviation := userRatings[seed] - userAvg
+		pairs := make(map[int]*itemPair)
+
+		for _, raterID := range dc.localDataset.MovieRaters[seed] {
+			if raterID == userID {
+				continue
+			}
+			raterRatings := dc.localDataset.UserRatingsMap[raterID]
+			if len(raterRatings) > itemMaxRatingsPerCo {
+				continue
+			}
+			raterAvg := dc.localDataset.UserAvgRatings[raterID]
+			devI := raterRatings[seed] - raterAvg
+
+			for movieID, rating := range raterRatings {
+				if _, seen := userRatings[movieID]; seen {
+					continue
+				}
+				devJ := rating - raterAvg
+				pair := pairs[movieID]
+				if pair == nil {
+					pair = &itemPair{}
+					pairs[movieID] = pair
+				}
+				pair.num += devI * devJ
+				pair.normI += devI * devI
+				pair.normJ += devJ * devJ
+				pair.support++
+			}
+		}
+
+		for movieID, pair := range pairs {
+			if pair.support < itemMinCoRaters || pair.normI == 0 || pair.normJ == 0 {
+				continue
+			}
+			similarity := pair.num / math.Sqrt(pair.normI*pair.normJ)
+			similarity *= math.Min(float64(pair.support), itemSupportShrink) / itemSupportShrink
+			if similarity <= 0 {
+				continue
+			}
+			weightedSum[movieID] += similarity * deviation
+			weightTotal[movieID] += similarity
+		}
+	}
+
+	for movieID, total := range weightTotal {
+		scores[movieID] = userAvg + weightedSum[movieID]/(total+itemScoreDamping)
+	}
+
+	return scores
+}
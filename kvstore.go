@@ -0,0 +1,550 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ALMACENAMIENTO - Log de solo anexado con índice en memoria
+// ============================================================================
+// Cada escritura es un lote de operaciones (put/delete) que se añade al final
+// del archivo como un único registro con CRC y se sincroniza con fsync antes
+// de confirmar: un lote se aplica entero o no se aplica. Un registro
+// incompleto al final (proceso terminado a mitad) se descarta al abrir. El
+// índice guarda dónde está el último valor de cada clave; la compactación
+// copia los valores vivos a un archivo nuevo y lo renombra sobre el original.
+//
+//	registro  crc32 uint32, largo uint32, operaciones
+//	operación tipo uint8, largo clave uint32, largo valor uint32, clave, valor
+const (
+	kvOpPut    = 1
+	kvOpDelete = 2
+
+	kvRecordHeader = 8
+	kvOpHeader     = 9
+
+	kvCompactMinBytes = 64 << 20 // no compactar logs pequeños
+	kvCompactBatch    = 1 << 20  // bytes por registro al compactar
+)
+
+var kvCRCTable = crc32.MakeTable(crc32.Castagnoli)
+
+type kvLocation struct {
+	offset int64 // posición del valor en el archivo
+	size   uint32
+	crc    uint32 // del valor, para omitir escrituras sin cambios
+}
+
+type KVStore struct {
+	path      string
+	file      *os.File
+	index     map[string]kvLocation
+	size      int64 // bytes del log
+	liveBytes int64 // bytes de los valores vigentes
+	readOnly  bool  // abierto con OpenKVStoreReadOnly: no se escribe ni se recorta
+	mu        sync.RWMutex
+}
+
+type kvOp struct {
+	op    byte
+	key   string
+	value []byte
+}
+
+// Lote de operaciones que se escribe de forma atómica
+type KVBatch struct {
+	ops []kvOp
+}
+
+func (b *KVBatch) Put(key string, value []byte) {
+	b.ops = append(b.ops, kvOp{kvOpPut, key, value})
+}
+
+func (b *KVBatch) Delete(key string) {
+	b.ops = append(b.ops, kvOp{kvOpDelete, key, nil})
+}
+
+func (b *KVBatch) Len() int {
+	return len(b.ops)
+}
+
+// Abrir (o crear) el log y reconstruir el índice
+func OpenKVStore(path string) (*KVStore, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	store := &KVStore{path: path, file: file, index: make(map[string]kvLocation)}
+	if err := store.replay(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// Abrir un log existente solo para leerlo (p. ej. un snapshot): un final
+// dañado se ignora pero el archivo no se modifica
+func OpenKVStoreReadOnly(path string) (*KVStore, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	store := &KVStore{path: path, file: file, index: make(map[string]kvLocation), readOnly: true}
+	if err := store.replay(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// Leer todos los registros; lo que sigue al último registro válido se trunca
+// (salvo en solo lectura, donde solo se ignora)
+func (s *KVStore) replay() error {
+	info, err := s.file.Stat()
+	if err != nil {
+		return err
+	}
+	reader := bufio.NewReaderSize(s.file, 1<<20)
+	header := make([]byte, kvRecordHeader)
+	offset := int64(0)
+
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			if err != io.EOF {
+				log.Printf("[DB] Cabecera incompleta en offset %d, descartando el final del log", offset)
+			}
+			break
+		}
+		// Una cabecera dañada no puede pedir más de lo que queda del archivo
+		length := binary.LittleEndian.Uint32(header[4:])
+		if int64(length) > info.Size()-offset-kvRecordHeader {
+			log.Printf("[DB] Largo de registro inválido en offset %d, descartando el final del log", offset)
+			break
+		}
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(reader, payload); err != nil {
+			log.Printf("[DB] Registro incompleto en offset %d, descartando el final del log", offset)
+			break
+		}
+		if crc32.Checksum(payload, kvCRCTable) != binary.LittleEndian.Uint32(header) {
+			log.Printf("[DB] CRC inválido en offset %d, descartando el final del log", offset)
+			break
+		}
+
+		ops, err := decodeKVOps(payload)
+		if err != nil {
+			log.Printf("[DB] Registro ilegible en offset %d: %v", offset, err)
+			break
+		}
+		s.applyIndex(ops, offset+kvRecordHeader)
+		offset += kvRecordHeader + int64(length)
+	}
+
+	if info.Size() != offset && !s.readOnly {
+		if err := s.file.Truncate(offset); err != nil {
+			return err
+		}
+		if err := s.file.Sync(); err != nil {
+			return err
+		}
+	}
+	s.size = offset
+	_, err = s.file.Seek(offset, io.SeekStart)
+	return err
+}
+
+func decodeKVOps(payload []byte) ([]kvOp, error) {
+	ops := make([]kvOp, 0, 1)
+	for pos := 0; pos < len(payload); {
+		if pos+kvOpHeader > len(payload) {
+			return nil, fmt.Errorf("operación truncada")
+		}
+		op := payload[pos]
+		keyLen := int(binary.LittleEndian.Uint32(payload[pos+1:]))
+		valueLen := int(binary.LittleEndian.Uint32(payload[pos+5:]))
+		pos += kvOpHeader
+		if pos+keyLen+valueLen > len(payload) {
+			return nil, fmt.Errorf("operación truncada")
+		}
+		key := string(payload[pos : pos+keyLen])
+		pos += keyLen
+		ops = append(ops, kvOp{op, key, payload[pos : pos+valueLen]})
+		pos += valueLen
+	}
+	return ops, nil
+}
+
+// Actualizar el índice con las operaciones de un registro que empieza
+// (sin cabecera) en base
+func (s *KVStore) applyIndex(ops []kvOp, base int64) {
+	pos := base
+	for _, op := range ops {
+		valueOffset := pos + kvOpHeader + int64(len(op.key))
+		if previous, exists := s.index[op.key]; exists {
+			s.liveBytes -= int64(previous.size)
+		}
+		switch op.op {
+		case kvOpPut:
+			s.index[op.key] = kvLocation{
+				offset: valueOffset,
+				size:   uint32(len(op.value)),
+				crc:    crc32.Checksum(op.value, kvCRCTable),
+			}
+			s.liveBytes += int64(len(op.value))
+		case kvOpDelete:
+			delete(s.index, op.key)
+		}
+		pos = valueOffset + int64(len(op.value))
+	}
+}
+
+func encodeKVRecord(ops []kvOp) []byte {
+	length := 0
+	for _, op := range ops {
+		length += kvOpHeader + len(op.key) + len(op.value)
+	}
+	record := make([]byte, kvRecordHeader+length)
+	pos := kvRecordHeader
+	for _, op := range ops {
+		record[pos] = op.op
+		binary.LittleEndian.PutUint32(record[pos+1:], uint32(len(op.key)))
+		binary.LittleEndian.PutUint32(record[pos+5:], uint32(len(op.value)))
+		pos += kvOpHeader
+		pos += copy(record[pos:], op.key)
+		pos += copy(record[pos:], op.value)
+	}
+	binary.LittleEndian.PutUint32(record, crc32.Checksum(record[kvRecordHeader:], kvCRCTable))
+	binary.LittleEndian.PutUint32(record[4:], uint32(length))
+	return record
+}
+
+// Escribir un lote; los puts con el mismo valor (comparado byte a byte si
+// coinciden tamaño y CRC) y los deletes de claves inexistentes se omiten
+func (s *KVStore) Write(batch *KVBatch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return fmt.Errorf("almacenamiento cerrado")
+	}
+	if s.readOnly {
+		return fmt.Errorf("%s abierto en solo lectura", s.path)
+	}
+
+	ops := make([]kvOp, 0, len(batch.ops))
+	for _, op := range batch.ops {
+		current, exists := s.index[op.key]
+		if op.op == kvOpDelete && !exists {
+			continue
+		}
+		if op.op == kvOpPut && exists && s.unchangedLocked(current, op.value) {
+			continue
+		}
+		ops = append(ops, op)
+	}
+	if len(ops) == 0 {
+		return nil
+	}
+
+	record := encodeKVRecord(ops)
+	if _, err := s.file.WriteAt(record, s.size); err != nil {
+		// Un registro a medias se descarta al reabrir; aquí se recorta ya
+		s.file.Truncate(s.size)
+		return err
+	}
+	if err := s.file.Sync(); err != nil {
+		return err
+	}
+
+	s.applyIndex(ops, s.size+kvRecordHeader)
+	s.size += int64(len(record))
+
+	if s.size > kvCompactMinBytes && s.size > 2*s.liveBytes {
+		if err := s.compactLocked(); err != nil {
+			log.Printf("[DB] Error compactando %s: %v", s.path, err)
+		}
+	}
+	return nil
+}
+
+// El valor guardado es igual a value; el CRC solo evita leer si difieren
+func (s *KVStore) unchangedLocked(location kvLocation, value []byte) bool {
+	if location.size != uint32(len(value)) || location.crc != crc32.Checksum(value, kvCRCTable) {
+		return false
+	}
+	stored, err := s.readLocked(location)
+	return err == nil && bytes.Equal(stored, value)
+}
+
+func (s *KVStore) Put(key string, value []byte) error {
+	batch := &KVBatch{}
+	batch.Put(key, value)
+	return s.Write(batch)
+}
+
+func (s *KVStore) Delete(key string) error {
+	batch := &KVBatch{}
+	batch.Delete(key)
+	return s.Write(batch)
+}
+
+func (s *KVStore) Get(key string) ([]byte, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	location, exists := s.index[key]
+	if !exists {
+		return nil, false, nil
+	}
+	value, err := s.readLocked(location)
+	return value, err == nil, err
+}
+
+func (s *KVStore) readLocked(location kvLocation) ([]byte, error) {
+	value := make([]byte, location.size)
+	if _, err := s.file.ReadAt(value, location.offset); err != nil {
+		return nil, err
+	}
+	if crc32.Checksum(value, kvCRCTable) != location.crc {
+		return nil, fmt.Errorf("valor corrupto en offset %d", location.offset)
+	}
+	return value, nil
+}
+
+// Claves con el prefijo, ordenadas
+func (s *KVStore) Keys(prefix string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]string, 0)
+	for key := range s.index {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Recorrer en orden los valores con el prefijo
+func (s *KVStore) Scan(prefix string, fn func(key string, value []byte) error) error {
+	for _, key := range s.Keys(prefix) {
+		value, exists, err := s.Get(key)
+		if err != nil {
+			return fmt.Errorf("%s: %v", key, err)
+		}
+		if !exists {
+			continue // borrada durante el recorrido
+		}
+		if err := fn(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *KVStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.index)
+}
+
+// Compactar el log aunque no supere el umbral
+func (s *KVStore) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.readOnly {
+		return fmt.Errorf("%s abierto en solo lectura", s.path)
+	}
+	return s.compactLocked()
+}
+
+func (s *KVStore) compactLocked() error {
+	before := s.size
+	tmpPath := s.path + ".compact"
+	tmp, index, size, err := s.writeLiveTo(tmpPath)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	syncDir(filepath.Dir(s.path))
+
+	s.file.Close()
+	s.file = tmp
+	s.index = index
+	s.size = size
+
+	log.Printf("[DB] Log compactado: %d → %d bytes, %d claves", before, size, len(index))
+	return nil
+}
+
+// Copiar los valores vivos a un archivo nuevo ya sincronizado; devuelve el
+// archivo abierto y su índice. Requiere al menos el bloqueo de lectura.
+func (s *KVStore) writeLiveTo(path string) (*os.File, map[string]kvLocation, int64, error) {
+	tmp, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	fail := func(err error) (*os.File, map[string]kvLocation, int64, error) {
+		tmp.Close()
+		os.Remove(path)
+		return nil, nil, 0, err
+	}
+
+	keys := make([]string, 0, len(s.index))
+	for key := range s.index {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	index := make(map[string]kvLocation, len(keys))
+	size := int64(0)
+	ops := make([]kvOp, 0)
+	pending := 0
+	flush := func() error {
+		if len(ops) == 0 {
+			return nil
+		}
+		record := encodeKVRecord(ops)
+		if _, err := tmp.Write(record); err != nil {
+			return err
+		}
+		pos := size + kvRecordHeader
+		for _, op := range ops {
+			valueOffset := pos + kvOpHeader + int64(len(op.key))
+			index[op.key] = kvLocation{offset: valueOffset, size: uint32(len(op.value)), crc: s.index[op.key].crc}
+			pos = valueOffset + int64(len(op.value))
+		}
+		size += int64(len(record))
+		ops = ops[:0]
+		pending = 0
+		return nil
+	}
+
+	for _, key := range keys {
+		value, err := s.readLocked(s.index[key])
+		if err != nil {
+			return fail(fmt.Errorf("%s: %v", key, err))
+		}
+		ops = append(ops, kvOp{kvOpPut, key, value})
+		pending += kvOpHeader + len(key) + len(value)
+		if pending >= kvCompactBatch {
+			if err := flush(); err != nil {
+				return fail(err)
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return fail(err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fail(err)
+	}
+	return tmp, index, size, nil
+}
+
+// Escribir una copia compacta del contenido actual en path (temporal,
+// fsync y rename), sin bloquear las lecturas
+func (s *KVStore) SnapshotTo(path string) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.file == nil {
+		return fmt.Errorf("almacenamiento cerrado")
+	}
+	tmpPath := path + ".tmp"
+	tmp, _, _, err := s.writeLiveTo(tmpPath)
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	syncDir(filepath.Dir(path))
+	return nil
+}
+
+// Reemplazar todo el contenido por el de otro log (p. ej. un snapshot)
+func (s *KVStore) ReplaceWith(path string) error {
+	source, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tmpPath := s.path + ".restore"
+	tmp, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tmp, source)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, s.path)
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	syncDir(filepath.Dir(s.path))
+
+	if s.file != nil {
+		s.file.Close()
+	}
+	s.file = tmp
+	s.index = make(map[string]kvLocation)
+	s.liveBytes = 0
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return s.replay()
+}
+
+// Sincronizar un directorio para que un rename sobreviva a un corte
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
+
+func (s *KVStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// PRUEBAS DEL LOG - Reapertura, finales dañados y compactación
+// ============================================================================
+// kvstore.go no depende del resto del coordinador:
+//
+//	go test kvstore.go kvstore_test.go
+
+func openTestKVStore(t *testing.T, path string) *KVStore {
+	t.Helper()
+	store, err := OpenKVStore(path)
+	if err != nil {
+		t.Fatalf("abriendo %s: %v", path, err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func expectValue(t *testing.T, store *KVStore, key, want string) {
+	t.Helper()
+	value, exists, err := store.Get(key)
+	if err != nil || !exists || string(value) != want {
+		t.Fatalf("%s: %q, %v, %v; se esperaba %q", key, value, exists, err, want)
+	}
+}
+
+func expectMissing(t *testing.T, store *KVStore, key string) {
+	t.Helper()
+	if value, exists, err := store.Get(key); exists || err != nil {
+		t.Fatalf("%s: %q, %v; se esperaba que no existiera", key, value, err)
+	}
+}
+
+func fileSize(t *testing.T, path string) int64 {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.Size()
+}
+
+func TestKVStoreReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db", "recsys.log")
+	store := openTestKVStore(t, path)
+
+	batch := &KVBatch{}
+	batch.Put("users/1", []byte("ana"))
+	batch.Put("users/2", []byte("luis"))
+	batch.Put("movies/1", []byte("Toy Story"))
+	if err := store.Write(batch); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put("users/1", []byte("ana maría")); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete("users/2"); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	store = openTestKVStore(t, path)
+	expectValue(t, store, "users/1", "ana maría")
+	expectValue(t, store, "movies/1", "Toy Story")
+	expectMissing(t, store, "users/2")
+	if keys := store.Keys("users/"); len(keys) != 1 || keys[0] != "users/1" {
+		t.Fatalf("Keys: %v", keys)
+	}
+}
+
+// Un lote cortado a mitad se descarta entero y el archivo se recorta
+func TestKVStoreTornTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recsys.log")
+	store := openTestKVStore(t, path)
+	if err := store.Put("a", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	valid := fileSize(t, path)
+
+	batch := &KVBatch{}
+	batch.Put("b", []byte("2"))
+	batch.Put("c", []byte("3"))
+	if err := store.Write(batch); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, cut := range []int64{1, kvRecordHeader - 1, kvRecordHeader + 3} {
+		if err := os.WriteFile(path, data[:valid+cut], 0644); err != nil {
+			t.Fatal(err)
+		}
+		store = openTestKVStore(t, path)
+		expectValue(t, store, "a", "1")
+		expectMissing(t, store, "b")
+		expectMissing(t, store, "c")
+		store.Close()
+		if size := fileSize(t, path); size != valid {
+			t.Fatalf("corte %d: el log mide %d bytes, se esperaban %d", cut, size, valid)
+		}
+	}
+}
+
+func TestKVStoreCorruptTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recsys.log")
+	store := openTestKVStore(t, path)
+	if err := store.Put("a", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	valid := fileSize(t, path)
+	if err := store.Put("b", []byte("2")); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Largo de 4 GiB en la cabecera: se descarta sin reservar memoria
+	huge := append([]byte(nil), data...)
+	binary.LittleEndian.PutUint32(huge[valid+4:], 0xFFFFFFFF)
+	// Un byte del valor cambiado: el CRC no coincide
+	flipped := append([]byte(nil), data...)
+	flipped[len(flipped)-1] ^= 0xFF
+
+	for name, content := range map[string][]byte{"largo": huge, "crc": flipped} {
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+		store = openTestKVStore(t, path)
+		expectValue(t, store, "a", "1")
+		expectMissing(t, store, "b")
+		store.Close()
+		if size := fileSize(t, path); size != valid {
+			t.Fatalf("%s: el log mide %d bytes, se esperaban %d", name, size, valid)
+		}
+	}
+}
+
+func TestKVStoreSkipsUnchangedPuts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recsys.log")
+	store := openTestKVStore(t, path)
+	if err := store.Put("a", []byte("abcd")); err != nil {
+		t.Fatal(err)
+	}
+	size := fileSize(t, path)
+	if err := store.Put("a", []byte("abcd")); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete("missing"); err != nil {
+		t.Fatal(err)
+	}
+	if fileSize(t, path) != size {
+		t.Fatal("un put sin cambios o un delete sin clave escribió en el log")
+	}
+
+	// Mismo tamaño y CRC (colisión simulada en el índice): se compara el valor
+	store.mu.Lock()
+	location := store.index["a"]
+	location.crc = crc32.Checksum([]byte("wxyz"), kvCRCTable)
+	store.index["a"] = location
+	store.mu.Unlock()
+	if err := store.Put("a", []byte("wxyz")); err != nil {
+		t.Fatal(err)
+	}
+	expectValue(t, store, "a", "wxyz")
+}
+
+func TestKVStoreCompact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recsys.log")
+	store := openTestKVStore(t, path)
+	value := bytes.Repeat([]byte("x"), 1024)
+	for i := 0; i < 50; i++ {
+		value[0] = byte(i)
+		if err := store.Put("hot", value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Put("cold", []byte("frío")); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put("gone", []byte("borrado")); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete("gone"); err != nil {
+		t.Fatal(err)
+	}
+	before := fileSize(t, path)
+
+	if err := store.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if after := fileSize(t, path); after >= before/10 {
+		t.Fatalf("compactación: %d → %d bytes", before, after)
+	}
+	expectValue(t, store, "hot", string(value))
+
+	// Escrituras después de compactar y reapertura sobre el archivo nuevo
+	if err := store.Put("new", []byte("nuevo")); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	store = openTestKVStore(t, path)
+	expectValue(t, store, "hot", string(value))
+	expectValue(t, store, "cold", "frío")
+	expectValue(t, store, "new", "nuevo")
+	expectMissing(t, store, "gone")
+	if store.Len() != 3 {
+		t.Fatalf("Len: %d", store.Len())
+	}
+}
+
+func TestKVStoreSnapshotAndReplace(t *testing.T) {
+	dir := t.TempDir()
+	store := openTestKVStore(t, filepath.Join(dir, "recsys.log"))
+	if err := store.Put("a", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	snapshot := filepath.Join(dir, "snapshot.log")
+	if err := store.SnapshotTo(snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put("a", []byte("2")); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put("b", []byte("3")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.ReplaceWith(snapshot); err != nil {
+		t.Fatal(err)
+	}
+	expectValue(t, store, "a", "1")
+	expectMissing(t, store, "b")
+	if err := store.Put("c", []byte("4")); err != nil {
+		t.Fatal(err)
+	}
+	expectValue(t, store, "c", "4")
+}
+
+// En solo lectura un final dañado se ignora sin recortar el archivo
+func TestKVStoreReadOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.log")
+	store := openTestKVStore(t, path)
+	if err := store.Put("a", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{1, 2, 3})
+	f.Close()
+	torn := fileSize(t, path)
+
+	readOnly, err := OpenKVStoreReadOnly(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer readOnly.Close()
+	expectValue(t, readOnly, "a", "1")
+	if err := readOnly.Put("b", []byte("2")); err == nil {
+		t.Fatal("se escribió en un log de solo lectura")
+	}
+	if size := fileSize(t, path); size != torn {
+		t.Fatalf("el archivo mide %d bytes, se esperaban %d", size, torn)
+	}
+
+	if _, err := OpenKVStoreReadOnly(filepath.Join(t.TempDir(), "missing.log")); err == nil {
+		t.Fatal("se abrió un log inexistente")
+	}
+}
@@ -24,6 +24,7 @@ type SystemMetrics struct {
 	TotalRequests int
 	CacheHits     int
 	CacheMisses   int
+	Coalesced     int // solicitudes que esperaron un cálculo idéntico en curso
 	ActiveNodes   int
 
 	mu        sync.RWMutex
@@ -108,6 +109,13 @@ func (m *SystemMetrics) RecordCacheMiss() {
 	m.CacheMisses++
 }
 
+// Registrar una solicitud servida por un cálculo en curso
+func (m *SystemMetrics) RecordCoalescedRequest() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Coalesced++
+}
+
 // Obtener métricas de escenario concurrente
 func (m *SystemMetrics) GetConcurrentMetrics() MetricsScenario {
 	m.mu.RLock()
@@ -212,6 +220,13 @@ func (m *SystemMetrics) GetCacheHitRate() float64 {
 	return float64(m.CacheHits) / float64(total) * 100
 }
 
+// Obtener solicitudes coalescidas
+func (m *SystemMetrics) GetCoalescedRequests() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.Coalesced
+}
+
 // Generar reporte de rendimiento
 func (m *SystemMetrics) GeneratePerformanceReport() PerformanceReport {
 	m.mu.RLock()
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ESTADÍSTICAS POR PELÍCULA - Índice precalculado
+// ============================================================================
+// Agregados por película construidos durante la carga y mantenidos con cada
+// rating nuevo, para que GetMovie no recorra los ratings de todos los usuarios.
+// El ranking por promedio bayesiano se ordena al pedirlo, sobre una copia y
+// fuera del lock de los contadores, y se reutiliza hasta que acumula
+// rankingRebuildChanges ratings nuevos o pasa rankingMaxAge con cambios.
+const (
+	histogramBuckets      = 10 // medias estrellas de 0.5 a 5.0
+	rankingRebuildChanges = 1000
+	rankingMaxAge         = 30 * time.Second
+)
+
+type MovieStats struct {
+	Count      int
+	Sum        float64
+	SumSquares float64
+	Histogram  [histogramBuckets]int
+}
+
+type MovieStatsIndex struct {
+	stats       map[int]*MovieStats
+	globalSum   float64
+	globalCount int
+	version     uint64 // cambia con cada rating
+	generation  uint64 // cambia al intercambiar el índice (recarga)
+	mu          sync.RWMutex
+
+	ranking           []int // movieIDs por promedio bayesiano descendente
+	rankingVersion    uint64
+	rankingGeneration uint64
+	rankingBuilt      time.Time
+	rankingMu         sync.Mutex
+}
+
+func NewMovieStatsIndex() *MovieStatsIndex {
+	return &MovieStatsIndex{
+		stats: make(map[int]*MovieStats),
+	}
+}
+
+// Intercambiar el contenido con otro índice (recarga del dataset)
+func (idx *MovieStatsIndex) swap(other *MovieStatsIndex) {
+	idx.mu.Lock()
+	other.mu.Lock()
+	idx.stats, other.stats = other.stats, idx.stats
+	idx.globalSum, other.globalSum = other.globalSum, idx.globalSum
+	idx.globalCount, other.globalCount = other.globalCount, idx.globalCount
+	idx.version++
+	other.version++
+	idx.generation++
+	other.generation++
+	other.mu.Unlock()
+	idx.mu.Unlock()
+}
+
+// Bucket del histograma para un rating (0.5 -> 0, 5.0 -> 9)
+func histogramBucket(rating float64) int {
+	bucket := int(math.Round(rating*2)) - 1
+	if bucket < 0 {
+		bucket = 0
+	}
+	if bucket >= histogramBuckets {
+		bucket = histogramBuckets - 1
+	}
+	return bucket
+}
+
+// Registrar un rating nuevo
+func (idx *MovieStatsIndex) Record(movieID int, rating float64) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	s := idx.stats[movieID]
+	if s == nil {
+		s = &MovieStats{}
+		idx.stats[movieID] = s
+	}
+	s.Count++
+	s.Sum += rating
+	s.SumSquares += rating * rating
+	s.Histogram[histogramBucket(rating)]++
+
+	idx.globalCount++
+	idx.globalSum += rating
+	idx.version++
+}
+
+// Reemplazar un rating existente (el usuario cambió su valoración)
+func (idx *MovieStatsIndex) Replace(movieID int, oldRating, newRating float64) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	s := idx.stats[movieID]
+	if s == nil || s.Count == 0 {
+		return
+	}
+	s.Sum += newRating - oldRating
+	s.SumSquares += newRating*newRating - oldRating*oldRating
+	s.Histogram[histogramBucket(oldRating)]--
+	s.Histogram[histogramBucket(newRating)]++
+
+	idx.globalSum += newRating - oldRating
+	idx.version++
+}
+
+// Copia de las estadísticas de una película
+func (idx *MovieStatsIndex) Get(movieID int) (MovieStats, bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	s, exists := idx.stats[movieID]
+	if !exists {
+		return MovieStats{}, false
+	}
+	return *s, true
+}
+
+// Promedio global de todos los ratings
+func (idx *MovieStatsIndex) GlobalAverage() float64 {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return idx.globalAverage()
+}
+
+func (idx *MovieStatsIndex) globalAverage() float64 {
+	if idx.globalCount == 0 {
+		return 0
+	}
+	return idx.globalSum / float64(idx.globalCount)
+}
+
+// Promedio bayesiano histórico de una película
+func (idx *MovieStatsIndex) BayesianAverage(movieID int) (float64, bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	s, exists := idx.stats[movieID]
+	if !exists {
+		return 0, false
+	}
+	return bayesianAverage(s.Sum, s.Count, idx.globalAverage()), true
+}
+
+// Películas ordenadas por promedio bayesiano histórico (empate: menor ID).
+// Puede ir hasta rankingRebuildChanges ratings o rankingMaxAge por detrás de
+// los contadores. El slice es compartido y no se modifica.
+func (idx *MovieStatsIndex) Ranking() []int {
+	idx.rankingMu.Lock()
+	defer idx.rankingMu.Unlock()
+
+	idx.mu.RLock()
+	version, generation := idx.version, idx.generation
+	idx.mu.RUnlock()
+
+	if idx.ranking != nil && idx.rankingGeneration == generation {
+		changes := version - idx.rankingVersion
+		if changes == 0 || (changes < rankingRebuildChanges && time.Since(idx.rankingBuilt) < rankingMaxAge) {
+			return idx.ranking
+		}
+	}
+
+	// Copiar los promedios bajo el lock de lectura; Record no espera al sort
+	idx.mu.RLock()
+	version, generation = idx.version, idx.generation
+	prior := idx.globalAverage()
+	averages := make(map[int]float64, len(idx.stats))
+	ranking := make([]int, 0, len(idx.stats))
+	for movieID, s := range idx.stats {
+		if s.Count == 0 {
+			continue
+		}
+		averages[movieID] = bayesianAverage(s.Sum, s.Count, prior)
+		ranking = append(ranking, movieID)
+	}
+	idx.mu.RUnlock()
+
+	sort.Slice(ranking, func(i, j int) bool {
+		a, b := averages[ranking[i]], averages[ranking[j]]
+		if a != b {
+			return a > b
+		}
+		return ranking[i] < ranking[j]
+	})
+
+	idx.ranking = ranking
+	idx.rankingVersion = version
+	idx.rankingGeneration = generation
+	idx.rankingBuilt = time.Now()
+	return ranking
+}
+
+func (s MovieStats) Average() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Sum / float64(s.Count)
+}
+
+func (s MovieStats) StdDev() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	mean := s.Average()
+	variance := s.SumSquares/float64(s.Count) - mean*mean
+	if variance < 0 {
+		variance = 0
+	}
+	return math.Sqrt(variance)
+}
+
+// Percentil (0-100) aproximado a la media estrella a partir del histograma
+func (s MovieStats) Percentile(p float64) float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	target := p / 100 * float64(s.Count)
+	cumulative := 0
+	for bucket, count := range s.Histogram {
+		cumulative += count
+		if float64(cumulative) >= target && count > 0 {
+			return float64(bucket+1) / 2
+		}
+	}
+	return 5.0
+}
+
+// Histograma con etiquetas "0.5" ... "5.0"
+func (s MovieStats) HistogramMap() map[string]int {
+	result := make(map[string]int, histogramBuckets)
+	for bucket, count := range s.Histogram {
+		result[fmt.Sprintf("%.1f", float64(bucket+1)/2)] = count
+	}
+	return result
+}
@@ -9,8 +9,13 @@ import (
 )
 
 // Script para dividir ratings.csv en 8 particiones
+// Uso: go run partition_data.go [directorio] (default data_25M)
 func main() {
-	inputFile := "data_25M/ratings.csv"
+	dir := "data_25M"
+	if len(os.Args) > 1 {
+		dir = os.Args[1]
+	}
+	inputFile := filepath.Join(dir, "ratings.csv")
 	numPartitions := 8
 
 	fmt.Printf("Particionando %s en %d partes...\n", inputFile, numPartitions)
@@ -52,7 +57,7 @@ func main() {
 	files := make([]*os.File, numPartitions)
 
 	for i := 0; i < numPartitions; i++ {
-		filename := filepath.Join("data_25M", fmt.Sprintf("ratings_part%d.csv", i+1))
+		filename := filepath.Join(dir, fmt.Sprintf("ratings_part%d.csv", i+1))
 		f, err := os.Create(filename)
 		if err != nil {
 			log.Fatalf("Error creando partición %d: %v", i+1, err)
@@ -108,7 +113,7 @@ func main() {
 	// Mostrar resumen
 	fmt.Println("\nArchivos creados:")
 	for i := 0; i < numPartitions; i++ {
-		filename := filepath.Join("data_25M", fmt.Sprintf("ratings_part%d.csv", i+1))
+		filename := filepath.Join(dir, fmt.Sprintf("ratings_part%d.csv", i+1))
 		info, _ := os.Stat(filename)
 		fmt.Printf("  - %s (%.2f MB)\n", filename, float64(info.Size())/1024/1024)
 	}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CARGA DE RATINGS - Lector compartido por coordinador, workers y validate
+// ============================================================================
+// Lee un CSV userId,movieId,rating[,timestamp] y clasifica cada fila. Las
+// filas mal formadas o con rating fuera de (0, 5] se descartan; el resto de
+// problemas (rating que no es múltiplo de 0.5, par usuario-película repetido,
+// película desconocida, timestamp ausente, inválido, futuro o anterior a
+// MovieLens) son avisos y la fila se carga. En modo strict el primer problema
+// de cualquier tipo aborta la carga con su número de línea, antes de aplicar
+// la fila. Los ratings en vivo pasan por ratingInRange y halfStar.
+const (
+	LoadLenient = "lenient"
+	LoadStrict  = "strict"
+)
+
+// Tipos de problema
+const (
+	IssueMalformed        = "malformed"
+	IssueOutOfRange       = "out_of_range"
+	IssueNonHalfStar      = "non_half_star"
+	IssueDuplicate        = "duplicate"
+	IssueUnknownMovie     = "unknown_movie"
+	IssueMissingTimestamp = "missing_timestamp"
+	IssueInvalidTimestamp = "invalid_timestamp"
+	IssueFutureTimestamp  = "future_timestamp"
+	IssueEarlyTimestamp   = "early_timestamp"
+)
+
+const (
+	earliestRatingTimestamp = 788918400 // 1995-01-01, inicio de MovieLens
+	issueSamples            = 5         // líneas de ejemplo por tipo
+	ratingsReadBufferKiB    = 1024
+)
+
+type RatingRow struct {
+	UserID    int
+	MovieID   int
+	Rating    float64
+	Timestamp int64 // 0 si falta o es inválido
+}
+
+type LoadOptions struct {
+	Mode       string         // lenient (default) o strict
+	KnownMovie func(int) bool // nil: no se comprueban las películas
+	Now        time.Time      // referencia para timestamps futuros (default: ahora)
+}
+
+// Resumen de ratings por usuario o por película
+type DistributionSummary struct {
+	Count  int     `json:"count"`
+	Min    int     `json:"min"`
+	Max    int     `json:"max"`
+	Mean   float64 `json:"mean"`
+	Median float64 `json:"median"`
+	P90    int     `json:"p90"`
+	Single int     `json:"single"` // con un solo rating
+}
+
+type LoadReport struct {
+	Path         string              `json:"path"`
+	Rows         int                 `json:"rows"`
+	Loaded       int                 `json:"loaded"`
+	Rejected     int                 `json:"rejected"`
+	AbortedAt    int                 `json:"aborted_at,omitempty"` // strict: línea del primer problema
+	Issues       map[string]int      `json:"issues"`
+	Samples      map[string][]int    `json:"samples,omitempty"` // líneas de ejemplo
+	Histogram    map[string]int      `json:"histogram"`         // ratings cargados por valor
+	MinTimestamp int64               `json:"min_timestamp,omitempty"`
+	MaxTimestamp int64               `json:"max_timestamp,omitempty"`
+	Users        DistributionSummary `json:"users"`
+	Movies       DistributionSummary `json:"movies"`
+}
+
+// Rating dentro de la escala de MovieLens (0, 5]
+func ratingInRange(rating float64) bool {
+	return rating > 0 && rating <= 5
+}
+
+// Rating múltiplo de media estrella
+func halfStar(rating float64) bool {
+	return rating*2 == math.Trunc(rating*2)
+}
+
+// Leer ratings de path llamando a add por cada fila aceptada, después de
+// clasificarla. seen indica si el par (usuario, película) ya estaba cargado;
+// add recibe ese mismo valor para reemplazar el rating sin volver a contarlo.
+func LoadRatingsCSV(path string, opts LoadOptions, seen func(row RatingRow) bool, add func(row RatingRow, duplicate bool)) (*LoadReport, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+	strict := opts.Mode == LoadStrict
+	maxTimestamp := opts.Now.Add(24 * time.Hour).Unix()
+
+	report := &LoadReport{
+		Path:      path,
+		Issues:    make(map[string]int),
+		Samples:   make(map[string][]int),
+		Histogram: make(map[string]int),
+	}
+	userCounts := make(map[int]int)
+	movieCounts := make(map[int]int)
+	histogram := make(map[float64]int)
+	warnings := make([]string, 0, 4)
+
+	reader := csv.NewReader(bufio.NewReaderSize(file, ratingsReadBufferKiB*1024))
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	if _, err := reader.Read(); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("%s: encabezado ilegible: %v", path, err)
+	}
+
+	line := 1
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		report.Rows++
+
+		var row RatingRow
+		issue := ""
+		if err != nil || len(record) < 3 {
+			issue = IssueMalformed
+		} else {
+			var err1, err2, err3 error
+			row.UserID, err1 = strconv.Atoi(record[0])
+			row.MovieID, err2 = strconv.Atoi(record[1])
+			row.Rating, err3 = strconv.ParseFloat(record[2], 64)
+			if err1 != nil || err2 != nil || err3 != nil {
+				issue = IssueMalformed
+			} else if !ratingInRange(row.Rating) {
+				issue = IssueOutOfRange
+			}
+		}
+		if issue != "" {
+			report.Rejected++
+			if err := report.note(issue, line, strict); err != nil {
+				return report, err
+			}
+			continue
+		}
+
+		// Avisos: la fila se carga igual
+		warnings = warnings[:0]
+		if !halfStar(row.Rating) {
+			warnings = append(warnings, IssueNonHalfStar)
+		}
+		if opts.KnownMovie != nil && !opts.KnownMovie(row.MovieID) {
+			warnings = append(warnings, IssueUnknownMovie)
+		}
+		switch {
+		case len(record) < 4 || strings.TrimSpace(record[3]) == "":
+			warnings = append(warnings, IssueMissingTimestamp)
+		default:
+			timestamp, err := strconv.ParseInt(record[3], 10, 64)
+			switch {
+			case err != nil || timestamp <= 0:
+				warnings = append(warnings, IssueInvalidTimestamp)
+			case timestamp > maxTimestamp:
+				warnings = append(warnings, IssueFutureTimestamp)
+				row.Timestamp = timestamp
+			case timestamp < earliestRatingTimestamp:
+				warnings = append(warnings, IssueEarlyTimestamp)
+				row.Timestamp = timestamp
+			default:
+				row.Timestamp = timestamp
+			}
+		}
+
+		duplicate := seen(row)
+		if duplicate {
+			warnings = append(warnings, IssueDuplicate)
+		}
+		for _, warning := range warnings {
+			if err := report.note(warning, line, strict); err != nil {
+				return report, err
+			}
+		}
+
+		add(row, duplicate)
+		if !duplicate {
+			userCounts[row.UserID]++
+			movieCounts[row.MovieID]++
+		}
+
+		report.Loaded++
+		histogram[row.Rating]++
+		if row.Timestamp > 0 {
+			if report.MinTimestamp == 0 || row.Timestamp < report.MinTimestamp {
+				report.MinTimestamp = row.Timestamp
+			}
+			if row.Timestamp > report.MaxTimestamp {
+				report.MaxTimestamp = row.Timestamp
+			}
+		}
+	}
+
+	for rating, count := range histogram {
+		report.Histogram[strconv.FormatFloat(rating, 'f', -1, 64)] += count
+	}
+	report.Users = summarizeCounts(userCounts)
+	report.Movies = summarizeCounts(movieCounts)
+	return report, nil
+}
+
+// Contar un problema; en strict se convierte en error
+func (r *LoadReport) note(issue string, line int, strict bool) error {
+	r.Issues[issue]++
+	if len(r.Samples[issue]) < issueSamples {
+		r.Samples[issue] = append(r.Samples[issue], line)
+	}
+	if strict {
+		r.AbortedAt = line
+		return fmt.Errorf("%s línea %d: %s", r.Path, line, issue)
+	}
+	return nil
+}
+
+// Total de avisos y descartes
+func (r *LoadReport) IssueCount() int {
+	total := 0
+	for _, count := range r.Issues {
+		total += count
+	}
+	return total
+}
+
+// Una línea para el log: filas, cargadas, descartadas y problemas por tipo
+func (r *LoadReport) Summary() string {
+	kinds := make([]string, 0, len(r.Issues))
+	for kind := range r.Issues {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	summary := fmt.Sprintf("%d filas, %d cargadas, %d descartadas", r.Rows, r.Loaded, r.Rejected)
+	for _, kind := range kinds {
+		summary += fmt.Sprintf(", %s=%d", kind, r.Issues[kind])
+	}
+	return summary
+}
+
+func summarizeCounts(counts map[int]int) DistributionSummary {
+	if len(counts) == 0 {
+		return DistributionSummary{}
+	}
+	values := make([]int, 0, len(counts))
+	total := 0
+	single := 0
+	for _, count := range counts {
+		values = append(values, count)
+		total += count
+		if count == 1 {
+			single++
+		}
+	}
+	sort.Ints(values)
+
+	n := len(values)
+	median := float64(values[n/2])
+	if n%2 == 0 {
+		median = float64(values[n/2-1]+values[n/2]) / 2
+	}
+	return DistributionSummary{
+		Count:  n,
+		Min:    values[0],
+		Max:    values[n-1],
+		Mean:   float64(total) / float64(n),
+		Median: median,
+		P90:    values[(n*9)/10],
+		Single: single,
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// PRUEBAS DEL LECTOR DE RATINGS - Problemas, modo strict y repetidos
+// ============================================================================
+// ratings_loader.go no depende del resto del coordinador:
+//
+//	go test ratings_loader.go ratings_loader_test.go
+
+var testLoadNow = time.Unix(1600000000, 0) // 2020-09-13
+
+func writeRatingsCSV(t *testing.T, rows ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ratings.csv")
+	data := "userId,movieId,rating,timestamp\n" + strings.Join(rows, "\n") + "\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+type loadedRow struct {
+	row       RatingRow
+	duplicate bool
+}
+
+// Cargar con un seen como el del coordinador: pares ya pasados a add
+func loadTestRatings(t *testing.T, path string, mode string) (*LoadReport, []loadedRow, error) {
+	t.Helper()
+	pairs := make(map[[2]int]bool)
+	var added []loadedRow
+	opts := LoadOptions{
+		Mode:       mode,
+		KnownMovie: func(movieID int) bool { return movieID < 100 },
+		Now:        testLoadNow,
+	}
+	report, err := LoadRatingsCSV(path, opts, func(row RatingRow) bool {
+		return pairs[[2]int{row.UserID, row.MovieID}]
+	}, func(row RatingRow, duplicate bool) {
+		pairs[[2]int{row.UserID, row.MovieID}] = true
+		added = append(added, loadedRow{row, duplicate})
+	})
+	return report, added, err
+}
+
+func TestLoadRatingsCSVIssues(t *testing.T) {
+	tests := []struct {
+		name     string
+		row      string
+		issue    string
+		rejected bool
+	}{
+		{"válida", "1,10,4.0,1000000000", "", false},
+		{"campos de menos", "1,10", IssueMalformed, true},
+		{"usuario no numérico", "x,10,4.0,1000000000", IssueMalformed, true},
+		{"rating no numérico", "1,10,muy buena,1000000000", IssueMalformed, true},
+		{"rating cero", "1,10,0,1000000000", IssueOutOfRange, true},
+		{"rating sobre 5", "1,10,5.5,1000000000", IssueOutOfRange, true},
+		{"no es media estrella", "1,10,3.7,1000000000", IssueNonHalfStar, false},
+		{"película desconocida", "1,500,4.0,1000000000", IssueUnknownMovie, false},
+		{"sin timestamp", "1,10,4.0", IssueMissingTimestamp, false},
+		{"timestamp vacío", "1,10,4.0, ", IssueMissingTimestamp, false},
+		{"timestamp inválido", "1,10,4.0,ayer", IssueInvalidTimestamp, false},
+		{"timestamp negativo", "1,10,4.0,-5", IssueInvalidTimestamp, false},
+		{"timestamp futuro", "1,10,4.0,1700000000", IssueFutureTimestamp, false},
+		{"timestamp anterior a MovieLens", "1,10,4.0,700000000", IssueEarlyTimestamp, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeRatingsCSV(t, tt.row)
+
+			report, added, err := loadTestRatings(t, path, LoadLenient)
+			if err != nil {
+				t.Fatalf("lenient: %v", err)
+			}
+			wantIssues := map[string]int{}
+			if tt.issue != "" {
+				wantIssues[tt.issue] = 1
+			}
+			if !reflect.DeepEqual(report.Issues, wantIssues) {
+				t.Fatalf("problemas: %v, se esperaba %v", report.Issues, wantIssues)
+			}
+			wantLoaded := 1
+			if tt.rejected {
+				wantLoaded = 0
+			}
+			if report.Rows != 1 || report.Loaded != wantLoaded || len(added) != wantLoaded || report.Rejected != 1-wantLoaded {
+				t.Fatalf("filas %d, cargadas %d (add %d), descartadas %d", report.Rows, report.Loaded, len(added), report.Rejected)
+			}
+
+			// En strict cualquier problema aborta en su línea, antes de add
+			report, added, err = loadTestRatings(t, path, LoadStrict)
+			if tt.issue == "" {
+				if err != nil || len(added) != 1 {
+					t.Fatalf("strict: %v, %d filas", err, len(added))
+				}
+				return
+			}
+			if err == nil || report.AbortedAt != 2 || len(added) != 0 {
+				t.Fatalf("strict: error %v, línea %d, %d filas aplicadas", err, report.AbortedAt, len(added))
+			}
+		})
+	}
+}
+
+func TestLoadRatingsCSVStrictAbortsBeforeAdd(t *testing.T) {
+	path := writeRatingsCSV(t,
+		"1,10,4.0,1000000000",
+		"1,11,3.5,1000000000",
+		"2,10,4.5,1000000000",
+		"2,10,2.0,1000000001", // línea 5: repetida
+		"3,12,5.0,1000000000",
+	)
+
+	report, added, err := loadTestRatings(t, path, LoadStrict)
+	if err == nil {
+		t.Fatal("strict no abortó con un par repetido")
+	}
+	if report.AbortedAt != 5 || !strings.Contains(err.Error(), "línea 5") {
+		t.Fatalf("abortó en la línea %d: %v", report.AbortedAt, err)
+	}
+	if len(added) != 3 || report.Loaded != 3 {
+		t.Fatalf("%d filas aplicadas (%d cargadas), se esperaban las 3 anteriores", len(added), report.Loaded)
+	}
+	for _, r := range added {
+		if r.row.UserID == 2 && r.row.Rating != 4.5 {
+			t.Fatalf("se aplicó la fila repetida: %+v", r.row)
+		}
+	}
+}
+
+func TestLoadRatingsCSVDuplicates(t *testing.T) {
+	path := writeRatingsCSV(t,
+		"1,10,4.0,1000000000",
+		"1,10,2.0,1000000001",
+		"1,10,3.0,1000000002",
+		"1,11,3.5,1000000000",
+		"2,10,4.5,1000000000",
+	)
+
+	report, added, err := loadTestRatings(t, path, LoadLenient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Issues[IssueDuplicate] != 2 || report.Loaded != 5 {
+		t.Fatalf("repetidos %d, cargadas %d", report.Issues[IssueDuplicate], report.Loaded)
+	}
+
+	// add recibe cada fila y si repite un par ya cargado
+	wantDuplicate := []bool{false, true, true, false, false}
+	for i, r := range added {
+		if r.duplicate != wantDuplicate[i] {
+			t.Fatalf("fila %d: duplicate=%v", i, r.duplicate)
+		}
+	}
+
+	// Los repetidos no cuentan en las distribuciones
+	if report.Users.Count != 2 || report.Users.Max != 2 || report.Users.Min != 1 {
+		t.Fatalf("usuarios: %+v", report.Users)
+	}
+	if report.Movies.Count != 2 || report.Movies.Max != 2 || report.Movies.Single != 1 {
+		t.Fatalf("películas: %+v", report.Movies)
+	}
+	if report.MinTimestamp != 1000000000 || report.MaxTimestamp != 1000000002 {
+		t.Fatalf("timestamps: %d - %d", report.MinTimestamp, report.MaxTimestamp)
+	}
+}
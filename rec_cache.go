@@ -0,0 +1,479 @@
+package main
+
+import (
+	"container/list"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CACHÉ DE RECOMENDACIONES - LRU acotado con TTL por entrada
+// ============================================================================
+// La clave incluye usuario, algoritmo, top_n y los parámetros de la
+// solicitud, así que un top-10 cacheado nunca se sirve a un top_n=50. Al
+// superar la capacidad se descarta la entrada usada hace más tiempo. Cada
+// invalidación da al usuario una generación nueva de un contador creciente:
+// un resultado calculado antes no se guarda (SetIfGeneration). Solo se
+// recuerdan hasta capacity usuarios; al superarlo (o al vaciar la caché) se
+// olvidan todos y el resto pasa a una generación común nueva.
+const (
+	defaultCacheCapacity = 10000
+	defaultCacheTTL      = 30 * time.Minute
+)
+
+// Identifica una solicitud de recomendaciones
+type CacheKey struct {
+	UserID    int
+	Algorithm string
+	TopN      int
+	Params    string // parámetros adicionales normalizados
+}
+
+func (k CacheKey) String() string {
+	key := fmt.Sprintf("user=%d|alg=%s|n=%d", k.UserID, k.Algorithm, k.TopN)
+	if k.Params != "" {
+		key += "|" + k.Params
+	}
+	return key
+}
+
+type cacheEntry struct {
+	key       CacheKey
+	items     []RecommendationItem
+	createdAt time.Time
+	expiresAt time.Time
+	hits      int
+}
+
+// Descripción de una entrada para el endpoint de administración
+type CacheEntryInfo struct {
+	Key       string    `json:"key"`
+	UserID    int       `json:"user_id"`
+	Algorithm string    `json:"algorithm"`
+	TopN      int       `json:"top_n"`
+	Items     int       `json:"items"`
+	Hits      int       `json:"hits"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type CacheStats struct {
+	Size        int     `json:"size"`
+	Capacity    int     `json:"capacity"`
+	TTLSeconds  float64 `json:"ttl_seconds"`
+	Hits        int     `json:"hits"`
+	Misses      int     `json:"misses"`
+	Evictions   int     `json:"evictions"`
+	Expirations int     `json:"expirations"`
+}
+
+type RecommendationCache struct {
+	capacity int
+	ttl      time.Duration
+	entries  map[string]*list.Element
+	order    *list.List              // frente = usada más recientemente
+	byUser   map[int]map[string]bool // userID -> claves
+	userGens map[int]uint64          // generación de los usuarios invalidados
+	baseGen  uint64                  // generación de los demás usuarios
+	clock    uint64                  // última generación asignada
+	stats    CacheStats
+	mu       sync.Mutex
+}
+
+func NewRecommendationCache(capacity int, ttl time.Duration) *RecommendationCache {
+	if capacity <= 0 {
+		capacity = defaultCacheCapacity
+	}
+	if ttl <= 0 {
+		ttl = defaultCacheTTL
+	}
+	return &RecommendationCache{
+		capacity: capacity,
+		ttl:      ttl,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+		byUser:   make(map[int]map[string]bool),
+		userGens: make(map[int]uint64),
+	}
+}
+
+// Obtener una entrada vigente; las vencidas se eliminan
+func (c *RecommendationCache) Get(key CacheKey) ([]RecommendationItem, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, exists := c.entries[key.String()]
+	if !exists {
+		c.stats.Misses++
+		return nil, false
+	}
+
+	entry := element.Value.(*cacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.removeElement(element)
+		c.stats.Expirations++
+		c.stats.Misses++
+		return nil, false
+	}
+
+	entry.hits++
+	c.stats.Hits++
+	c.order.MoveToFront(element)
+	return entry.items, true
+}
+
+// Tiempo de vida restante de una entrada (0 si no existe), sin contar como acceso
+func (c *RecommendationCache) Remaining(key CacheKey) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, exists := c.entries[key.String()]
+	if !exists {
+		return 0
+	}
+	if remaining := time.Until(element.Value.(*cacheEntry).expiresAt); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// Generación de las entradas de un usuario: aumenta al invalidarlas o al
+// vaciar la caché
+func (c *RecommendationCache) Generation(userID int) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generationLocked(userID)
+}
+
+func (c *RecommendationCache) generationLocked(userID int) uint64 {
+	if generation, exists := c.userGens[userID]; exists {
+		return generation
+	}
+	return c.baseGen
+}
+
+// Olvidar las generaciones por usuario: todos pasan a una nueva, así que
+// ningún cálculo en curso se guarda
+func (c *RecommendationCache) resetGenerationsLocked() {
+	c.clock++
+	c.baseGen = c.clock
+	c.userGens = make(map[int]uint64)
+}
+
+// Guardar (o reemplazar) una entrada, expulsando la menos usada si no hay espacio
+func (c *RecommendationCache) Set(key CacheKey, items []RecommendationItem) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setLocked(key, items)
+}
+
+// Guardar solo si el usuario no se invalidó desde que se leyó generation
+// (antes de calcular items); devuelve si se guardó
+func (c *RecommendationCache) SetIfGeneration(key CacheKey, items []RecommendationItem, generation uint64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generationLocked(key.UserID) != generation {
+		return false
+	}
+	c.setLocked(key, items)
+	return true
+}
+
+func (c *RecommendationCache) setLocked(key CacheKey, items []RecommendationItem) {
+	now := time.Now()
+	keyString := key.String()
+	if element, exists := c.entries[keyString]; exists {
+		entry := element.Value.(*cacheEntry)
+		entry.items = items
+		entry.createdAt = now
+		entry.expiresAt = now.Add(c.ttl)
+		c.order.MoveToFront(element)
+		return
+	}
+
+	for c.order.Len() >= c.capacity {
+		c.removeElement(c.order.Back())
+		c.stats.Evictions++
+	}
+
+	c.entries[keyString] = c.order.PushFront(&cacheEntry{
+		key:       key,
+		items:     items,
+		createdAt: now,
+		expiresAt: now.Add(c.ttl),
+	})
+	if c.byUser[key.UserID] == nil {
+		c.byUser[key.UserID] = make(map[string]bool)
+	}
+	c.byUser[key.UserID][keyString] = true
+}
+
+// Eliminar todas las entradas de un usuario; devuelve cuántas había
+func (c *RecommendationCache) InvalidateUser(userID int) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.userGens) >= c.capacity {
+		c.resetGenerationsLocked()
+	}
+	c.clock++
+	c.userGens[userID] = c.clock
+	removed := 0
+	for keyString := range c.byUser[userID] {
+		if element, exists := c.entries[keyString]; exists {
+			c.removeElement(element)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Vaciar la caché; devuelve cuántas entradas había
+func (c *RecommendationCache) Purge() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.resetGenerationsLocked()
+	removed := c.order.Len()
+	c.entries = make(map[string]*list.Element)
+	c.order.Init()
+	c.byUser = make(map[int]map[string]bool)
+	return removed
+}
+
+// Eliminar las entradas vencidas
+func (c *RecommendationCache) RemoveExpired() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	removed := 0
+	for element := c.order.Back(); element != nil; {
+		prev := element.Prev()
+		if now.After(element.Value.(*cacheEntry).expiresAt) {
+			c.removeElement(element)
+			removed++
+		}
+		element = prev
+	}
+	c.stats.Expirations += removed
+	return removed
+}
+
+func (c *RecommendationCache) removeElement(element *list.Element) {
+	entry := element.Value.(*cacheEntry)
+	keyString := entry.key.String()
+	c.order.Remove(element)
+	delete(c.entries, keyString)
+	if keys := c.byUser[entry.key.UserID]; keys != nil {
+		delete(keys, keyString)
+		if len(keys) == 0 {
+			delete(c.byUser, entry.key.UserID)
+		}
+	}
+}
+
+func (c *RecommendationCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *RecommendationCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Size = c.order.Len()
+	stats.Capacity = c.capacity
+	stats.TTLSeconds = c.ttl.Seconds()
+	return stats
+}
+
+// Entradas (más recientes primero), opcionalmente de un solo usuario
+func (c *RecommendationCache) Entries(userID int, limit int) []CacheEntryInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries := make([]CacheEntryInfo, 0)
+	for element := c.order.Front(); element != nil && len(entries) < limit; element = element.Next() {
+		entry := element.Value.(*cacheEntry)
+		if userID != 0 && entry.key.UserID != userID {
+			continue
+		}
+		entries = append(entries, CacheEntryInfo{
+			Key:       entry.key.String(),
+			UserID:    entry.key.UserID,
+			Algorithm: entry.key.Algorithm,
+			TopN:      entry.key.TopN,
+			Items:     len(entry.items),
+			Hits:      entry.hits,
+			CreatedAt: entry.createdAt,
+			ExpiresAt: entry.expiresAt,
+		})
+	}
+	return entries
+}
+
+// Entrada completa, para persistirla y restaurarla
+type CachedRecommendations struct {
+	Key       CacheKey             `json:"key"`
+	Items     []RecommendationItem `json:"items"`
+	CreatedAt time.Time            `json:"created_at"`
+	ExpiresAt time.Time            `json:"expires_at"`
+}
+
+// Entradas vigentes, la más usada primero
+func (c *RecommendationCache) Snapshot() []CachedRecommendations {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	entries := make([]CachedRecommendations, 0, c.order.Len())
+	for element := c.order.Front(); element != nil; element = element.Next() {
+		entry := element.Value.(*cacheEntry)
+		if now.After(entry.expiresAt) {
+			continue
+		}
+		entries = append(entries, CachedRecommendations{entry.key, entry.items, entry.createdAt, entry.expiresAt})
+	}
+	return entries
+}
+
+// Restaurar entradas conservando su vencimiento; las vencidas se ignoran
+func (c *RecommendationCache) Restore(entries []CachedRecommendations) int {
+	now := time.Now()
+	restored := 0
+	// Del menos usado al más usado, para conservar el orden LRU
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		if now.After(entry.ExpiresAt) {
+			continue
+		}
+		c.Set(entry.Key, entry.Items)
+
+		c.mu.Lock()
+		if element, exists := c.entries[entry.Key.String()]; exists {
+			stored := element.Value.(*cacheEntry)
+			stored.createdAt = entry.CreatedAt
+			stored.expiresAt = entry.ExpiresAt
+		}
+		c.mu.Unlock()
+		restored++
+	}
+	return restored
+}
+
+// Parámetros de la solicitud en forma canónica para la clave
+func recommendationCacheKey(req RecommendationAPIRequest) CacheKey {
+	// Con parámetros temporales se usa siempre k-NN (ver computeRecommendations)
+	algorithm := req.Algorithm
+	if algorithm == "" || req.timeOptions().Enabled() {
+		algorithm = AlgorithmKNN
+	}
+
+	params := make([]string, 0)
+	if req.AsOf > 0 {
+		params = append(params, fmt.Sprintf("as_of=%d", req.AsOf))
+	}
+	if req.HalfLifeDays > 0 {
+		params = append(params, fmt.Sprintf("half_life=%g", req.HalfLifeDays))
+	}
+	if req.Mode != "" {
+		params = append(params, "mode="+req.Mode)
+	}
+	if algorithm == AlgorithmHybrid {
+		if req.Blend != "" {
+			params = append(params, "blend="+req.Blend)
+		}
+		sources := make([]string, 0, len(req.Weights))
+		for source := range req.Weights {
+			sources = append(sources, source)
+		}
+		sort.Strings(sources)
+		for _, source := range sources {
+			params = append(params, fmt.Sprintf("w.%s=%g", source, req.Weights[source]))
+		}
+	}
+
+	return CacheKey{
+		UserID:    req.UserID,
+		Algorithm: algorithm,
+		TopN:      req.TopN,
+		Params:    strings.Join(params, "|"),
+	}
+}
+
+// Reemplazar la caché por una con otra capacidad o TTL
+func (db *Database) ConfigureCache(capacity int, ttl time.Duration) {
+	cache := NewRecommendationCache(capacity, ttl)
+
+	db.mu.Lock()
+	previous := db.RecommendationCache
+	db.RecommendationCache = cache
+	db.mu.Unlock()
+
+	// Conservar lo restaurado del almacenamiento
+	if previous != nil {
+		cache.Restore(previous.Snapshot())
+	}
+
+	log.Printf("[DB] Caché de recomendaciones: %d entradas, TTL %v", cache.capacity, cache.ttl)
+}
+
+// Cálculos de recomendaciones en curso, para que solicitudes idénticas que
+// llegan antes de que el primero termine compartan su resultado en lugar de
+// consultar de nuevo a todos los workers
+type inflightCall struct {
+	done            chan struct{}
+	recommendations []RecommendationItem
+	nodesUsed       int
+	err             error
+	waiters         int
+}
+
+type requestGroup struct {
+	calls map[string]*inflightCall
+	mu    sync.Mutex
+}
+
+func newRequestGroup() *requestGroup {
+	return &requestGroup{calls: make(map[string]*inflightCall)}
+}
+
+// Ejecutar compute una sola vez por clave; coalesced indica si se reutilizó
+// el resultado de otra solicitud. El resultado es compartido: no modificarlo.
+func (g *requestGroup) Do(key CacheKey, compute func() ([]RecommendationItem, int, error)) (recommendations []RecommendationItem, nodesUsed int, coalesced bool, err error) {
+	keyString := key.String()
+
+	g.mu.Lock()
+	if call, exists := g.calls[keyString]; exists {
+		call.waiters++
+		g.mu.Unlock()
+		<-call.done
+		return call.recommendations, call.nodesUsed, true, call.err
+	}
+	call := &inflightCall{done: make(chan struct{})}
+	g.calls[keyString] = call
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, keyString)
+		waiters := call.waiters
+		g.mu.Unlock()
+		close(call.done)
+
+		if waiters > 0 {
+			log.Printf("[API] %d solicitudes coalescidas con %s", waiters, keyString)
+		}
+	}()
+
+	// Si compute entra en pánico, quienes esperan reciben este error
+	call.err = fmt.Errorf("cálculo de recomendaciones interrumpido")
+	call.recommendations, call.nodesUsed, call.err = compute()
+	return call.recommendations, call.nodesUsed, false, call.err
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// PRUEBAS DE LA CACHÉ - Claves, LRU, TTL, generaciones y coalescencia
+// ============================================================================
+// Necesitan los archivos del coordinador (ver el Dockerfile):
+//
+//	go test <archivos del coordinador del Dockerfile> rec_cache_test.go
+
+func testItems(movieIDs ...int) []RecommendationItem {
+	items := make([]RecommendationItem, len(movieIDs))
+	for i, movieID := range movieIDs {
+		items[i] = RecommendationItem{MovieID: movieID}
+	}
+	return items
+}
+
+func TestRecommendationCacheKeys(t *testing.T) {
+	top10 := recommendationCacheKey(RecommendationAPIRequest{UserID: 1, TopN: 10})
+	top50 := recommendationCacheKey(RecommendationAPIRequest{UserID: 1, TopN: 50})
+	if top10 == top50 {
+		t.Fatalf("top_n 10 y 50 comparten clave: %s", top10)
+	}
+	if top10.Algorithm != AlgorithmKNN {
+		t.Fatalf("algoritmo por defecto: %q", top10.Algorithm)
+	}
+
+	// Con parámetros temporales siempre se calcula k-NN
+	timed := recommendationCacheKey(RecommendationAPIRequest{UserID: 1, TopN: 10, Algorithm: AlgorithmKNN, AsOf: 1000})
+	if timed.Algorithm != AlgorithmKNN || timed == top10 {
+		t.Fatalf("clave temporal: %s", timed)
+	}
+
+	// Los pesos del híbrido no dependen del orden del mapa
+	weights := map[string]float64{AlgorithmKNN: 0.7, AlgorithmContent: 0.3}
+	a := recommendationCacheKey(RecommendationAPIRequest{UserID: 1, TopN: 10, Algorithm: AlgorithmHybrid, Weights: weights})
+	for i := 0; i < 10; i++ {
+		b := recommendationCacheKey(RecommendationAPIRequest{UserID: 1, TopN: 10, Algorithm: AlgorithmHybrid, Weights: map[string]float64{AlgorithmContent: 0.3, AlgorithmKNN: 0.7}})
+		if a != b {
+			t.Fatalf("claves distintas para los mismos pesos: %s, %s", a, b)
+		}
+	}
+	// Los pesos solo cuentan para el híbrido
+	if k := recommendationCacheKey(RecommendationAPIRequest{UserID: 1, TopN: 10, Weights: weights}); k != top10 {
+		t.Fatalf("pesos en la clave de k-NN: %s", k)
+	}
+
+	cache := NewRecommendationCache(10, time.Minute)
+	cache.Set(top10, testItems(1))
+	if _, hit := cache.Get(top50); hit {
+		t.Fatal("un top-10 cacheado se sirvió a top_n=50")
+	}
+}
+
+func TestRecommendationCacheLRU(t *testing.T) {
+	cache := NewRecommendationCache(2, time.Minute)
+	k1 := CacheKey{UserID: 1, Algorithm: AlgorithmKNN, TopN: 10}
+	k2 := CacheKey{UserID: 2, Algorithm: AlgorithmKNN, TopN: 10}
+	k3 := CacheKey{UserID: 3, Algorithm: AlgorithmKNN, TopN: 10}
+
+	cache.Set(k1, testItems(1))
+	cache.Set(k2, testItems(2))
+	if _, hit := cache.Get(k1); !hit {
+		t.Fatal("k1 no está")
+	}
+	cache.Set(k3, testItems(3)) // expulsa k2, usada hace más tiempo
+
+	if _, hit := cache.Get(k2); hit {
+		t.Fatal("k2 debió expulsarse")
+	}
+	for _, key := range []CacheKey{k1, k3} {
+		if _, hit := cache.Get(key); !hit {
+			t.Fatalf("%s no está", key)
+		}
+	}
+	if stats := cache.Stats(); stats.Size != 2 || stats.Evictions != 1 {
+		t.Fatalf("stats: %+v", stats)
+	}
+}
+
+func TestRecommendationCacheTTL(t *testing.T) {
+	cache := NewRecommendationCache(10, 20*time.Millisecond)
+	key := CacheKey{UserID: 1, Algorithm: AlgorithmKNN, TopN: 10}
+	cache.Set(key, testItems(1))
+	if _, hit := cache.Get(key); !hit {
+		t.Fatal("entrada recién guardada no está")
+	}
+
+	time.Sleep(40 * time.Millisecond)
+	if _, hit := cache.Get(key); hit {
+		t.Fatal("entrada vencida servida")
+	}
+	if stats := cache.Stats(); stats.Size != 0 || stats.Expirations != 1 {
+		t.Fatalf("stats: %+v", stats)
+	}
+}
+
+func TestRecommendationCacheGenerations(t *testing.T) {
+	cache := NewRecommendationCache(2, time.Minute)
+	key := CacheKey{UserID: 1, Algorithm: AlgorithmKNN, TopN: 10}
+
+	// Un cálculo que empezó antes de la invalidación no se guarda
+	generation := cache.Generation(1)
+	cache.InvalidateUser(1)
+	if cache.SetIfGeneration(key, testItems(1), generation) {
+		t.Fatal("se guardó un resultado anterior a la invalidación")
+	}
+	if !cache.SetIfGeneration(key, testItems(1), cache.Generation(1)) {
+		t.Fatal("no se guardó un resultado vigente")
+	}
+
+	// Las generaciones recordadas no superan la capacidad, y olvidarlas no
+	// deja guardar cálculos anteriores
+	stale := cache.Generation(1)
+	for userID := 2; userID <= 20; userID++ {
+		cache.InvalidateUser(userID)
+	}
+	cache.mu.Lock()
+	tracked := len(cache.userGens)
+	cache.mu.Unlock()
+	if tracked > 2 {
+		t.Fatalf("%d usuarios con generación propia, capacidad 2", tracked)
+	}
+	if cache.SetIfGeneration(key, testItems(2), stale) {
+		t.Fatal("se guardó un resultado anterior al olvido de generaciones")
+	}
+
+	stale = cache.Generation(1)
+	cache.Purge()
+	if cache.SetIfGeneration(key, testItems(3), stale) {
+		t.Fatal("se guardó un resultado anterior a vaciar la caché")
+	}
+}
+
+// Las solicitudes idénticas que llegan durante un cálculo esperan su
+// resultado; las de otra clave calculan por su cuenta
+func TestRequestGroupCoalescing(t *testing.T) {
+	group := newRequestGroup()
+	key := CacheKey{UserID: 1, Algorithm: AlgorithmKNN, TopN: 10}
+	other := CacheKey{UserID: 1, Algorithm: AlgorithmKNN, TopN: 20}
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	computes := 0
+	type result struct {
+		items     []RecommendationItem
+		coalesced bool
+		err       error
+	}
+	leader := make(chan result)
+	go func() {
+		items, _, coalesced, err := group.Do(key, func() ([]RecommendationItem, int, error) {
+			computes++
+			close(started)
+			<-release
+			return testItems(7), 3, nil
+		})
+		leader <- result{items, coalesced, err}
+	}()
+	<-started
+
+	const waiters = 5
+	followers := make(chan result, waiters)
+	for i := 0; i < waiters; i++ {
+		go func() {
+			items, _, coalesced, err := group.Do(key, func() ([]RecommendationItem, int, error) {
+				t.Error("una solicitud coalescida volvió a calcular")
+				return nil, 0, nil
+			})
+			followers <- result{items, coalesced, err}
+		}()
+	}
+	// Esperar a que todas estén bloqueadas en la llamada en curso
+	for deadline := time.Now().Add(time.Second); ; {
+		group.mu.Lock()
+		joined := group.calls[key.String()].waiters
+		group.mu.Unlock()
+		if joined == waiters {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d de %d solicitudes esperando", joined, waiters)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Otra clave no espera a la primera
+	if _, _, coalesced, _ := group.Do(other, func() ([]RecommendationItem, int, error) {
+		return testItems(8), 1, nil
+	}); coalesced {
+		t.Fatal("una clave distinta se coalesció")
+	}
+
+	close(release)
+	if r := <-leader; r.coalesced || r.err != nil || len(r.items) != 1 || r.items[0].MovieID != 7 {
+		t.Fatalf("primera solicitud: %+v", r)
+	}
+	for i := 0; i < waiters; i++ {
+		if r := <-followers; !r.coalesced || r.err != nil || len(r.items) != 1 || r.items[0].MovieID != 7 {
+			t.Fatalf("solicitud coalescida: %+v", r)
+		}
+	}
+	if computes != 1 {
+		t.Fatalf("%d cálculos, se esperaba 1", computes)
+	}
+
+	// Terminado el cálculo, la clave vuelve a calcular
+	if _, _, coalesced, _ := group.Do(key, func() ([]RecommendationItem, int, error) {
+		return nil, 0, nil
+	}); coalesced {
+		t.Fatal("se reutilizó un cálculo ya terminado")
+	}
+}
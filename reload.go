@@ -0,0 +1,488 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RECARGA DE DATASETS - Versión nueva sin reiniciar el clúster
+// ============================================================================
+// Workers y coordinador cargan la versión nueva aparte mientras la API sigue
+// respondiendo con la actual. Se verifica (nada vacío, las particiones suman
+// lo mismo que ratings.csv y, sin force, no se pierde más de la mitad de los
+// usuarios), se confirma en los workers y se intercambia el contenido del
+// dataset local y del catálogo bajo sus locks. Después se aplican los ratings
+// en vivo, se reconstruyen los modelos y se vacía la caché. La versión
+// reemplazada queda en memoria para deshacer la recarga hasta que se confirme
+// otra; mientras se carga una nueva conviven tres versiones.
+const (
+	ReloadStateIdle        = "idle"
+	ReloadStateLoading     = "loading"
+	ReloadStateRollingBack = "rolling_back"
+	ReloadStateCommitted   = "committed"
+	ReloadStateFailed      = "failed"
+	ReloadStateRolledBack  = "rolled_back"
+
+	reloadMinUserRatio  = 0.5
+	reloadVersionFormat = "20060102T150405Z"
+)
+
+var errReloadBusy = fmt.Errorf("ya hay una recarga en curso")
+
+type DatasetVersion struct {
+	Version  string    `json:"version"`
+	Dir      string    `json:"dir"`
+	LoadedAt time.Time `json:"loaded_at"`
+	Users    int       `json:"users"`
+	Movies   int       `json:"movies"`
+	Ratings  int       `json:"ratings"`
+}
+
+type ReloadStatus struct {
+	State      string           `json:"state"`
+	Target     string           `json:"target,omitempty"` // versión de la última recarga o rollback
+	Error      string           `json:"error,omitempty"`
+	StartedAt  *time.Time       `json:"started_at,omitempty"`
+	FinishedAt *time.Time       `json:"finished_at,omitempty"`
+	Current    DatasetVersion   `json:"current"`
+	Previous   *DatasetVersion  `json:"previous,omitempty"`
+	Workers    []ReloadResponse `json:"workers,omitempty"`
+}
+
+type datasetReload struct {
+	status   ReloadStatus
+	previous *datasetState // versión reemplazada, para deshacer
+	mu       sync.Mutex
+}
+
+func (r *datasetReload) busy() bool {
+	return r.status.State == ReloadStateLoading || r.status.State == ReloadStateRollingBack
+}
+
+// Datos y modelos de una versión del dataset
+type datasetState struct {
+	info       DatasetVersion
+	local      *LocalDataSet
+	catalog    *Database // películas, ratings e índices; nil sin base de datos
+	partitions []string
+	baseline   *BaselineModel
+	content    *ContentModel
+	bpr        *BPRModel
+	slopeOne   *SlopeOneModel
+}
+
+// Registrar la versión cargada al arrancar
+func (dc *DistributedCoordinator) InitDatasetVersion(dir string) {
+	info := dc.localDataset.info(initialDatasetVersion, dir)
+	dc.reload.mu.Lock()
+	dc.reload.status = ReloadStatus{State: ReloadStateIdle, Current: info}
+	dc.reload.mu.Unlock()
+}
+
+func (dc *DistributedCoordinator) ReloadStatus() ReloadStatus {
+	dc.reload.mu.Lock()
+	defer dc.reload.mu.Unlock()
+	return dc.reload.status
+}
+
+// Iniciar en segundo plano la carga de dir (movies.csv, ratings.csv y
+// ratings_partN.csv, visibles con la misma ruta en los workers)
+func (dc *DistributedCoordinator) StartReload(dir, version string, force bool) (ReloadStatus, error) {
+	if version == "" {
+		version = time.Now().UTC().Format(reloadVersionFormat)
+	}
+	if err := WithinDir(dc.dataRoot, dir); err != nil {
+		return dc.ReloadStatus(), err
+	}
+
+	dc.reload.mu.Lock()
+	defer dc.reload.mu.Unlock()
+	if dc.reload.busy() {
+		return dc.reload.status, errReloadBusy
+	}
+	if version == dc.reload.status.Current.Version {
+		return dc.reload.status, fmt.Errorf("la versión %s ya está en uso", version)
+	}
+
+	// La versión anterior se conserva hasta confirmar la nueva: si la carga
+	// falla, el rollback sigue disponible
+	now := time.Now()
+	dc.reload.status.State = ReloadStateLoading
+	dc.reload.status.Target = version
+	dc.reload.status.Error = ""
+	dc.reload.status.StartedAt = &now
+	dc.reload.status.FinishedAt = nil
+	dc.reload.status.Workers = nil
+
+	go dc.runReload(dir, version, force)
+	return dc.reload.status, nil
+}
+
+func (dc *DistributedCoordinator) runReload(dir, version string, force bool) {
+	start := time.Now()
+	log.Printf("[COORD] Recarga %s: cargando %s", version, dir)
+
+	partitions := make([]string, len(dc.workers))
+	for i := range dc.workers {
+		partitions[i] = filepath.Join(dir, fmt.Sprintf("ratings_part%d.csv", i+1))
+	}
+
+	// Workers y coordinador cargan en paralelo
+	var staged *datasetState
+	var stageErr error
+	loaded := make(chan struct{})
+	go func() {
+		staged, stageErr = dc.stageDataset(dir, version)
+		close(loaded)
+	}()
+	responses, err := dc.broadcastReload(ReloadPrepare, version, partitions)
+	<-loaded
+
+	if err == nil {
+		err = stageErr
+	}
+	if err == nil {
+		err = dc.verifyReload(staged, responses, force)
+	}
+	committing := false
+	if err == nil {
+		// Todos los workers confirman antes de tocar el coordinador
+		committing = true
+		responses, err = dc.broadcastReload(ReloadCommit, version, nil)
+	}
+	if err != nil {
+		// Descartar lo preparado y deshacer las confirmaciones parciales
+		dc.broadcastReload(ReloadRollback, version, nil)
+		if committing {
+			// Los workers que confirmaron ya reemplazaron su versión anterior
+			dc.reload.mu.Lock()
+			dc.reload.previous = nil
+			dc.reload.mu.Unlock()
+			log.Printf("[COORD] Recarga %s: confirmación parcial deshecha, no queda versión para rollback", version)
+		}
+		log.Printf("[COORD] Recarga %s fallida: %v", version, err)
+		dc.finishReload(ReloadStateFailed, responses, err)
+		return
+	}
+
+	// Hasta reconstruirlos se siguen usando los modelos actuales
+	staged.partitions = partitions
+	dc.mu.RLock()
+	staged.baseline, staged.content, staged.bpr, staged.slopeOne = dc.baseline, dc.content, dc.bpr, dc.slopeOne
+	dc.mu.RUnlock()
+
+	dc.swapDataset(staged)
+	dc.reload.mu.Lock()
+	dc.reload.previous = staged
+	dc.reload.mu.Unlock()
+
+	if err := dc.ReplayStoredRatings(); err != nil {
+		log.Printf("[COORD] Recarga %s: no se pudieron aplicar los ratings en vivo: %v", version, err)
+	}
+	dc.rebuildModels()
+	dc.flushAfterSwap()
+
+	log.Printf("[COORD] Recarga %s completada en %v", version, time.Since(start))
+	dc.finishReload(ReloadStateCommitted, responses, nil)
+}
+
+// Volver en segundo plano a la versión reemplazada por la última recarga
+// (recalcular baseline y contenido tarda lo mismo que en una recarga). La
+// versión deshecha queda como anterior: un segundo rollback la restaura.
+func (dc *DistributedCoordinator) RollbackDataset() (ReloadStatus, error) {
+	dc.reload.mu.Lock()
+	if dc.reload.busy() {
+		dc.reload.mu.Unlock()
+		return dc.ReloadStatus(), errReloadBusy
+	}
+	previous := dc.reload.previous
+	if previous == nil {
+		dc.reload.mu.Unlock()
+		return dc.ReloadStatus(), fmt.Errorf("no hay una versión anterior")
+	}
+	current := dc.reload.status.Current.Version
+	now := time.Now()
+	dc.reload.status.State = ReloadStateRollingBack
+	dc.reload.status.Target = previous.info.Version
+	dc.reload.status.Error = ""
+	dc.reload.status.StartedAt = &now
+	dc.reload.status.FinishedAt = nil
+	status := dc.reload.status
+	dc.reload.mu.Unlock()
+
+	go dc.runRollback(previous, current)
+	return status, nil
+}
+
+func (dc *DistributedCoordinator) runRollback(previous *datasetState, current string) {
+	responses, err := dc.broadcastReload(ReloadRollback, current, nil)
+	if err != nil {
+		// Los workers que ya volvieron se adelantan otra vez a la versión actual
+		dc.broadcastReload(ReloadRollback, previous.info.Version, nil)
+		log.Printf("[COORD] Rollback a %s fallido: %v", previous.info.Version, err)
+		dc.finishReload(ReloadStateFailed, responses, err)
+		return
+	}
+
+	dc.swapDataset(previous)
+	if err := dc.ReplayStoredRatings(); err != nil {
+		log.Printf("[COORD] Rollback: no se pudieron aplicar los ratings en vivo: %v", err)
+	}
+	// Los modelos guardados con la versión pueden ser de otra (p. ej. si el
+	// rollback llegó antes de terminar el entrenamiento): se reconstruyen
+	dc.rebuildModels()
+	dc.flushAfterSwap()
+
+	log.Printf("[COORD] Rollback: versión %s en uso (%s queda como anterior)", previous.info.Version, current)
+	dc.finishReload(ReloadStateRolledBack, responses, nil)
+}
+
+// Cargar una versión del dataset sin tocar la que está en uso
+func (dc *DistributedCoordinator) stageDataset(dir, version string) (*datasetState, error) {
+	moviesPath := filepath.Join(dir, "movies.csv")
+	state := &datasetState{local: newLocalDataSet()}
+
+	if dc.db != nil {
+		state.catalog = newDatabase(NewMemoryStore(), "")
+		if err := state.catalog.LoadMovies(moviesPath); err != nil {
+			return nil, fmt.Errorf("error cargando películas: %v", err)
+		}
+
+		// Como al arrancar, las películas que solo están en el almacenamiento se conservan
+		stored, err := dc.db.store.Movies()
+		if err != nil {
+			return nil, fmt.Errorf("error leyendo el catálogo guardado: %v", err)
+		}
+		for _, movie := range stored {
+			if _, exists := state.catalog.Movies[movie.MovieID]; !exists {
+				state.catalog.Movies[movie.MovieID] = movie
+			}
+		}
+	}
+
+	if err := state.local.loadMovies(moviesPath); err != nil {
+		return nil, fmt.Errorf("error cargando películas: %v", err)
+	}
+	if err := state.local.loadRatings(filepath.Join(dir, "ratings.csv"), state.catalog, dc.loadMode); err != nil {
+		return nil, fmt.Errorf("error cargando ratings: %v", err)
+	}
+
+	state.info = state.local.info(version, dir)
+	log.Printf("[COORD] Recarga %s: %d usuarios, %d películas, %d ratings cargados",
+		version, state.info.Users, state.info.Movies, state.info.Ratings)
+	return state, nil
+}
+
+func (dc *DistributedCoordinator) verifyReload(staged *datasetState, responses []ReloadResponse, force bool) error {
+	info := staged.info
+	if info.Users == 0 || info.Movies == 0 || info.Ratings == 0 {
+		return fmt.Errorf("dataset incompleto: %d usuarios, %d películas, %d ratings",
+			info.Users, info.Movies, info.Ratings)
+	}
+
+	// Las particiones son ratings.csv dividido: deben sumar lo mismo
+	if len(responses) > 0 {
+		partitionRatings := 0
+		for _, resp := range responses {
+			if resp.Ratings == 0 {
+				return fmt.Errorf("worker %s: partición vacía", resp.WorkerID)
+			}
+			partitionRatings += resp.Ratings
+		}
+		if partitionRatings != info.Ratings {
+			return fmt.Errorf("las particiones suman %d ratings y ratings.csv tiene %d",
+				partitionRatings, info.Ratings)
+		}
+	}
+
+	current := dc.ReloadStatus().Current
+	if !force && float64(info.Users) < reloadMinUserRatio*float64(current.Users) {
+		return fmt.Errorf("%d usuarios, menos del %.0f%% de los %d actuales (force=true para aceptarlo)",
+			info.Users, reloadMinUserRatio*100, current.Users)
+	}
+	return nil
+}
+
+// Intercambiar datos, modelos y particiones con state: al terminar, state
+// contiene la versión que estaba en uso
+func (dc *DistributedCoordinator) swapDataset(state *datasetState) {
+	dc.localDataset.swap(state.local)
+	if dc.db != nil && state.catalog != nil {
+		dc.db.swapDataset(state.catalog)
+	}
+
+	dc.mu.Lock()
+	dc.baseline, state.baseline = state.baseline, dc.baseline
+	dc.content, state.content = state.content, dc.content
+	dc.bpr, state.bpr = state.bpr, dc.bpr
+	dc.slopeOne, state.slopeOne = state.slopeOne, dc.slopeOne
+	for i := range dc.workers {
+		if i < len(state.partitions) {
+			dc.workers[i].Partition, state.partitions[i] = state.partitions[i], dc.workers[i].Partition
+		}
+	}
+	dc.datasetGen++
+	dc.mu.Unlock()
+
+	dc.reload.mu.Lock()
+	dc.reload.status.Current, state.info = state.info, dc.reload.status.Current
+	dc.reload.mu.Unlock()
+}
+
+// Modelos de la versión en uso tras un intercambio: baseline y contenido al
+// momento, BPR y Slope One en segundo plano (hasta entonces, los anteriores)
+func (dc *DistributedCoordinator) rebuildModels() {
+	dc.fitBaseline()
+	dc.buildContentModel()
+	dc.StartBPRTraining()
+	dc.StartSlopeOneBuild()
+}
+
+// Catálogo en el almacenamiento y caché vacía tras cambiar de versión
+func (dc *DistributedCoordinator) flushAfterSwap() {
+	if dc.db == nil {
+		return
+	}
+	dc.db.persistCatalog()
+	dc.db.cache().Purge()
+}
+
+func (dc *DistributedCoordinator) finishReload(state string, responses []ReloadResponse, err error) {
+	now := time.Now()
+	dc.reload.mu.Lock()
+	defer dc.reload.mu.Unlock()
+
+	dc.reload.status.State = state
+	dc.reload.status.Workers = responses
+	dc.reload.status.FinishedAt = &now
+	if err != nil {
+		dc.reload.status.Error = err.Error()
+	}
+	dc.reload.status.Previous = nil
+	if dc.reload.previous != nil {
+		previous := dc.reload.previous.info
+		dc.reload.status.Previous = &previous
+	}
+}
+
+// Enviar una fase de la recarga a los workers activos en paralelo
+func (dc *DistributedCoordinator) broadcastReload(phase, version string, partitions []string) ([]ReloadResponse, error) {
+	var wg sync.WaitGroup
+	responses := make([]ReloadResponse, len(dc.workers))
+	sent := make([]bool, len(dc.workers))
+
+	for i, worker := range dc.workers {
+		if !worker.Active {
+			continue
+		}
+		req := ReloadRequest{Phase: phase, Version: version}
+		if partitions != nil {
+			req.Partition = partitions[i]
+		}
+		sent[i] = true
+		wg.Add(1)
+		go func(i int, address string, req ReloadRequest) {
+			defer wg.Done()
+			resp, err := dc.sendReloadRequest(address, req)
+			if err != nil {
+				resp = ReloadResponse{WorkerID: address, Error: err.Error()}
+			}
+			responses[i] = resp
+		}(i, worker.Address, req)
+	}
+	wg.Wait()
+
+	results := make([]ReloadResponse, 0, len(responses))
+	failed := make([]string, 0)
+	for i, resp := range responses {
+		if !sent[i] {
+			continue
+		}
+		results = append(results, resp)
+		if resp.Error != "" {
+			failed = append(failed, resp.WorkerID+": "+resp.Error)
+		}
+	}
+	if len(failed) > 0 {
+		return results, fmt.Errorf("error en %s: %s", phase, strings.Join(failed, "; "))
+	}
+	return results, nil
+}
+
+// Enviar una fase de la recarga a un worker via TCP
+func (dc *DistributedCoordinator) sendReloadRequest(address string, reload ReloadRequest) (ReloadResponse, error) {
+	conn, err := net.DialTimeout("tcp", address, 10*time.Second)
+	if err != nil {
+		return ReloadResponse{}, err
+	}
+	defer conn.Close()
+
+	req := WorkerRequest{Op: OpReload, Reload: &reload}
+	req.Dataset = dc.dataset
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return ReloadResponse{}, err
+	}
+
+	var resp ReloadResponse
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return ReloadResponse{}, err
+	}
+	return resp, nil
+}
+
+func (ds *LocalDataSet) info(version, dir string) DatasetVersion {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+	return DatasetVersion{
+		Version:  version,
+		Dir:      dir,
+		LoadedAt: time.Now(),
+		Users:    len(ds.UserRatingsMap),
+		Movies:   len(ds.Movies),
+		Ratings:  ds.Ratings,
+	}
+}
+
+// Intercambiar el contenido con otro dataset bajo el lock de ambos
+func (ds *LocalDataSet) swap(other *LocalDataSet) {
+	ds.mu.Lock()
+	other.mu.Lock()
+	ds.UserRatingsMap, other.UserRatingsMap = other.UserRatingsMap, ds.UserRatingsMap
+	ds.UserTimestamps, other.UserTimestamps = other.UserTimestamps, ds.UserTimestamps
+	ds.MaxTimestamp, other.MaxTimestamp = other.MaxTimestamp, ds.MaxTimestamp
+	ds.MovieRaters, other.MovieRaters = other.MovieRaters, ds.MovieRaters
+	ds.MovieCounts, other.MovieCounts = other.MovieCounts, ds.MovieCounts
+	ds.Movies, other.Movies = other.Movies, ds.Movies
+	ds.UserAvgRatings, other.UserAvgRatings = other.UserAvgRatings, ds.UserAvgRatings
+	ds.GlobalAvgRating, other.GlobalAvgRating = other.GlobalAvgRating, ds.GlobalAvgRating
+	ds.AllUserIDs, other.AllUserIDs = other.AllUserIDs, ds.AllUserIDs
+	ds.Ratings, other.Ratings = other.Ratings, ds.Ratings
+	ds.LiveBase, other.LiveBase = other.LiveBase, ds.LiveBase
+	other.mu.Unlock()
+	ds.mu.Unlock()
+}
+
+// Intercambiar catálogo, ratings e índices con otra base (recarga del dataset)
+func (db *Database) swapDataset(other *Database) {
+	db.mu.Lock()
+	other.mu.Lock()
+	db.Movies, other.Movies = other.Movies, db.Movies
+	db.Ratings, other.Ratings = other.Ratings, db.Ratings
+	db.GenomeTags, other.GenomeTags = other.GenomeTags, db.GenomeTags
+	// Ratings y géneros cambian para todos: las estadísticas se recalculan
+	db.ratingsClock++
+	db.ratingsBase = db.ratingsClock
+	db.ratingsVersions = make(map[int]uint64)
+	other.mu.Unlock()
+	db.mu.Unlock()
+
+	db.MovieStats.swap(other.MovieStats)
+	db.Trends.swap(other.Trends)
+	db.Search.swap(other.Search)
+}
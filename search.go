@@ -0,0 +1,257 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// BÚSQUEDA DE PELÍCULAS - Índice invertido sobre títulos
+// ============================================================================
+// Los títulos se normalizan (minúsculas, sin acentos) y se separan en tokens.
+// El último token de la consulta se trata como prefijo para búsqueda mientras
+// se escribe; un token de 4 dígitos se interpreta como año.
+const (
+	prefixMatchWeight = 0.6
+	maxSearchLimit    = 100
+)
+
+type SearchIndex struct {
+	postings map[string][]int // token -> movieIDs
+	tokens   []string         // tokens ordenados para búsqueda por prefijo
+	titles   map[int]string
+	years    map[int]int
+	docFreq  map[string]int
+	numDocs  int
+	mu       sync.RWMutex
+}
+
+type SearchResult struct {
+	MovieID      int      `json:"movie_id"`
+	Title        string   `json:"title"`
+	Year         int      `json:"year,omitempty"`
+	Genres       []string `json:"genres"`
+	RatingsCount int      `json:"ratings_count"`
+	Score        float64  `json:"score"`
+}
+
+// Equivalencias para quitar acentos y diacríticos comunes
+var accentFolding = map[rune]string{
+	'á': "a", 'à': "a", 'â': "a", 'ä': "a", 'ã': "a", 'å': "a", 'æ': "ae",
+	'é': "e", 'è': "e", 'ê': "e", 'ë': "e",
+	'í': "i", 'ì': "i", 'î': "i", 'ï': "i",
+	'ó': "o", 'ò': "o", 'ô': "o", 'ö': "o", 'õ': "o", 'ø': "o", 'œ': "oe",
+	'ú': "u", 'ù': "u", 'û': "u", 'ü': "u",
+	'ñ': "n", 'ç': "c", 'ß': "ss", 'ý': "y", 'ÿ': "y",
+}
+
+// Normalizar y separar un texto en tokens
+func tokenize(text string) []string {
+	var builder strings.Builder
+	for _, c := range strings.ToLower(text) {
+		if folded, exists := accentFolding[c]; exists {
+			builder.WriteString(folded)
+		} else if unicode.IsLetter(c) || unicode.IsDigit(c) {
+			builder.WriteRune(c)
+		} else {
+			builder.WriteRune(' ')
+		}
+	}
+	return strings.Fields(builder.String())
+}
+
+func NewSearchIndex() *SearchIndex {
+	return &SearchIndex{
+		postings: make(map[string][]int),
+		titles:   make(map[int]string),
+		years:    make(map[int]int),
+		docFreq:  make(map[string]int),
+	}
+}
+
+// Intercambiar el contenido con otro índice (recarga del dataset)
+func (idx *SearchIndex) swap(other *SearchIndex) {
+	idx.mu.Lock()
+	other.mu.Lock()
+	idx.postings, other.postings = other.postings, idx.postings
+	idx.tokens, other.tokens = other.tokens, idx.tokens
+	idx.titles, other.titles = other.titles, idx.titles
+	idx.years, other.years = other.years, idx.years
+	idx.docFreq, other.docFreq = other.docFreq, idx.docFreq
+	idx.numDocs, other.numDocs = other.numDocs, idx.numDocs
+	other.mu.Unlock()
+	idx.mu.Unlock()
+}
+
+// Reconstruir el índice a partir del catálogo
+func (idx *SearchIndex) Build(movies map[int]*Movie) {
+	postings := make(map[string][]int)
+	titles := make(map[int]string, len(movies))
+	years := make(map[int]int, len(movies))
+
+	for movieID, movie := range movies {
+		titles[movieID] = movie.Title
+		year := movieYear(movie.Title)
+		years[movieID] = year
+
+		yearToken := strconv.Itoa(year)
+		seen := make(map[string]bool)
+		for _, token := range tokenize(movie.Title) {
+			// El año va en su propio índice
+			if year != 0 && token == yearToken {
+				continue
+			}
+			if seen[token] {
+				continue
+			}
+			seen[token] = true
+			postings[token] = append(postings[token], movieID)
+		}
+	}
+
+	tokens := make([]string, 0, len(postings))
+	docFreq := make(map[string]int, len(postings))
+	for token, ids := range postings {
+		tokens = append(tokens, token)
+		docFreq[token] = len(ids)
+	}
+	sort.Strings(tokens)
+
+	idx.mu.Lock()
+	idx.postings = postings
+	idx.tokens = tokens
+	idx.titles = titles
+	idx.years = years
+	idx.docFreq = docFreq
+	idx.numDocs = len(movies)
+	idx.mu.Unlock()
+}
+
+// Buscar películas; popularity devuelve el número de ratings de una película
+func (idx *SearchIndex) Search(query string, limit int, popularity func(movieID int) int) []SearchResult {
+	if limit <= 0 || limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+
+	queryTokens := tokenize(query)
+	year := 0
+	terms := make([]string, 0, len(queryTokens))
+	for _, token := range queryTokens {
+		if len(token) == 4 && year == 0 {
+			if y, err := strconv.Atoi(token); err == nil && y >= 1870 && y <= 2100 {
+				year = y
+				continue
+			}
+		}
+		terms = append(terms, token)
+	}
+	if len(terms) == 0 && year == 0 {
+		return []SearchResult{}
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	results := idx.search(terms, year, popularity)
+	// Un número de 4 dígitos también puede ser parte del título ("2001: A Space Odyssey")
+	if len(results) == 0 && year != 0 {
+		results = idx.search(append(terms, strconv.Itoa(year)), 0, popularity)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].MovieID < results[j].MovieID
+	})
+
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// Puntuar las películas que contienen todos los términos (y el año, si se indica)
+func (idx *SearchIndex) search(terms []string, year int, popularity func(movieID int) int) []SearchResult {
+
+	// Relevancia por película: suma de idf de cada término (prefijo con menor peso).
+	// Todas las palabras de la consulta deben aparecer.
+	var scores map[int]float64
+	for i, term := range terms {
+		termScores := make(map[int]float64)
+		idx.matchExact(term, termScores)
+		if i == len(terms)-1 {
+			idx.matchPrefix(term, termScores)
+		}
+
+		if scores == nil {
+			scores = termScores
+			continue
+		}
+		for movieID := range scores {
+			if termScore, exists := termScores[movieID]; exists {
+				scores[movieID] += termScore
+			} else {
+				delete(scores, movieID)
+			}
+		}
+	}
+
+	// Solo año: todas las películas de ese año
+	if scores == nil {
+		scores = make(map[int]float64)
+		for movieID, y := range idx.years {
+			if y == year {
+				scores[movieID] = 1.0
+			}
+		}
+	}
+
+	results := make([]SearchResult, 0, len(scores))
+	for movieID, relevance := range scores {
+		if year != 0 && idx.years[movieID] != year {
+			continue
+		}
+		count := 0
+		if popularity != nil {
+			count = popularity(movieID)
+		}
+		results = append(results, SearchResult{
+			MovieID:      movieID,
+			Title:        idx.titles[movieID],
+			Year:         idx.years[movieID],
+			RatingsCount: count,
+			Score:        relevance * math.Log(2+float64(count)),
+		})
+	}
+	return results
+}
+
+func (idx *SearchIndex) idf(token string) float64 {
+	return math.Log(1 + float64(idx.numDocs)/float64(1+idx.docFreq[token]))
+}
+
+func (idx *SearchIndex) matchExact(term string, scores map[int]float64) {
+	weight := idx.idf(term)
+	for _, movieID := range idx.postings[term] {
+		scores[movieID] = math.Max(scores[movieID], weight)
+	}
+}
+
+// Coincidencias por prefijo sobre la lista ordenada de tokens
+func (idx *SearchIndex) matchPrefix(prefix string, scores map[int]float64) {
+	start := sort.SearchStrings(idx.tokens, prefix)
+	for i := start; i < len(idx.tokens) && strings.HasPrefix(idx.tokens[i], prefix); i++ {
+		token := idx.tokens[i]
+		if token == prefix {
+			continue
+		}
+		weight := prefixMatchWeight * idx.idf(token)
+		for _, movieID := range idx.postings[token] {
+			scores[movieID] = math.Max(scores[movieID], weight)
+		}
+	}
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SLOPE ONE PONDERADO - Desviaciones entre pares de películas
+// ============================================================================
+// dev(j,i) = promedio de (r_j - r_i) entre los usuarios que valoraron ambas.
+// La predicción para j es sum((dev(j,i) + r_i) * c_ji) / sum(c_ji) sobre las
+// películas i del usuario. La tabla se limita a las películas más valoradas
+// (n² pares); cada worker suma su partición y el coordinador combina.
+const (
+	slopeOneMovies   = 500
+	slopeOneMinCount = 5 // usuarios mínimos en común para usar un par
+)
+
+type SlopeOneModel struct {
+	movieIDs []int
+	index    map[int]int
+	sums     []float64 // sum(r_a - r_b) para a < b (posiciones en movieIDs)
+	counts   []int32
+	mu       sync.RWMutex
+}
+
+func newSlopeOneModel(movieIDs []int) *SlopeOneModel {
+	index := make(map[int]int, len(movieIDs))
+	for pos, movieID := range movieIDs {
+		index[movieID] = pos
+	}
+	pairs := len(movieIDs) * (len(movieIDs) - 1) / 2
+	return &SlopeOneModel{
+		movieIDs: movieIDs,
+		index:    index,
+		sums:     make([]float64, pairs),
+		counts:   make([]int32, pairs),
+	}
+}
+
+// Construir la tabla en segundo plano con los workers
+func (dc *DistributedCoordinator) StartSlopeOneBuild() {
+	dc.mu.RLock()
+	generation := dc.datasetGen
+	dc.mu.RUnlock()
+
+	go func() {
+		start := time.Now()
+		model, err := dc.buildSlopeOne()
+		if err != nil {
+			dc.mu.Lock()
+			if dc.datasetGen == generation {
+				dc.slopeOneErr = err
+			}
+			dc.mu.Unlock()
+			log.Printf("[COORD] Slope One no construido: %v", err)
+			return
+		}
+
+		if !dc.publishSlopeOne(model, generation) {
+			log.Println("[COORD] Slope One descartado: el dataset cambió durante la construcción")
+			return
+		}
+		log.Printf("[COORD] Slope One listo: %d películas, %d pares (%v)",
+			len(model.movieIDs), len(model.sums), time.Since(start))
+	}()
+}
+
+// Tabla de ratings.csv: con todos los workers o, si ninguno responde, con
+// los datos locales. Una respuesta parcial es un error (faltarían usuarios).
+func (dc *DistributedCoordinator) buildSlopeOne() (*SlopeOneModel, error) {
+	// Películas con más ratings
+	dc.localDataset.mu.RLock()
+	movieIDs := make([]int, 0, len(dc.localDataset.MovieCounts))
+	for movieID := range dc.localDataset.MovieCounts {
+		movieIDs = append(movieIDs, movieID)
+	}
+	sort.Slice(movieIDs, func(i, j int) bool {
+		ci, cj := dc.localDataset.MovieCounts[movieIDs[i]], dc.localDataset.MovieCounts[movieIDs[j]]
+		if ci != cj {
+			return ci > cj
+		}
+		return movieIDs[i] < movieIDs[j]
+	})
+	dc.localDataset.mu.RUnlock()
+	if len(movieIDs) > slopeOneMovies {
+		movieIDs = movieIDs[:slopeOneMovies]
+	}
+
+	model := newSlopeOneModel(movieIDs)
+
+	// Cada worker calcula su partición en paralelo
+	var wg sync.WaitGroup
+	responsesChan := make(chan DeviationResponse, len(dc.workers))
+	req := WorkerRequest{Op: OpDeviations, Deviations: &DeviationRequest{MovieIDs: movieIDs}}
+
+	failed := 0
+	var failedMu sync.Mutex
+	for _, worker := range dc.workers {
+		if !worker.Active {
+			failed++
+			continue
+		}
+		wg.Add(1)
+		go func(w WorkerNode) {
+			defer wg.Done()
+			resp, err := dc.sendDeviationRequest(w.Address, req)
+			if err == nil && (len(resp.Sums) != len(model.sums) || len(resp.Counts) != len(model.counts)) {
+				err = fmt.Errorf("tabla con tamaño inválido")
+			}
+			if err != nil {
+				log.Printf("[COORD] Error en worker %s (Slope One): %v", w.Address, err)
+				failedMu.Lock()
+				failed++
+				failedMu.Unlock()
+				return
+			}
+			responsesChan <- resp
+		}(worker)
+	}
+	wg.Wait()
+	close(responsesChan)
+
+	// Sin workers disponibles se calcula con los datos locales, tal como
+	// estaban en ratings.csv
+	if failed == len(dc.workers) {
+		log.Println("[COORD] Slope One: sin respuesta de workers, calculando localmente")
+		dc.localDataset.mu.RLock()
+		for userID, userRatings := range dc.localDataset.UserRatingsMap {
+			if base, live := dc.localDataset.LiveBase[userID]; live {
+				userRatings = base
+			}
+			AccumulateDeviations(userRatings, model.index, model.sums, model.counts)
+		}
+		dc.localDataset.mu.RUnlock()
+		return model, nil
+	}
+	if failed > 0 {
+		return nil, fmt.Errorf("%d de %d workers sin tabla Slope One", failed, len(dc.workers))
+	}
+
+	for resp := range responsesChan {
+		for k := range model.sums {
+			model.sums[k] += resp.Sums[k]
+			model.counts[k] += resp.Counts[k]
+		}
+		log.Printf("[COORD] Worker %s: desviaciones Slope One en %.2fms", resp.WorkerID, resp.ProcessTime)
+	}
+	return model, nil
+}
+
+// Sumar los ratings en vivo a una tabla de ratings.csv y publicarla. El
+// dataset queda bloqueado hasta publicar: applyRating espera y actualiza la
+// tabla nueva. false si el dataset cambió mientras se construía.
+func (dc *DistributedCoordinator) publishSlopeOne(model *SlopeOneModel, generation int) bool {
+	ds := dc.localDataset
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	if dc.datasetGen != generation {
+		return false
+	}
+
+	// Contribución de cada usuario: se quita la de ratings.csv y se suma la actual
+	for userID, base := range ds.LiveBase {
+		model.subtractDeviations(base)
+		AccumulateDeviations(ds.UserRatingsMap[userID], model.index, model.sums, model.counts)
+	}
+
+	dc.slopeOne = model
+	dc.slopeOneErr = nil
+	return true
+}
+
+// Inverso de AccumulateDeviations: quitar de la tabla los pares de un usuario
+func (m *SlopeOneModel) subtractDeviations(ratings map[int]float64) {
+	positions := make([]int, 0, len(ratings))
+	for movieID := range ratings {
+		if pos, exists := m.index[movieID]; exists {
+			positions = append(positions, pos)
+		}
+	}
+
+	n := len(m.movieIDs)
+	for x, a := range positions {
+		for _, b := range positions[x+1:] {
+			low, high := a, b
+			if low > high {
+				low, high = high, low
+			}
+			k := DeviationPairIndex(low, high, n)
+			m.sums[k] -= ratings[m.movieIDs[low]] - ratings[m.movieIDs[high]]
+			m.counts[k]--
+		}
+	}
+}
+
+// Enviar una solicitud de desviaciones a un worker via TCP
+func (dc *DistributedCoordinator) sendDeviationRequest(address string, req WorkerRequest) (DeviationResponse, error) {
+	conn, err := net.DialTimeout("tcp", address, 10*time.Second)
+	if err != nil {
+		return DeviationResponse{}, err
+	}
+	defer conn.Close()
+
+	req.Dataset = dc.dataset
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return DeviationResponse{}, err
+	}
+
+	var resp DeviationResponse
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return DeviationResponse{}, err
+	}
+	return resp, nil
+}
+
+// Actualizar la tabla con un rating nuevo o modificado. userRatings son los
+// ratings del usuario antes del cambio.
+func (m *SlopeOneModel) AddRating(userRatings map[int]float64, movieID int, rating float64) {
+	pos, tracked := m.index[movieID]
+	if !tracked {
+		return
+	}
+	previous, rerated := userRatings[movieID]
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n := len(m.movieIDs)
+	for otherID, otherRating := range userRatings {
+		other, exists := m.index[otherID]
+		if !exists || otherID == movieID {
+			continue
+		}
+
+		// Desviación del par orientada como (menor posición) - (mayor posición)
+		var k int
+		var sign float64
+		if pos < other {
+			k, sign = DeviationPairIndex(pos, other, n), 1
+		} else {
+			k, sign = DeviationPairIndex(other, pos, n), -1
+		}
+
+		if rerated {
+			m.sums[k] += sign * (rating - previous)
+		} else {
+			m.sums[k] += sign * (rating - otherRating)
+			m.counts[k]++
+		}
+	}
+}
+
+// Predicciones Slope One ponderadas para las películas no vistas
+func (m *SlopeOneModel) Predict(userRatings map[int]float64) map[int]float64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	// Posiciones de las películas del usuario en la tabla
+	rated := make(map[int]float64)
+	for movieID, rating := range userRatings {
+		if pos, exists := m.index[movieID]; exists {
+			rated[pos] = rating
+		}
+	}
+
+	n := len(m.movieIDs)
+	predictions := make(map[int]float64)
+	if len(rated) == 0 {
+		return predictions
+	}
+
+	for j, movieID := range m.movieIDs {
+		if _, seen := userRatings[movieID]; seen {
+			continue
+		}
+
+		numerator := 0.0
+		weight := 0
+		for i, rating := range rated {
+			var k int
+			var devSum float64
+			if j < i {
+				k = DeviationPairIndex(j, i, n)
+				devSum = m.sums[k]
+			} else {
+				k = DeviationPairIndex(i, j, n)
+				devSum = -m.sums[k]
+			}
+			count := int(m.counts[k])
+			if count < slopeOneMinCount {
+				continue
+			}
+			// (dev(j,i) + r_i) * c_ji = sum(r_j - r_i) + r_i * c_ji
+			numerator += devSum + rating*float64(count)
+			weight += count
+		}
+
+		if weight > 0 {
+			predictions[movieID] = numerator / float64(weight)
+		}
+	}
+
+	return predictions
+}
+
+// Recomendaciones Slope One para un usuario registrado
+func (dc *DistributedCoordinator) GetSlopeOneRecommendations(userID int, topN int) ([]RecommendationItem, error) {
+	dc.mu.RLock()
+	model, err := dc.slopeOne, dc.slopeOneErr
+	dc.mu.RUnlock()
+
+	if model == nil {
+		if err != nil {
+			return nil, fmt.Errorf("tabla Slope One no disponible: %v", err)
+		}
+		return nil, fmt.Errorf("tabla Slope One aún en construcción: %w", errModelNotReady)
+	}
+
+	dc.localDataset.mu.RLock()
+	userRatings := dc.localDataset.UserRatingsMap[userID]
+	dc.localDataset.mu.RUnlock()
+
+	if len(userRatings) == 0 {
+		return nil, requestError{fmt.Errorf("usuario no encontrado")}
+	}
+
+	return dc.rankPredictions(model.Predict(userRatings), topN), nil
+}
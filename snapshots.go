@@ -0,0 +1,324 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// SNAPSHOTS - Copias versionadas de la base de datos
+// ============================================================================
+// Cada snapshot es una copia compacta del log escrita en un temporal,
+// sincronizada y renombrada. manifest.json guarda de cada versión su tamaño,
+// número de claves y SHA-256; se conservan las últimas N y las más viejas se
+// borran. Restaurar verifica el checksum, guarda antes un snapshot del estado
+// actual y recarga usuarios, feedback y caché (el catálogo de películas se
+// mantiene, viene de los CSV). Solo con -store log: las bases SQL tienen sus
+// propias copias de seguridad.
+const (
+	defaultSnapshotKeep     = 5
+	defaultSnapshotInterval = 24 * time.Hour
+	snapshotManifest        = "manifest.json"
+	snapshotVersionFormat   = "20060102T150405.000Z"
+)
+
+type SnapshotInfo struct {
+	Version   string    `json:"version"`
+	File      string    `json:"file"`
+	CreatedAt time.Time `json:"created_at"`
+	Size      int64     `json:"size"`
+	Keys      int       `json:"keys"`
+	SHA256    string    `json:"sha256"`
+	Reason    string    `json:"reason,omitempty"`
+}
+
+// Directorio y retención de los snapshots
+func (db *Database) ConfigureSnapshots(dir string, keep int) {
+	if keep <= 0 {
+		keep = defaultSnapshotKeep
+	}
+	db.saveMu.Lock()
+	db.snapshotDir = dir
+	db.snapshotKeep = keep
+	db.saveMu.Unlock()
+}
+
+// Crear un snapshot nuevo y aplicar la retención
+func (db *Database) CreateSnapshot(reason string) (SnapshotInfo, error) {
+	db.saveMu.Lock()
+	defer db.saveMu.Unlock()
+	return db.createSnapshotLocked(reason, "")
+}
+
+// protect: versión que la retención no puede borrar (la que se restaura)
+func (db *Database) createSnapshotLocked(reason, protect string) (SnapshotInfo, error) {
+	memory, err := db.snapshotLog()
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+	if err := os.MkdirAll(db.snapshotDir, 0755); err != nil {
+		return SnapshotInfo{}, err
+	}
+
+	// Usuarios y caché actuales entran en el snapshot
+	if err := db.saveLocked(); err != nil {
+		return SnapshotInfo{}, err
+	}
+
+	snapshots, err := db.readManifest()
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+	now := time.Now().UTC()
+	version := db.newSnapshotVersion(now, snapshots)
+	file := "recsys-" + version + ".db"
+	path := filepath.Join(db.snapshotDir, file)
+	if err := memory.log.SnapshotTo(path); err != nil {
+		return SnapshotInfo{}, err
+	}
+
+	checksum, size, err := fileSHA256(path)
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+	info := SnapshotInfo{
+		Version:   version,
+		File:      file,
+		CreatedAt: now,
+		Size:      size,
+		Keys:      memory.log.Len(),
+		SHA256:    checksum,
+		Reason:    reason,
+	}
+	snapshots = append(snapshots, info)
+
+	// Retención: las más viejas se borran
+	var removed []SnapshotInfo
+	if len(snapshots) > db.snapshotKeep {
+		excess := len(snapshots) - db.snapshotKeep
+		kept := snapshots[:0]
+		for i, snapshot := range snapshots {
+			if i < excess && snapshot.Version != protect {
+				removed = append(removed, snapshot)
+				continue
+			}
+			kept = append(kept, snapshot)
+		}
+		snapshots = kept
+	}
+	if err := db.writeManifest(snapshots); err != nil {
+		return SnapshotInfo{}, err
+	}
+	for _, old := range removed {
+		os.Remove(filepath.Join(db.snapshotDir, old.File))
+	}
+
+	log.Printf("[DB] Snapshot %s creado: %d claves, %d bytes (%d conservados)",
+		version, info.Keys, size, len(snapshots))
+	return info, nil
+}
+
+// Versión con la hora de creación; si otro snapshot ya la usa (mismo
+// milisegundo) o su archivo existe, se añade un número de secuencia
+func (db *Database) newSnapshotVersion(now time.Time, snapshots []SnapshotInfo) string {
+	base := now.Format(snapshotVersionFormat)
+	used := make(map[string]bool, len(snapshots))
+	for _, snapshot := range snapshots {
+		used[snapshot.Version] = true
+	}
+	version := base
+	for sequence := 1; ; sequence++ {
+		if _, err := os.Stat(filepath.Join(db.snapshotDir, "recsys-"+version+".db")); !used[version] && os.IsNotExist(err) {
+			return version
+		}
+		version = fmt.Sprintf("%s-%d", base, sequence)
+	}
+}
+
+// Snapshots disponibles, del más viejo al más nuevo
+func (db *Database) ListSnapshots() ([]SnapshotInfo, error) {
+	db.saveMu.Lock()
+	defer db.saveMu.Unlock()
+	return db.readManifest()
+}
+
+// Los ratings en vivo ya están aplicados al dataset, las estadísticas y los
+// modelos: un snapshot con otros ratings dejaría el log distinto de la memoria
+var errRestoreRatings = fmt.Errorf("los ratings en vivo no coinciden con los del snapshot")
+
+var (
+	errSnapshotNotFound = fmt.Errorf("snapshot no encontrado")
+	// Limitación de la configuración (-store memory o SQL), no un fallo
+	errSnapshotsUnavailable = fmt.Errorf("snapshots no disponibles")
+)
+
+// Restaurar una versión; devuelve el snapshot de seguridad del estado previo.
+// Solo se restaura si el snapshot tiene los mismos ratings en vivo que el log.
+func (db *Database) RestoreSnapshot(version string) (SnapshotInfo, error) {
+	db.saveMu.Lock()
+	defer db.saveMu.Unlock()
+	db.ratingsMu.Lock()
+	defer db.ratingsMu.Unlock()
+
+	memory, err := db.snapshotLog()
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+	snapshots, err := db.readManifest()
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+	var target *SnapshotInfo
+	for i := range snapshots {
+		if snapshots[i].Version == version {
+			target = &snapshots[i]
+		}
+	}
+	if target == nil {
+		return SnapshotInfo{}, fmt.Errorf("%w: %s", errSnapshotNotFound, version)
+	}
+
+	path := filepath.Join(db.snapshotDir, target.File)
+	checksum, _, err := fileSHA256(path)
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+	if checksum != target.SHA256 {
+		return SnapshotInfo{}, fmt.Errorf("checksum inválido para %s: el archivo está dañado", version)
+	}
+	same, err := memory.sameRatings(path)
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+	if !same {
+		return SnapshotInfo{}, errRestoreRatings
+	}
+
+	backup, err := db.createSnapshotLocked("antes de restaurar "+version, version)
+	if err != nil {
+		return SnapshotInfo{}, fmt.Errorf("error creando snapshot de seguridad: %v", err)
+	}
+
+	if err := memory.Restore(path); err != nil {
+		return SnapshotInfo{}, err
+	}
+	if err := db.loadState(); err != nil {
+		return SnapshotInfo{}, err
+	}
+
+	// Las recomendaciones del snapshot no reflejan el feedback posterior: la
+	// caché se vacía después de cargar el estado, también en el log
+	db.cache().Purge()
+	if err := memory.Save(nil); err != nil {
+		return SnapshotInfo{}, fmt.Errorf("error vaciando la caché restaurada: %v", err)
+	}
+
+	log.Printf("[DB] Snapshot %s restaurado (estado anterior en %s)", version, backup.Version)
+	return backup, nil
+}
+
+// Snapshots periódicos (interval <= 0 los desactiva)
+func (db *Database) StartSnapshotTask(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	if _, err := db.snapshotLog(); err != nil {
+		log.Printf("[DB] Snapshots periódicos desactivados: %v", err)
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if _, err := db.CreateSnapshot("periódico"); err != nil {
+				log.Printf("[DB] Error creando snapshot: %v", err)
+			}
+		}
+	}()
+}
+
+// Los snapshots copian el log del almacenamiento por defecto
+func (db *Database) snapshotLog() (*MemoryStore, error) {
+	memory, ok := db.store.(*MemoryStore)
+	if !ok || memory.log == nil {
+		return nil, fmt.Errorf("%w con el almacenamiento %s", errSnapshotsUnavailable, db.store)
+	}
+	return memory, nil
+}
+
+func (db *Database) readManifest() ([]SnapshotInfo, error) {
+	data, err := os.ReadFile(filepath.Join(db.snapshotDir, snapshotManifest))
+	if os.IsNotExist(err) {
+		return []SnapshotInfo{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var snapshots []SnapshotInfo
+	if err := json.Unmarshal(data, &snapshots); err != nil {
+		return nil, fmt.Errorf("manifest de snapshots inválido: %v", err)
+	}
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		return snapshots[i].CreatedAt.Before(snapshots[j].CreatedAt)
+	})
+	return snapshots, nil
+}
+
+// Escribir el manifest con temporal, fsync y rename
+func (db *Database) writeManifest(snapshots []SnapshotInfo) error {
+	data, err := json.MarshalIndent(snapshots, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(db.snapshotDir, snapshotManifest)
+	if err := writeFileAtomic(path, data); err != nil {
+		return err
+	}
+	syncDir(db.snapshotDir)
+	return nil
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmpPath := path + ".tmp"
+	file, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+	}
+	return err
+}
+
+func fileSHA256(path string) (string, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
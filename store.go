@@ -0,0 +1,510 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// STORE - Almacenamiento intercambiable de la base de datos
+// ============================================================================
+// Database delega en un Store los usuarios, el catálogo persistido, los
+// ratings y el feedback recibidos en vivo y la caché de recomendaciones. El
+// dataset de ratings, los índices y el genome siguen en memoria. Se elige con
+// -store: log (mapas en memoria + log de solo anexado, por defecto), memory
+// (sin persistencia), sqlite o postgres (ver store_sql.go).
+const (
+	StoreLog      = "log"
+	StoreMemory   = "memory"
+	StoreSQLite   = "sqlite"
+	StorePostgres = "postgres"
+)
+
+var errStoreNotFound = fmt.Errorf("no encontrado")
+
+type Store interface {
+	// Usuarios: se devuelven copias, los cambios se guardan con UpsertUser
+	GetUser(userID int) (*User, error)
+	UpsertUser(user *User) error
+	UpsertUsers(users []*User) error             // en un solo lote (migración)
+	TouchUsers(accessed map[int]time.Time) error // última consulta, en un solo lote
+	UserCount() (int, error)
+
+	// Catálogo
+	GetMovie(movieID int) (*Movie, error)
+	UpsertMovies(movies []*Movie) error
+	Movies() ([]*Movie, error)
+	MovieCount() (int, error)
+	PutGenomeTags(tags []string) error // nombre de cada tag del genome, por tagId-1
+	GenomeTags() ([]string, error)
+
+	// Ratings y feedback en vivo: se escriben antes de confirmarlos
+	PutRating(rating StoredRating) error
+	Ratings() ([]StoredRating, error)
+	PutFeedback(event StoredFeedback) error
+	Feedback() ([]StoredFeedback, error)
+
+	// Caché de recomendaciones: Save la reemplaza completa y guarda lo pendiente
+	LoadCache() ([]CachedRecommendations, error)
+	Save(cache []CachedRecommendations) error
+
+	Close() error
+	String() string
+}
+
+type StoreConfig struct {
+	Kind   string // log, memory, sqlite o postgres
+	Path   string // archivo del log
+	DSN    string // conexión SQL
+	Schema string // esquema de Postgres; vacío = el de la conexión
+}
+
+// Configuración de un dataset adicional: el mismo almacenamiento en un
+// subdirectorio con su nombre (log, sqlite) o en su propio esquema (postgres)
+func (cfg StoreConfig) ForDataset(name string) (StoreConfig, error) {
+	cfg.Path = filepath.Join(filepath.Dir(cfg.Path), name, filepath.Base(cfg.Path))
+	switch cfg.Kind {
+	case StoreSQLite:
+		dsn := cfg.DSN
+		if dsn == "" {
+			dsn = defaultSQLiteDSN
+		}
+		if strings.HasPrefix(dsn, "file:") || strings.Contains(dsn, "?") {
+			return cfg, fmt.Errorf("con varios datasets -store-dsn de sqlite debe ser la ruta del archivo")
+		}
+		cfg.DSN = filepath.Join(filepath.Dir(dsn), name, filepath.Base(dsn))
+	case StorePostgres:
+		cfg.Schema = datasetSchema(name)
+	}
+	return cfg, nil
+}
+
+// Esquema de Postgres de un dataset. "-" y "_" dan el mismo esquema, así que
+// ParseDatasets rechaza los nombres que solo difieren en eso.
+func datasetSchema(name string) string {
+	return "dataset_" + strings.ReplaceAll(name, "-", "_")
+}
+
+// Abrir el almacenamiento indicado en la configuración
+func OpenStore(cfg StoreConfig) (Store, error) {
+	switch cfg.Kind {
+	case "", StoreLog:
+		return OpenMemoryStore(cfg.Path)
+	case StoreMemory:
+		return NewMemoryStore(), nil
+	case StoreSQLite, StorePostgres:
+		return OpenSQLStore(cfg)
+	}
+	return nil, fmt.Errorf("almacenamiento desconocido: %s (log, memory, sqlite o postgres)", cfg.Kind)
+}
+
+// MEMORY STORE
+// Mapas en memoria. Con log, usuarios, películas, ratings y feedback se
+// escriben (con fsync) antes de confirmarlos; la caché se reemplaza en Save.
+// Claves del log: user/<id>, movie/<id>, rating/<user>/<movie>,
+// feedback/<user>/<movie>, genome/tags y cache/<clave>. Como en SQL, cada
+// película guarda también su vector del genome (storedMovie).
+type MemoryStore struct {
+	users      map[int]*User
+	movies     map[int]*Movie
+	genomeTags []string
+	log        *KVStore // nil: solo memoria
+	mu         sync.RWMutex
+}
+
+const genomeTagsKey = "genome/tags"
+
+// Película en el log: Movie no serializa el genome en la API
+type storedMovie struct {
+	*Movie
+	Genome []float32 `json:"genome,omitempty"`
+}
+
+func userKey(userID int) string              { return fmt.Sprintf("user/%d", userID) }
+func movieKey(movieID int) string            { return fmt.Sprintf("movie/%d", movieID) }
+func ratingKey(userID, movieID int) string   { return fmt.Sprintf("rating/%d/%d", userID, movieID) }
+func feedbackKey(userID, movieID int) string { return fmt.Sprintf("feedback/%d/%d", userID, movieID) }
+
+// Agregar un valor JSON al lote
+func putJSON(batch *KVBatch, key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("%s: %v", key, err)
+	}
+	batch.Put(key, data)
+	return nil
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		users:  make(map[int]*User),
+		movies: make(map[int]*Movie),
+	}
+}
+
+// Abrir el log y cargar usuarios y películas
+func OpenMemoryStore(path string) (*MemoryStore, error) {
+	kv, err := OpenKVStore(path)
+	if err != nil {
+		return nil, err
+	}
+	store := NewMemoryStore()
+	store.log = kv
+	if err := store.load(); err != nil {
+		kv.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+func (s *MemoryStore) load() error {
+	users := make(map[int]*User)
+	movies := make(map[int]*Movie)
+
+	err := s.log.Scan("user/", func(key string, value []byte) error {
+		var user User
+		if err := json.Unmarshal(value, &user); err != nil {
+			return fmt.Errorf("%s: %v", key, err)
+		}
+		users[user.UserID] = &user
+		return nil
+	})
+	if err == nil {
+		err = s.log.Scan("movie/", func(key string, value []byte) error {
+			movie := storedMovie{Movie: &Movie{}}
+			if err := json.Unmarshal(value, &movie); err != nil {
+				return fmt.Errorf("%s: %v", key, err)
+			}
+			movie.Movie.Genome = movie.Genome
+			movies[movie.MovieID] = movie.Movie
+			return nil
+		})
+	}
+	if err != nil {
+		return err
+	}
+	var genomeTags []string
+	if data, exists, err := s.log.Get(genomeTagsKey); err != nil {
+		return err
+	} else if exists {
+		if err := json.Unmarshal(data, &genomeTags); err != nil {
+			return fmt.Errorf("%s: %v", genomeTagsKey, err)
+		}
+	}
+
+	s.mu.Lock()
+	s.users = users
+	s.movies = movies
+	s.genomeTags = genomeTags
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *MemoryStore) GetUser(userID int) (*User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	user, exists := s.users[userID]
+	if !exists {
+		return nil, errStoreNotFound
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (s *MemoryStore) UpsertUser(user *User) error {
+	copied := *user
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.writeUsers(&copied); err != nil {
+		return err
+	}
+	s.users[user.UserID] = &copied
+	return nil
+}
+
+func (s *MemoryStore) UpsertUsers(users []*User) error {
+	copies := make([]*User, len(users))
+	for i, user := range users {
+		copied := *user
+		copies[i] = &copied
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.writeUsers(copies...); err != nil {
+		return err
+	}
+	for _, user := range copies {
+		s.users[user.UserID] = user
+	}
+	return nil
+}
+
+// Escribir usuarios en el log en un lote; sin log no hace nada
+func (s *MemoryStore) writeUsers(users ...*User) error {
+	if s.log == nil || len(users) == 0 {
+		return nil
+	}
+	batch := &KVBatch{}
+	for _, user := range users {
+		if err := putJSON(batch, userKey(user.UserID), user); err != nil {
+			return err
+		}
+	}
+	return s.log.Write(batch)
+}
+
+// Usuarios que no existen se ignoran
+func (s *MemoryStore) TouchUsers(accessed map[int]time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	touched := make([]*User, 0, len(accessed))
+	for userID, at := range accessed {
+		if user, exists := s.users[userID]; exists && at.After(user.LastAccessed) {
+			copied := *user
+			copied.LastAccessed = at
+			touched = append(touched, &copied)
+		}
+	}
+	if err := s.writeUsers(touched...); err != nil {
+		return err
+	}
+	for _, user := range touched {
+		s.users[user.UserID] = user
+	}
+	return nil
+}
+
+func (s *MemoryStore) UserCount() (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.users), nil
+}
+
+func (s *MemoryStore) GetMovie(movieID int) (*Movie, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	movie, exists := s.movies[movieID]
+	if !exists {
+		return nil, errStoreNotFound
+	}
+	copied := *movie
+	return &copied, nil
+}
+
+// Películas en un solo lote
+func (s *MemoryStore) UpsertMovies(movies []*Movie) error {
+	copies := make([]*Movie, len(movies))
+	batch := &KVBatch{}
+	for i, movie := range movies {
+		copied := *movie
+		copies[i] = &copied
+		if s.log != nil {
+			if err := putJSON(batch, movieKey(movie.MovieID), storedMovie{&copied, copied.Genome}); err != nil {
+				return err
+			}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.log != nil {
+		if err := s.log.Write(batch); err != nil {
+			return err
+		}
+	}
+	for _, movie := range copies {
+		s.movies[movie.MovieID] = movie
+	}
+	return nil
+}
+
+func (s *MemoryStore) Movies() ([]*Movie, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	movies := make([]*Movie, 0, len(s.movies))
+	for _, movie := range s.movies {
+		copied := *movie
+		movies = append(movies, &copied)
+	}
+	sort.Slice(movies, func(i, j int) bool { return movies[i].MovieID < movies[j].MovieID })
+	return movies, nil
+}
+
+func (s *MemoryStore) MovieCount() (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.movies), nil
+}
+
+func (s *MemoryStore) PutGenomeTags(tags []string) error {
+	copied := append([]string(nil), tags...)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.log != nil {
+		batch := &KVBatch{}
+		if err := putJSON(batch, genomeTagsKey, copied); err != nil {
+			return err
+		}
+		if err := s.log.Write(batch); err != nil {
+			return err
+		}
+	}
+	s.genomeTags = copied
+	return nil
+}
+
+func (s *MemoryStore) GenomeTags() ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]string(nil), s.genomeTags...), nil
+}
+
+func (s *MemoryStore) PutRating(rating StoredRating) error {
+	if s.log == nil {
+		return nil
+	}
+	data, err := json.Marshal(rating)
+	if err != nil {
+		return err
+	}
+	return s.log.Put(ratingKey(rating.UserID, rating.MovieID), data)
+}
+
+func (s *MemoryStore) Ratings() ([]StoredRating, error) {
+	ratings := make([]StoredRating, 0)
+	if s.log == nil {
+		return ratings, nil
+	}
+	err := s.log.Scan("rating/", func(key string, value []byte) error {
+		var rating StoredRating
+		if err := json.Unmarshal(value, &rating); err != nil {
+			return fmt.Errorf("%s: %v", key, err)
+		}
+		ratings = append(ratings, rating)
+		return nil
+	})
+	return ratings, err
+}
+
+func (s *MemoryStore) PutFeedback(event StoredFeedback) error {
+	if s.log == nil {
+		return nil
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return s.log.Put(feedbackKey(event.UserID, event.MovieID), data)
+}
+
+func (s *MemoryStore) Feedback() ([]StoredFeedback, error) {
+	events := make([]StoredFeedback, 0)
+	if s.log == nil {
+		return events, nil
+	}
+	err := s.log.Scan("feedback/", func(key string, value []byte) error {
+		var event StoredFeedback
+		if err := json.Unmarshal(value, &event); err != nil {
+			return fmt.Errorf("%s: %v", key, err)
+		}
+		events = append(events, event)
+		return nil
+	})
+	return events, err
+}
+
+func (s *MemoryStore) LoadCache() ([]CachedRecommendations, error) {
+	cached := make([]CachedRecommendations, 0)
+	if s.log == nil {
+		return cached, nil
+	}
+	err := s.log.Scan("cache/", func(key string, value []byte) error {
+		var entry CachedRecommendations
+		if err := json.Unmarshal(value, &entry); err != nil {
+			return fmt.Errorf("%s: %v", key, err)
+		}
+		cached = append(cached, entry)
+		return nil
+	})
+	return cached, err
+}
+
+// Usuarios, películas, ratings y feedback ya están escritos: solo se
+// reemplaza la caché, en un único lote
+func (s *MemoryStore) Save(cache []CachedRecommendations) error {
+	if s.log == nil {
+		return nil
+	}
+
+	batch := &KVBatch{}
+	cached := make(map[string]bool)
+	for _, entry := range cache {
+		key := "cache/" + entry.Key.String()
+		cached[key] = true
+		if err := putJSON(batch, key, entry); err != nil {
+			return err
+		}
+	}
+	for _, key := range s.log.Keys("cache/") {
+		if !cached[key] {
+			batch.Delete(key)
+		}
+	}
+
+	return s.log.Write(batch)
+}
+
+// Reemplazar el log por una copia y recargar usuarios y películas
+func (s *MemoryStore) Restore(path string) error {
+	if err := s.log.ReplaceWith(path); err != nil {
+		return err
+	}
+	return s.load()
+}
+
+// Los ratings en vivo del log son los mismos que los del snapshot en path
+func (s *MemoryStore) sameRatings(path string) (bool, error) {
+	snapshot, err := OpenKVStoreReadOnly(path)
+	if err != nil {
+		return false, err
+	}
+	defer snapshot.Close()
+
+	keys := s.log.Keys("rating/")
+	if len(keys) != len(snapshot.Keys("rating/")) {
+		return false, nil
+	}
+	for _, key := range keys {
+		current, _, err := s.log.Get(key)
+		if err != nil {
+			return false, err
+		}
+		stored, exists, err := snapshot.Get(key)
+		if err != nil {
+			return false, err
+		}
+		if !exists || !bytes.Equal(current, stored) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (s *MemoryStore) Close() error {
+	if s.log == nil {
+		return nil
+	}
+	return s.log.Close()
+}
+
+func (s *MemoryStore) String() string {
+	if s.log == nil {
+		return StoreMemory
+	}
+	return StoreLog + " " + s.log.path
+}
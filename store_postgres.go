@@ -0,0 +1,6 @@
+//go:build postgres
+
+package main
+
+// Driver Postgres (pgx) para -store postgres
+import _ "github.com/jackc/pgx/v5/stdlib"
@@ -0,0 +1,538 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// SQL STORE - Usuarios y catálogo en una base relacional
+// ============================================================================
+// Implementación de Store sobre database/sql. El mismo SQL sirve para SQLite
+// y Postgres (INSERT ... ON CONFLICT DO UPDATE); con SQLite los parámetros $n
+// se reescriben a ?. Los drivers no entran en la compilación por defecto:
+// store_sqlite.go (modernc.org/sqlite, Go puro) y store_postgres.go (pgx) se
+// activan con -tags sqlite / -tags postgres (ver el Dockerfile). Del catálogo
+// se guarda todo lo que carga LoadMovies: tags y vector del genome en JSON y
+// los nombres del genome en genome_tags.
+var sqlDrivers = map[string]string{
+	StoreSQLite:   "sqlite",
+	StorePostgres: "pgx",
+}
+
+const defaultSQLiteDSN = "db/recsys.sqlite"
+
+var sqlSchema = []string{
+	`CREATE TABLE IF NOT EXISTS users (
+		user_id        BIGINT PRIMARY KEY,
+		ratings_count  INTEGER NOT NULL,
+		average_rating DOUBLE PRECISION NOT NULL,
+		top_genres     TEXT NOT NULL,
+		last_accessed  BIGINT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS movies (
+		movie_id BIGINT PRIMARY KEY,
+		title    TEXT NOT NULL,
+		genres   TEXT NOT NULL,
+		imdb_id  TEXT NOT NULL,
+		tmdb_id  BIGINT NOT NULL,
+		tags     TEXT NOT NULL DEFAULT '',
+		genome   TEXT NOT NULL DEFAULT ''
+	)`,
+	`CREATE TABLE IF NOT EXISTS genome_tags (
+		tag_id INTEGER PRIMARY KEY,
+		tag    TEXT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS ratings (
+		user_id  BIGINT NOT NULL,
+		movie_id BIGINT NOT NULL,
+		rating   DOUBLE PRECISION NOT NULL,
+		rated_at BIGINT NOT NULL,
+		PRIMARY KEY (user_id, movie_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS feedback (
+		user_id       BIGINT NOT NULL,
+		movie_id      BIGINT NOT NULL,
+		feedback_type TEXT NOT NULL,
+		created_at    BIGINT NOT NULL,
+		PRIMARY KEY (user_id, movie_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS recommendation_cache (
+		cache_key  TEXT PRIMARY KEY,
+		user_id    BIGINT NOT NULL,
+		entry      TEXT NOT NULL,
+		expires_at BIGINT NOT NULL
+	)`,
+}
+
+// Columnas agregadas después de crear la tabla: se añaden a las bases existentes
+var sqlAddedColumns = []struct{ table, column, definition string }{
+	{"movies", "tags", "TEXT NOT NULL DEFAULT ''"},
+	{"movies", "genome", "TEXT NOT NULL DEFAULT ''"},
+}
+
+var sqlParam = regexp.MustCompile(`\$\d+`)
+
+type SQLStore struct {
+	db   *sql.DB
+	kind string
+}
+
+// Conectar y crear las tablas que falten
+func OpenSQLStore(cfg StoreConfig) (*SQLStore, error) {
+	kind, dsn := cfg.Kind, cfg.DSN
+	driver := sqlDrivers[kind]
+	if !sqlDriverRegistered(driver) {
+		return nil, fmt.Errorf("driver %s no incluido en el binario: compile con -tags %s", driver, kind)
+	}
+	if dsn == "" {
+		if kind != StoreSQLite {
+			return nil, fmt.Errorf("%s requiere -store-dsn", kind)
+		}
+		dsn = defaultSQLiteDSN
+	}
+	if kind == StoreSQLite && !strings.HasPrefix(dsn, "file:") {
+		if err := os.MkdirAll(filepath.Dir(dsn), 0755); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Schema != "" {
+		// Todas las conexiones del pool usan el esquema del dataset
+		dsn = withSearchPath(dsn, cfg.Schema)
+	}
+
+	conn, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if kind == StoreSQLite {
+		// SQLite admite un solo escritor: se evita SQLITE_BUSY
+		conn.SetMaxOpenConns(1)
+	}
+	if err := conn.Ping(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error conectando a %s: %v", kind, err)
+	}
+
+	store := &SQLStore{db: conn, kind: kind}
+	if cfg.Schema != "" {
+		if _, err := conn.Exec("CREATE SCHEMA IF NOT EXISTS " + cfg.Schema); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("error creando el esquema %s: %v", cfg.Schema, err)
+		}
+	}
+	for _, statement := range sqlSchema {
+		if _, err := conn.Exec(statement); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("error creando el esquema: %v", err)
+		}
+	}
+	for _, added := range sqlAddedColumns {
+		if _, err := conn.Exec(fmt.Sprintf("SELECT %s FROM %s LIMIT 1", added.column, added.table)); err == nil {
+			continue
+		}
+		if _, err := conn.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", added.table, added.column, added.definition)); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("error agregando %s.%s: %v", added.table, added.column, err)
+		}
+	}
+	return store, nil
+}
+
+// Agregar search_path a un DSN de Postgres (URL o clave=valor)
+func withSearchPath(dsn, schema string) string {
+	if !strings.Contains(dsn, "://") {
+		return dsn + " search_path=" + schema
+	}
+	if strings.Contains(dsn, "?") {
+		return dsn + "&search_path=" + schema
+	}
+	return dsn + "?search_path=" + schema
+}
+
+func sqlDriverRegistered(name string) bool {
+	for _, driver := range sql.Drivers() {
+		if driver == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Placeholders $n para Postgres, ? para SQLite
+func (s *SQLStore) query(query string) string {
+	if s.kind == StoreSQLite {
+		return sqlParam.ReplaceAllString(query, "?")
+	}
+	return query
+}
+
+func (s *SQLStore) GetUser(userID int) (*User, error) {
+	user := &User{UserID: userID}
+	var topGenres string
+	var lastAccessed int64
+	err := s.db.QueryRow(s.query(`SELECT ratings_count, average_rating, top_genres, last_accessed
+		FROM users WHERE user_id = $1`), userID).
+		Scan(&user.RatingsCount, &user.AverageRating, &topGenres, &lastAccessed)
+	if err == sql.ErrNoRows {
+		return nil, errStoreNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	user.TopGenres = splitString(topGenres, '|')
+	user.LastAccessed = time.UnixMilli(lastAccessed)
+	return user, nil
+}
+
+const sqlUpsertUser = `INSERT INTO users (user_id, ratings_count, average_rating, top_genres, last_accessed)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (user_id) DO UPDATE SET
+		ratings_count = excluded.ratings_count,
+		average_rating = excluded.average_rating,
+		top_genres = excluded.top_genres,
+		last_accessed = excluded.last_accessed`
+
+func (s *SQLStore) UpsertUser(user *User) error {
+	_, err := s.db.Exec(s.query(sqlUpsertUser), user.UserID, user.RatingsCount, user.AverageRating,
+		strings.Join(user.TopGenres, "|"), user.LastAccessed.UnixMilli())
+	return err
+}
+
+// Todos los usuarios en una transacción
+func (s *SQLStore) UpsertUsers(users []*User) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statement, err := tx.Prepare(s.query(sqlUpsertUser))
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+
+	for _, user := range users {
+		if _, err := statement.Exec(user.UserID, user.RatingsCount, user.AverageRating,
+			strings.Join(user.TopGenres, "|"), user.LastAccessed.UnixMilli()); err != nil {
+			return fmt.Errorf("usuario %d: %v", user.UserID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// Una transacción con un UPDATE por usuario; no crea usuarios
+func (s *SQLStore) TouchUsers(accessed map[int]time.Time) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Cada $n una sola vez: con SQLite se reescriben a ? posicionales
+	statement, err := tx.Prepare(s.query(`UPDATE users SET last_accessed = $1
+		WHERE user_id = $2 AND last_accessed < $3`))
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+
+	for userID, at := range accessed {
+		if _, err := statement.Exec(at.UnixMilli(), userID, at.UnixMilli()); err != nil {
+			return fmt.Errorf("usuario %d: %v", userID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLStore) UserCount() (int, error) {
+	return s.count("users")
+}
+
+func (s *SQLStore) GetMovie(movieID int) (*Movie, error) {
+	movie := &Movie{MovieID: movieID}
+	var genres, tags, genome string
+	err := s.db.QueryRow(s.query(`SELECT title, genres, imdb_id, tmdb_id, tags, genome FROM movies WHERE movie_id = $1`), movieID).
+		Scan(&movie.Title, &genres, &movie.ImdbID, &movie.TmdbID, &tags, &genome)
+	if err == sql.ErrNoRows {
+		return nil, errStoreNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := decodeMovieColumns(movie, genres, tags, genome); err != nil {
+		return nil, err
+	}
+	return movie, nil
+}
+
+// Tags y genome en JSON; vacío si la película no tiene
+func encodeMovieExtras(movie *Movie) (string, string, error) {
+	tags, genome := "", ""
+	if len(movie.Tags) > 0 {
+		data, err := json.Marshal(movie.Tags)
+		if err != nil {
+			return "", "", err
+		}
+		tags = string(data)
+	}
+	if len(movie.Genome) > 0 {
+		data, err := json.Marshal(movie.Genome)
+		if err != nil {
+			return "", "", err
+		}
+		genome = string(data)
+	}
+	return tags, genome, nil
+}
+
+func decodeMovieColumns(movie *Movie, genres, tags, genome string) error {
+	movie.Genres = splitString(genres, '|')
+	if tags != "" {
+		if err := json.Unmarshal([]byte(tags), &movie.Tags); err != nil {
+			return fmt.Errorf("película %d, tags: %v", movie.MovieID, err)
+		}
+	}
+	if genome != "" {
+		if err := json.Unmarshal([]byte(genome), &movie.Genome); err != nil {
+			return fmt.Errorf("película %d, genome: %v", movie.MovieID, err)
+		}
+	}
+	return nil
+}
+
+// Películas en una sola transacción
+func (s *SQLStore) UpsertMovies(movies []*Movie) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statement, err := tx.Prepare(s.query(`INSERT INTO movies (movie_id, title, genres, imdb_id, tmdb_id, tags, genome)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (movie_id) DO UPDATE SET
+			title = excluded.title,
+			genres = excluded.genres,
+			imdb_id = excluded.imdb_id,
+			tmdb_id = excluded.tmdb_id,
+			tags = excluded.tags,
+			genome = excluded.genome`))
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+
+	for _, movie := range movies {
+		tags, genome, err := encodeMovieExtras(movie)
+		if err != nil {
+			return fmt.Errorf("película %d: %v", movie.MovieID, err)
+		}
+		if _, err := statement.Exec(movie.MovieID, movie.Title, strings.Join(movie.Genres, "|"),
+			movie.ImdbID, movie.TmdbID, tags, genome); err != nil {
+			return fmt.Errorf("película %d: %v", movie.MovieID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLStore) Movies() ([]*Movie, error) {
+	rows, err := s.db.Query(`SELECT movie_id, title, genres, imdb_id, tmdb_id, tags, genome FROM movies ORDER BY movie_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	movies := make([]*Movie, 0)
+	for rows.Next() {
+		movie := &Movie{}
+		var genres, tags, genome string
+		if err := rows.Scan(&movie.MovieID, &movie.Title, &genres, &movie.ImdbID, &movie.TmdbID, &tags, &genome); err != nil {
+			return nil, err
+		}
+		if err := decodeMovieColumns(movie, genres, tags, genome); err != nil {
+			return nil, err
+		}
+		movies = append(movies, movie)
+	}
+	return movies, rows.Err()
+}
+
+func (s *SQLStore) MovieCount() (int, error) {
+	return s.count("movies")
+}
+
+// Reemplazar los nombres del genome en una transacción
+func (s *SQLStore) PutGenomeTags(tags []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM genome_tags`); err != nil {
+		return err
+	}
+	statement, err := tx.Prepare(s.query(`INSERT INTO genome_tags (tag_id, tag) VALUES ($1, $2)`))
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+
+	for i, tag := range tags {
+		if _, err := statement.Exec(i+1, tag); err != nil {
+			return fmt.Errorf("tag %d: %v", i+1, err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLStore) GenomeTags() ([]string, error) {
+	rows, err := s.db.Query(`SELECT tag FROM genome_tags ORDER BY tag_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := make([]string, 0)
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+func (s *SQLStore) PutRating(rating StoredRating) error {
+	_, err := s.db.Exec(s.query(`INSERT INTO ratings (user_id, movie_id, rating, rated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, movie_id) DO UPDATE SET
+			rating = excluded.rating,
+			rated_at = excluded.rated_at`),
+		rating.UserID, rating.MovieID, rating.Rating, rating.Timestamp)
+	return err
+}
+
+func (s *SQLStore) Ratings() ([]StoredRating, error) {
+	rows, err := s.db.Query(`SELECT user_id, movie_id, rating, rated_at FROM ratings ORDER BY user_id, movie_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ratings := make([]StoredRating, 0)
+	for rows.Next() {
+		var rating StoredRating
+		if err := rows.Scan(&rating.UserID, &rating.MovieID, &rating.Rating, &rating.Timestamp); err != nil {
+			return nil, err
+		}
+		ratings = append(ratings, rating)
+	}
+	return ratings, rows.Err()
+}
+
+func (s *SQLStore) PutFeedback(event StoredFeedback) error {
+	_, err := s.db.Exec(s.query(`INSERT INTO feedback (user_id, movie_id, feedback_type, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, movie_id) DO UPDATE SET
+			feedback_type = excluded.feedback_type,
+			created_at = excluded.created_at`),
+		event.UserID, event.MovieID, event.Type, event.CreatedAt.UnixMilli())
+	return err
+}
+
+func (s *SQLStore) Feedback() ([]StoredFeedback, error) {
+	rows, err := s.db.Query(`SELECT user_id, movie_id, feedback_type, created_at FROM feedback`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]StoredFeedback, 0)
+	for rows.Next() {
+		var event StoredFeedback
+		var createdAt int64
+		if err := rows.Scan(&event.UserID, &event.MovieID, &event.Type, &createdAt); err != nil {
+			return nil, err
+		}
+		event.CreatedAt = time.UnixMilli(createdAt)
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+func (s *SQLStore) LoadCache() ([]CachedRecommendations, error) {
+	rows, err := s.db.Query(`SELECT cache_key, entry FROM recommendation_cache`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cached := make([]CachedRecommendations, 0)
+	for rows.Next() {
+		var key, data string
+		if err := rows.Scan(&key, &data); err != nil {
+			return nil, err
+		}
+		var entry CachedRecommendations
+		if err := json.Unmarshal([]byte(data), &entry); err != nil {
+			return nil, fmt.Errorf("caché %s: %v", key, err)
+		}
+		cached = append(cached, entry)
+	}
+	return cached, rows.Err()
+}
+
+// Usuarios, películas, ratings y feedback ya están escritos: solo se
+// reemplaza la caché, en una transacción
+func (s *SQLStore) Save(cache []CachedRecommendations) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM recommendation_cache`); err != nil {
+		return err
+	}
+	statement, err := tx.Prepare(s.query(`INSERT INTO recommendation_cache (cache_key, user_id, entry, expires_at)
+		VALUES ($1, $2, $3, $4)`))
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+
+	for _, entry := range cache {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		if _, err := statement.Exec(entry.Key.String(), entry.Key.UserID, string(data),
+			entry.ExpiresAt.UnixMilli()); err != nil {
+			return fmt.Errorf("caché %s: %v", entry.Key, err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLStore) count(table string) (int, error) {
+	var count int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&count)
+	return count, err
+}
+
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLStore) String() string {
+	return s.kind
+}
@@ -0,0 +1,241 @@
+//go:build sqlite
+
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// PRUEBAS DEL SQL STORE - Ida y vuelta sobre SQLite
+// ============================================================================
+// Necesitan el driver (-tags sqlite) y los archivos del coordinador:
+//
+//	go test -tags sqlite <archivos del coordinador del Dockerfile> store_sqlite.go store_sql_test.go
+
+func openTestSQLStore(t *testing.T, path string) *SQLStore {
+	t.Helper()
+	store, err := OpenSQLStore(StoreConfig{Kind: StoreSQLite, DSN: path})
+	if err != nil {
+		t.Fatalf("abriendo %s: %v", path, err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestSQLStoreUsers(t *testing.T) {
+	store := openTestSQLStore(t, filepath.Join(t.TempDir(), "recsys.sqlite"))
+
+	if _, err := store.GetUser(1); err != errStoreNotFound {
+		t.Fatalf("usuario inexistente: %v", err)
+	}
+
+	accessed := time.UnixMilli(time.Now().UnixMilli())
+	user := &User{UserID: 1, RatingsCount: 3, AverageRating: 3.5, TopGenres: []string{"Drama", "War"}, LastAccessed: accessed}
+	if err := store.UpsertUser(user); err != nil {
+		t.Fatal(err)
+	}
+	err := store.UpsertUsers([]*User{
+		{UserID: 2, RatingsCount: 1, AverageRating: 5, LastAccessed: accessed},
+		{UserID: 3, RatingsCount: 2, AverageRating: 2, LastAccessed: accessed},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := store.GetUser(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, user) {
+		t.Fatalf("usuario 1: %+v, se esperaba %+v", got, user)
+	}
+	if count, err := store.UserCount(); err != nil || count != 3 {
+		t.Fatalf("UserCount: %d, %v", count, err)
+	}
+
+	// TouchUsers solo adelanta la última consulta y no crea usuarios
+	later := accessed.Add(time.Hour)
+	err = store.TouchUsers(map[int]time.Time{1: later, 2: accessed.Add(-time.Hour), 99: later})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := store.GetUser(1); !got.LastAccessed.Equal(later) {
+		t.Errorf("usuario 1: last_accessed %v, se esperaba %v", got.LastAccessed, later)
+	}
+	if got, _ := store.GetUser(2); !got.LastAccessed.Equal(accessed) {
+		t.Errorf("usuario 2: last_accessed %v retrocedió", got.LastAccessed)
+	}
+	if _, err := store.GetUser(99); err != errStoreNotFound {
+		t.Errorf("TouchUsers creó el usuario 99: %v", err)
+	}
+}
+
+func TestSQLStoreMovies(t *testing.T) {
+	store := openTestSQLStore(t, filepath.Join(t.TempDir(), "recsys.sqlite"))
+
+	movies := []*Movie{
+		{
+			MovieID: 1, Title: "Toy Story (1995)", Genres: []string{"Animation", "Children"},
+			ImdbID: "0114709", TmdbID: 862,
+			Tags:   []TagCount{{Tag: "pixar", Count: 12}, {Tag: "fun", Count: 3}},
+			Genome: []float32{0.025, 0.5, 0.975},
+		},
+		{MovieID: 2, Title: "Jumanji (1995)", Genres: []string{"Adventure"}},
+	}
+	if err := store.UpsertMovies(movies); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.PutGenomeTags([]string{"007", "action", "pixar animation"}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := store.GetMovie(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, movies[0]) {
+		t.Fatalf("película 1: %+v, se esperaba %+v", got, movies[0])
+	}
+	if _, err := store.GetMovie(3); err != errStoreNotFound {
+		t.Fatalf("película inexistente: %v", err)
+	}
+
+	all, err := store.Movies()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 || !reflect.DeepEqual(all[1], movies[1]) {
+		t.Fatalf("Movies: %+v", all)
+	}
+	if count, err := store.MovieCount(); err != nil || count != 2 {
+		t.Fatalf("MovieCount: %d, %v", count, err)
+	}
+
+	// Una segunda carga reemplaza los campos, también los extras
+	updated := *movies[0]
+	updated.Tags, updated.Genome = nil, nil
+	if err := store.UpsertMovies([]*Movie{&updated}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := store.GetMovie(1); got.Tags != nil || got.Genome != nil {
+		t.Errorf("extras no reemplazados: %+v", got)
+	}
+
+	tags, err := store.GenomeTags()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tags, []string{"007", "action", "pixar animation"}) {
+		t.Fatalf("GenomeTags: %v", tags)
+	}
+}
+
+func TestSQLStoreRatingsAndFeedback(t *testing.T) {
+	store := openTestSQLStore(t, filepath.Join(t.TempDir(), "recsys.sqlite"))
+
+	for _, rating := range []StoredRating{{1, 10, 4, 100}, {1, 10, 2.5, 200}, {2, 10, 5, 300}} {
+		if err := store.PutRating(rating); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ratings, err := store.Ratings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []StoredRating{{1, 10, 2.5, 200}, {2, 10, 5, 300}}
+	if !reflect.DeepEqual(ratings, want) {
+		t.Fatalf("Ratings: %+v, se esperaba %+v", ratings, want)
+	}
+
+	created := time.UnixMilli(time.Now().UnixMilli())
+	event := StoredFeedback{UserID: 1, FeedbackEvent: FeedbackEvent{MovieID: 10, Type: FeedbackDismiss, CreatedAt: created}}
+	if err := store.PutFeedback(event); err != nil {
+		t.Fatal(err)
+	}
+	events, err := store.Feedback()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].UserID != 1 || events[0].MovieID != 10 ||
+		events[0].Type != FeedbackDismiss || !events[0].CreatedAt.Equal(created) {
+		t.Fatalf("Feedback: %+v", events)
+	}
+}
+
+func TestSQLStoreCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recsys.sqlite")
+	store := openTestSQLStore(t, path)
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	entry := func(userID int, items ...int) CachedRecommendations {
+		recs := make([]RecommendationItem, 0, len(items))
+		for _, movieID := range items {
+			recs = append(recs, RecommendationItem{MovieID: movieID, PredictedScore: 4.5})
+		}
+		return CachedRecommendations{
+			Key:       CacheKey{UserID: userID, Algorithm: AlgorithmKNN, TopN: 10},
+			Items:     recs,
+			CreatedAt: now,
+			ExpiresAt: now.Add(time.Hour),
+		}
+	}
+
+	if err := store.Save([]CachedRecommendations{entry(1, 10, 20), entry(2, 30)}); err != nil {
+		t.Fatal(err)
+	}
+	// Save reemplaza la caché completa
+	if err := store.Save([]CachedRecommendations{entry(2, 40)}); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	// Los datos sobreviven a reabrir la base
+	store = openTestSQLStore(t, path)
+	cached, err := store.LoadCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cached) != 1 || !reflect.DeepEqual(cached[0].Items, entry(2, 40).Items) ||
+		cached[0].Key != entry(2, 40).Key || !cached[0].ExpiresAt.Equal(now.Add(time.Hour)) {
+		t.Fatalf("LoadCache: %+v", cached)
+	}
+}
+
+// Una base creada antes de guardar tags y genome recibe las columnas al abrirla
+func TestSQLStoreAddsMovieColumns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recsys.sqlite")
+	conn, err := sql.Open(sqlDrivers[StoreSQLite], path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = conn.Exec(`CREATE TABLE movies (
+		movie_id BIGINT PRIMARY KEY,
+		title    TEXT NOT NULL,
+		genres   TEXT NOT NULL,
+		imdb_id  TEXT NOT NULL,
+		tmdb_id  BIGINT NOT NULL
+	)`)
+	if err == nil {
+		_, err = conn.Exec(`INSERT INTO movies VALUES (1, 'Toy Story (1995)', 'Animation', '', 0)`)
+	}
+	conn.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := openTestSQLStore(t, path)
+	movie, err := store.GetMovie(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if movie.Title != "Toy Story (1995)" || movie.Tags != nil || movie.Genome != nil {
+		t.Fatalf("película migrada: %+v", movie)
+	}
+	if err := store.UpsertMovies([]*Movie{{MovieID: 1, Title: movie.Title, Tags: []TagCount{{"pixar", 1}}}}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,6 @@
+//go:build sqlite
+
+package main
+
+// Driver SQLite en Go puro (sin cgo) para -store sqlite
+import _ "modernc.org/sqlite"
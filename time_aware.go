@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+)
+
+// RECOMENDACIONES SENSIBLES AL TIEMPO
+// ============================================================================
+// Modo "recent": el perfil del usuario se limita a sus ratings más recientes
+// y el peso de los ratings decae con la antigüedad.
+const (
+	TimeModeRecent          = "recent"
+	recentWindowDays        = 730 // ventana del perfil en modo recent
+	recentMinRatings        = 20  // si la ventana queda corta, se usan los N más recientes
+	recentDefaultHalfLife   = 180.0
+	secondsPerDay           = 86400
+	timeAwareMinProfileSize = 3
+)
+
+type TimeOptions struct {
+	AsOf         int64   // segundos Unix; 0 = sin corte
+	HalfLifeDays float64 // 0 = sin decaimiento
+	Mode         string  // "" o "recent"
+}
+
+// Indica si la solicitud pide algún comportamiento temporal
+func (opts TimeOptions) Enabled() bool {
+	return opts.AsOf > 0 || opts.HalfLifeDays > 0 || opts.Mode != ""
+}
+
+// Recomendaciones usando solo ratings anteriores a as_of, con decaimiento
+// temporal y, opcionalmente, limitadas a los gustos recientes del usuario
+func (dc *DistributedCoordinator) GetTimeAwareRecommendations(userID int, topN int, opts TimeOptions) ([]RecommendationItem, int, error) {
+	if opts.Mode != "" && opts.Mode != TimeModeRecent {
+		return nil, 0, requestError{fmt.Errorf("modo temporal desconocido: %s", opts.Mode)}
+	}
+
+	dc.localDataset.mu.RLock()
+	allRatings := dc.localDataset.UserRatingsMap[userID]
+	allTimestamps := dc.localDataset.UserTimestamps[userID]
+	reference := dc.localDataset.MaxTimestamp
+	dc.localDataset.mu.RUnlock()
+
+	if len(allRatings) == 0 {
+		return nil, 0, requestError{fmt.Errorf("usuario no encontrado")}
+	}
+
+	if opts.AsOf > 0 {
+		reference = opts.AsOf
+	}
+	halfLife := opts.HalfLifeDays
+	if opts.Mode == TimeModeRecent && halfLife <= 0 {
+		halfLife = recentDefaultHalfLife
+	}
+
+	tc := &TimeContext{
+		AsOf:         opts.AsOf,
+		HalfLifeDays: halfLife,
+		Reference:    reference,
+	}
+
+	// Perfil del usuario dentro de la ventana temporal
+	ratings := make(map[int]float64)
+	timestamps := make(map[int]int64)
+	for movieID, rating := range allRatings {
+		ts := allTimestamps[movieID]
+		if tc.Includes(ts) {
+			ratings[movieID] = rating
+			timestamps[movieID] = ts
+		}
+	}
+
+	// Lo visto antes de as_of se excluye aunque no forme parte del perfil reciente
+	seen := ratings
+	if opts.Mode == TimeModeRecent {
+		ratings, timestamps = recentProfile(ratings, timestamps, reference)
+	}
+
+	if len(ratings) < timeAwareMinProfileSize {
+		return nil, 0, requestError{fmt.Errorf("usuario con %d ratings en el periodo solicitado", len(ratings))}
+	}
+
+	sum := 0.0
+	for _, rating := range ratings {
+		sum += rating
+	}
+	avg := sum / float64(len(ratings))
+
+	req := SimilarityRequest{
+		TargetUserID:     userID,
+		TargetRatings:    ratings,
+		TargetAvg:        avg,
+		K:                neighbourCount,
+		SampleSize:       workerSampleSize,
+		Time:             tc,
+		TargetTimestamps: timestamps,
+	}
+
+	similarUsers, activeWorkers := dc.searchNeighbours(req)
+
+	scores := dc.predictScoresAt(seen, avg, similarUsers, tc)
+
+	log.Printf("[COORD] Recomendaciones temporales usuario %d: perfil %d/%d ratings, as_of=%d, vida media %.0f días",
+		userID, len(ratings), len(allRatings), opts.AsOf, halfLife)
+
+	return dc.rankPredictions(scores, topN), activeWorkers, nil
+}
+
+// Quedarse con los ratings de la ventana reciente, o con los más recientes
+// si la ventana tiene muy pocos
+func recentProfile(ratings map[int]float64, timestamps map[int]int64, reference int64) (map[int]float64, map[int]int64) {
+	cutoff := reference - recentWindowDays*secondsPerDay
+
+	recent := make(map[int]float64)
+	recentTs := make(map[int]int64)
+	for movieID, rating := range ratings {
+		if timestamps[movieID] >= cutoff {
+			recent[movieID] = rating
+			recentTs[movieID] = timestamps[movieID]
+		}
+	}
+	if len(recent) >= recentMinRatings || len(recent) == len(ratings) {
+		return recent, recentTs
+	}
+
+	movieIDs := make([]int, 0, len(ratings))
+	for movieID := range ratings {
+		movieIDs = append(movieIDs, movieID)
+	}
+	sort.Slice(movieIDs, func(i, j int) bool {
+		return timestamps[movieIDs[i]] > timestamps[movieIDs[j]]
+	})
+	if len(movieIDs) > recentMinRatings {
+		movieIDs = movieIDs[:recentMinRatings]
+	}
+
+	for _, movieID := range movieIDs {
+		recent[movieID] = ratings[movieID]
+		recentTs[movieID] = timestamps[movieID]
+	}
+	return recent, recentTs
+}
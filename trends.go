@@ -0,0 +1,274 @@
+package main
+
+import (
+	"sort"
+	"sync"
+)
+
+// TENDENCIAS Y POPULARIDAD - Índice incremental por película
+// ============================================================================
+// Los contadores se actualizan con cada rating ingerido (carga inicial o en
+// vivo), así que consultar tendencias nunca recorre todos los ratings. El
+// "ahora" de las ventanas es el día del rating más reciente del dataset, ya
+// que es histórico. Los ratings en vivo no lo mueven: uno posterior cuenta
+// en ese día, así que no borra el histórico de las ventanas.
+const (
+	maxTrendWindowDays = 90
+	bayesianPriorVotes = 50.0 // votos ficticios con el promedio global
+)
+
+type movieTotals struct {
+	Count int
+	Sum   float64
+}
+
+type MovieTrends struct {
+	stats     *MovieStatsIndex               // acumulados históricos
+	days      map[int64]map[int]*movieTotals // día -> movieID -> acumulado del día
+	latestDay int64                          // solo lo mueven los ratings del dataset
+	mu        sync.RWMutex
+}
+
+type TrendingMovie struct {
+	MovieID       int      `json:"movie_id"`
+	Title         string   `json:"title"`
+	Genres        []string `json:"genres"`
+	WindowCount   int      `json:"window_count"`
+	PreviousCount int      `json:"previous_window_count"`
+	Growth        float64  `json:"growth"`
+	RatingsCount  int      `json:"ratings_count"`
+	AverageRating float64  `json:"average_rating"`
+	BayesianAvg   float64  `json:"bayesian_average"`
+}
+
+func NewMovieTrends(stats *MovieStatsIndex) *MovieTrends {
+	return &MovieTrends{
+		stats: stats,
+		days:  make(map[int64]map[int]*movieTotals),
+	}
+}
+
+// Intercambiar los contadores diarios con otro índice; cada uno conserva su
+// MovieStatsIndex, que se intercambia por separado
+func (t *MovieTrends) swap(other *MovieTrends) {
+	t.mu.Lock()
+	other.mu.Lock()
+	t.days, other.days = other.days, t.days
+	t.latestDay, other.latestDay = other.latestDay, t.latestDay
+	other.mu.Unlock()
+	t.mu.Unlock()
+}
+
+// Registrar un rating en su día (timestamp 0 = desconocido, no entra en
+// ninguna ventana). live indica un rating recibido por la API. El histórico
+// lo lleva MovieStatsIndex.
+func (t *MovieTrends) Record(movieID int, rating float64, timestamp int64, live bool) {
+	if timestamp <= 0 {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.record(movieID, rating, timestamp, live)
+}
+
+// Un usuario cambió su rating: el anterior sale de su día (si sigue en las
+// ventanas) y el nuevo entra en el suyo
+func (t *MovieTrends) Replace(movieID int, oldRating float64, oldTimestamp int64, newRating float64, newTimestamp int64, live bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if oldTimestamp > 0 {
+		// Un rating en vivo posterior al dataset se contó en latestDay
+		day := oldTimestamp / secondsPerDay
+		if day > t.latestDay {
+			day = t.latestDay
+		}
+		if stats := t.days[day][movieID]; stats != nil && stats.Count > 0 {
+			stats.Count--
+			stats.Sum -= oldRating
+			if stats.Count == 0 {
+				delete(t.days[day], movieID)
+			}
+		}
+	}
+	if newTimestamp > 0 {
+		t.record(movieID, newRating, newTimestamp, live)
+	}
+}
+
+func (t *MovieTrends) record(movieID int, rating float64, timestamp int64, live bool) {
+	day := timestamp / secondsPerDay
+	if live && day > t.latestDay {
+		day = t.latestDay
+	}
+	if day <= t.latestDay-2*maxTrendWindowDays {
+		return
+	}
+
+	dayStats := t.days[day]
+	if dayStats == nil {
+		dayStats = make(map[int]*movieTotals)
+		t.days[day] = dayStats
+	}
+	stats := dayStats[movieID]
+	if stats == nil {
+		stats = &movieTotals{}
+		dayStats[movieID] = stats
+	}
+	stats.Count++
+	stats.Sum += rating
+
+	if day > t.latestDay {
+		t.latestDay = day
+		// Se guardan dos ventanas máximas para poder calcular el crecimiento
+		for d := range t.days {
+			if d <= t.latestDay-2*maxTrendWindowDays {
+				delete(t.days, d)
+			}
+		}
+	}
+}
+
+func bayesianAverage(sum float64, count int, prior float64) float64 {
+	return (bayesianPriorVotes*prior + sum) / (bayesianPriorVotes + float64(count))
+}
+
+// Acumular por película los días (from, to]
+func (t *MovieTrends) windowTotals(from, to int64) map[int]*movieTotals {
+	result := make(map[int]*movieTotals)
+	for day, dayStats := range t.days {
+		if day <= from || day > to {
+			continue
+		}
+		for movieID, stats := range dayStats {
+			acc := result[movieID]
+			if acc == nil {
+				acc = &movieTotals{}
+				result[movieID] = acc
+			}
+			acc.Count += stats.Count
+			acc.Sum += stats.Sum
+		}
+	}
+	return result
+}
+
+// Películas con más ratings en los últimos windowDays días
+func (t *MovieTrends) Trending(windowDays int, include func(movieID int) bool, limit int) []TrendingMovie {
+	if windowDays <= 0 || windowDays > maxTrendWindowDays {
+		windowDays = maxTrendWindowDays
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	window := int64(windowDays)
+	current := t.windowTotals(t.latestDay-window, t.latestDay)
+	previous := t.windowTotals(t.latestDay-2*window, t.latestDay-window)
+	prior := t.stats.GlobalAverage()
+
+	results := make([]TrendingMovie, 0, len(current))
+	for movieID, stats := range current {
+		if include != nil && !include(movieID) {
+			continue
+		}
+		prevCount := 0
+		if prev, exists := previous[movieID]; exists {
+			prevCount = prev.Count
+		}
+		results = append(results, t.describe(movieID, stats, prevCount, prior))
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].WindowCount != results[j].WindowCount {
+			return results[i].WindowCount > results[j].WindowCount
+		}
+		return results[i].MovieID < results[j].MovieID
+	})
+
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// Películas mejor valoradas (promedio bayesiano), históricas o de una ventana
+func (t *MovieTrends) Popular(windowDays int, minRatings int, include func(movieID int) bool, limit int) []TrendingMovie {
+	if windowDays <= 0 {
+		return t.popularAllTime(minRatings, include, limit)
+	}
+	if windowDays > maxTrendWindowDays {
+		windowDays = maxTrendWindowDays
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	source := t.windowTotals(t.latestDay-int64(windowDays), t.latestDay)
+	prior := t.stats.GlobalAverage()
+
+	results := make([]TrendingMovie, 0)
+	for movieID, stats := range source {
+		if stats.Count < minRatings {
+			continue
+		}
+		if include != nil && !include(movieID) {
+			continue
+		}
+		item := t.describe(movieID, stats, 0, prior)
+		item.BayesianAvg = bayesianAverage(stats.Sum, stats.Count, prior)
+		results = append(results, item)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].BayesianAvg != results[j].BayesianAvg {
+			return results[i].BayesianAvg > results[j].BayesianAvg
+		}
+		return results[i].MovieID < results[j].MovieID
+	})
+
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// Ranking histórico: recorre el orden cacheado de MovieStatsIndex hasta
+// completar limit, sin ordenar el catálogo en cada consulta. El orden puede
+// ir algo atrasado; los conteos y promedios devueltos son los actuales.
+func (t *MovieTrends) popularAllTime(minRatings int, include func(movieID int) bool, limit int) []TrendingMovie {
+	prior := t.stats.GlobalAverage()
+	results := make([]TrendingMovie, 0)
+	for _, movieID := range t.stats.Ranking() {
+		if len(results) >= limit {
+			break
+		}
+		total, exists := t.stats.Get(movieID)
+		if !exists || total.Count < minRatings {
+			continue
+		}
+		if include != nil && !include(movieID) {
+			continue
+		}
+		results = append(results, t.describe(movieID, &movieTotals{Count: total.Count, Sum: total.Sum}, 0, prior))
+	}
+	return results
+}
+
+func (t *MovieTrends) describe(movieID int, window *movieTotals, prevCount int, prior float64) TrendingMovie {
+	item := TrendingMovie{
+		MovieID:       movieID,
+		WindowCount:   window.Count,
+		PreviousCount: prevCount,
+	}
+	if prevCount > 0 {
+		item.Growth = float64(window.Count-prevCount) / float64(prevCount)
+	}
+	if total, exists := t.stats.Get(movieID); exists && total.Count > 0 {
+		item.RatingsCount = total.Count
+		item.AverageRating = total.Average()
+		item.BayesianAvg = bayesianAverage(total.Sum, total.Count, prior)
+	}
+	return item
+}
@@ -1,5 +1,12 @@
 package main
 
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+)
+
 // TIPOS COMPARTIDOS - Sistema Distribuido
 // ============================================================================
 // Solicitud que el coordinador envía a los workers
@@ -9,6 +16,14 @@ type SimilarityRequest struct {
 	TargetAvg     float64         `json:"target_avg"`
 	K             int             `json:"k"`
 	SampleSize    int             `json:"sample_size"`
+
+	// Opcionales: recomendaciones sensibles al tiempo
+	Time             *TimeContext  `json:"time,omitempty"`
+	TargetTimestamps map[int]int64 `json:"target_timestamps,omitempty"`
+
+	// Dataset del worker al que va la solicitud (cualquier operación);
+	// obligatorio, el worker rechaza las solicitudes sin nombre
+	Dataset string `json:"dataset"`
 }
 
 // Respuesta que los workers envían al coordinador
@@ -26,3 +41,221 @@ type SimilarityResult struct {
 	UserID     int     `json:"user_id"`
 	Similarity float64 `json:"similarity"`
 }
+
+// Similitud coseno centrada en el promedio de cada usuario sobre las
+// películas en común; devuelve 0 con menos de 3 en común
+func CenteredCosine(vec1, vec2 map[int]float64, avg1, avg2 float64) (float64, int) {
+	dotProduct := 0.0
+	norm1 := 0.0
+	norm2 := 0.0
+	commonCount := 0
+
+	for movieID, rating1 := range vec1 {
+		rating2, exists := vec2[movieID]
+		if !exists {
+			continue
+		}
+		commonCount++
+
+		r1 := rating1 - avg1
+		r2 := rating2 - avg2
+		dotProduct += r1 * r2
+		norm1 += r1 * r1
+		norm2 += r2 * r2
+	}
+
+	if commonCount < 3 || norm1 == 0 || norm2 == 0 {
+		return 0.0, commonCount
+	}
+
+	return dotProduct / (math.Sqrt(norm1) * math.Sqrt(norm2)), commonCount
+}
+
+// Similitud coseno con filtro as_of y decaimiento temporal. Los ratings del
+// objetivo ya vienen filtrados por el coordinador.
+func TimeWeightedCosine(vec1 map[int]float64, ts1 map[int]int64, vec2 map[int]float64, ts2 map[int]int64, avg1 float64, tc *TimeContext) (float64, int) {
+	// Promedio del candidato solo con ratings dentro de la ventana
+	sum := 0.0
+	included := 0
+	for movieID, rating := range vec2 {
+		if tc.Includes(ts2[movieID]) {
+			sum += rating
+			included++
+		}
+	}
+	if included == 0 {
+		return 0.0, 0
+	}
+	avg2 := sum / float64(included)
+
+	dotProduct := 0.0
+	norm1 := 0.0
+	norm2 := 0.0
+	commonCount := 0
+
+	for movieID, rating1 := range vec1 {
+		rating2, exists := vec2[movieID]
+		if !exists || !tc.Includes(ts2[movieID]) {
+			continue
+		}
+		commonCount++
+
+		weight := math.Sqrt(tc.Weight(ts1[movieID]) * tc.Weight(ts2[movieID]))
+		r1 := rating1 - avg1
+		r2 := rating2 - avg2
+		dotProduct += weight * r1 * r2
+		norm1 += weight * r1 * r1
+		norm2 += weight * r2 * r2
+	}
+
+	if commonCount < 3 || norm1 == 0 || norm2 == 0 {
+		return 0.0, commonCount
+	}
+
+	return dotProduct / (math.Sqrt(norm1) * math.Sqrt(norm2)), commonCount
+}
+
+// Parámetros temporales de una solicitud. Los timestamps son segundos Unix
+// (columna timestamp de ratings.csv); 0 significa desconocido.
+type TimeContext struct {
+	AsOf         int64   `json:"as_of,omitempty"`          // solo ratings hasta este instante
+	HalfLifeDays float64 `json:"half_life_days,omitempty"` // vida media del peso de un rating
+	Reference    int64   `json:"reference"`                // instante desde el que se mide la antigüedad
+}
+
+// Indica si un rating con este timestamp entra en el cálculo
+func (tc *TimeContext) Includes(ts int64) bool {
+	if tc == nil || tc.AsOf <= 0 || ts == 0 {
+		return true
+	}
+	return ts <= tc.AsOf
+}
+
+// Peso por decaimiento exponencial: 1 para un rating del instante de
+// referencia, 0.5 tras HalfLifeDays días
+func (tc *TimeContext) Weight(ts int64) float64 {
+	if tc == nil || tc.HalfLifeDays <= 0 || ts == 0 {
+		return 1.0
+	}
+	ageDays := float64(tc.Reference-ts) / 86400.0
+	if ageDays < 0 {
+		ageDays = 0
+	}
+	return math.Pow(0.5, ageDays/tc.HalfLifeDays)
+}
+
+// Operaciones que acepta un worker. Una solicitud sin "op" es de similitud,
+// así que el formato original sigue siendo válido.
+const (
+	OpSimilarity = ""
+	OpDeviations = "deviations"
+	OpReload     = "reload"
+)
+
+// Solicitud genérica al worker: la de similitud más la operación
+type WorkerRequest struct {
+	SimilarityRequest
+	Op         string            `json:"op,omitempty"`
+	Deviations *DeviationRequest `json:"deviations,omitempty"`
+	Reload     *ReloadRequest    `json:"reload,omitempty"`
+}
+
+// Fases de la recarga de una partición: prepare la carga aparte, commit la
+// pone en uso y rollback descarta lo preparado o, si la versión indicada es
+// la activa, vuelve a la anterior
+const (
+	ReloadPrepare  = "prepare"
+	ReloadCommit   = "commit"
+	ReloadRollback = "rollback"
+)
+
+// Versión del dataset cargado al arrancar
+const initialDatasetVersion = "initial"
+
+type ReloadRequest struct {
+	Phase     string `json:"phase"`
+	Version   string `json:"version"`
+	Partition string `json:"partition,omitempty"` // solo en prepare
+}
+
+// Comprobar que path (directorio o archivo de una recarga) está dentro de
+// root, ya resueltos los enlaces simbólicos
+func WithinDir(root, path string) error {
+	resolvedRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return fmt.Errorf("directorio raíz %s: %v", root, err)
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return err
+	}
+	if resolvedRoot, err = filepath.Abs(resolvedRoot); err != nil {
+		return err
+	}
+	if resolved, err = filepath.Abs(resolved); err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(resolvedRoot, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("%s está fuera de %s", path, root)
+	}
+	return nil
+}
+
+// Estado del worker tras la fase: versión activa (o preparada, en prepare)
+type ReloadResponse struct {
+	WorkerID string `json:"worker_id"`
+	Version  string `json:"version"`
+	Users    int    `json:"users"`
+	Ratings  int    `json:"ratings"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Slope One: desviaciones entre pares de estas películas
+type DeviationRequest struct {
+	MovieIDs []int `json:"movie_ids"`
+}
+
+// Sumas de (r_i - r_j) y número de usuarios por par i<j, en el orden de
+// DeviationPairIndex sobre MovieIDs
+type DeviationResponse struct {
+	WorkerID    string    `json:"worker_id"`
+	Sums        []float64 `json:"sums"`
+	Counts      []int32   `json:"counts"`
+	ProcessTime float64   `json:"process_time_ms"`
+}
+
+// Posición del par (a, b), a < b, en la matriz triangular de n películas
+func DeviationPairIndex(a, b, n int) int {
+	return a*(2*n-a-1)/2 + (b - a - 1)
+}
+
+// Sumar las desviaciones de los ratings de un usuario. index traduce
+// movieID a su posición en la lista de películas.
+func AccumulateDeviations(ratings map[int]float64, index map[int]int, sums []float64, counts []int32) {
+	type positioned struct {
+		pos    int
+		rating float64
+	}
+	rated := make([]positioned, 0)
+	for movieID, rating := range ratings {
+		if pos, exists := index[movieID]; exists {
+			rated = append(rated, positioned{pos, rating})
+		}
+	}
+
+	n := len(index)
+	for x, a := range rated {
+		for _, b := range rated[x+1:] {
+			if a.pos < b.pos {
+				k := DeviationPairIndex(a.pos, b.pos, n)
+				sums[k] += a.rating - b.rating
+				counts[k]++
+			} else {
+				k := DeviationPairIndex(b.pos, a.pos, n)
+				sums[k] += b.rating - a.rating
+				counts[k]++
+			}
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// PERFIL DE GUSTOS DEL USUARIO
+// ============================================================================
+// La afinidad por género es la desviación media respecto al promedio del
+// usuario, con shrinkage hacia 0 para géneros con pocos ratings.
+const (
+	genreAffinityShrinkage = 5.0
+	topGenresCount         = 3
+	profileTopMovies       = 10
+)
+
+type UserProfile struct {
+	User
+	GenreAffinity      []GenreAffinity    `json:"genre_affinity"`
+	RatingDistribution map[string]int     `json:"rating_distribution"`
+	FavouriteDecades   []DecadeAffinity   `json:"favourite_decades"`
+	MostRatedMovies    []ProfileMovieItem `json:"most_rated_movies"` // valoradas más alto por el usuario
+}
+
+type GenreAffinity struct {
+	Genre         string  `json:"genre"`
+	Affinity      float64 `json:"affinity"`
+	RatingsCount  int     `json:"ratings_count"`
+	AverageRating float64 `json:"average_rating"`
+}
+
+type DecadeAffinity struct {
+	Decade        int     `json:"decade"`
+	RatingsCount  int     `json:"ratings_count"`
+	AverageRating float64 `json:"average_rating"`
+	Affinity      float64 `json:"affinity"`
+}
+
+type ProfileMovieItem struct {
+	MovieID int     `json:"movie_id"`
+	Title   string  `json:"title"`
+	Rating  float64 `json:"rating"`
+}
+
+// Perfil completo de un usuario a partir de sus ratings y el catálogo
+func (db *Database) GetUserProfile(userID int) (*UserProfile, error) {
+	user, err := db.GetUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	userRatings := db.Ratings[userID]
+	if len(userRatings) == 0 {
+		return nil, fmt.Errorf("usuario sin ratings")
+	}
+
+	profile := &UserProfile{
+		User:               *user,
+		GenreAffinity:      db.genreAffinity(userRatings, user.AverageRating),
+		RatingDistribution: make(map[string]int),
+		FavouriteDecades:   make([]DecadeAffinity, 0),
+		MostRatedMovies:    make([]ProfileMovieItem, 0, profileTopMovies),
+	}
+
+	// Distribución de ratings por media estrella
+	var distribution MovieStats
+	for _, rating := range userRatings {
+		distribution.Histogram[histogramBucket(rating)]++
+	}
+	profile.RatingDistribution = distribution.HistogramMap()
+
+	// Décadas favoritas según el año del título
+	decadeSums := make(map[int]float64)
+	decadeCounts := make(map[int]int)
+	for movieID, rating := range userRatings {
+		movie, exists := db.Movies[movieID]
+		if !exists {
+			continue
+		}
+		year := movieYear(movie.Title)
+		if year == 0 {
+			continue
+		}
+		decade := year / 10 * 10
+		decadeSums[decade] += rating
+		decadeCounts[decade]++
+	}
+	for decade, count := range decadeCounts {
+		avg := decadeSums[decade] / float64(count)
+		profile.FavouriteDecades = append(profile.FavouriteDecades, DecadeAffinity{
+			Decade:        decade,
+			RatingsCount:  count,
+			AverageRating: avg,
+			Affinity:      (decadeSums[decade] - user.AverageRating*float64(count)) / (float64(count) + genreAffinityShrinkage),
+		})
+	}
+	sort.Slice(profile.FavouriteDecades, func(i, j int) bool {
+		return profile.FavouriteDecades[i].Affinity > profile.FavouriteDecades[j].Affinity
+	})
+
+	// Películas mejor valoradas por el usuario; empates por popularidad
+	movieIDs := make([]int, 0, len(userRatings))
+	for movieID := range userRatings {
+		movieIDs = append(movieIDs, movieID)
+	}
+	popularity := make(map[int]int, len(movieIDs))
+	for _, movieID := range movieIDs {
+		if stats, exists := db.MovieStats.Get(movieID); exists {
+			popularity[movieID] = stats.Count
+		}
+	}
+	sort.Slice(movieIDs, func(i, j int) bool {
+		ri, rj := userRatings[movieIDs[i]], userRatings[movieIDs[j]]
+		if ri != rj {
+			return ri > rj
+		}
+		return popularity[movieIDs[i]] > popularity[movieIDs[j]]
+	})
+	for _, movieID := range movieIDs {
+		if len(profile.MostRatedMovies) >= profileTopMovies {
+			break
+		}
+		title := "Unknown"
+		if movie, exists := db.Movies[movieID]; exists {
+			title = movie.Title
+		}
+		profile.MostRatedMovies = append(profile.MostRatedMovies, ProfileMovieItem{
+			MovieID: movieID,
+			Title:   title,
+			Rating:  userRatings[movieID],
+		})
+	}
+
+	return profile, nil
+}
+
+// Afinidad por género (rating centrado en el promedio del usuario).
+// Se llama con db.mu tomado.
+func (db *Database) genreAffinity(userRatings map[int]float64, userAvg float64) []GenreAffinity {
+	deviations := make(map[string]float64)
+	sums := make(map[string]float64)
+	counts := make(map[string]int)
+
+	for movieID, rating := range userRatings {
+		movie, exists := db.Movies[movieID]
+		if !exists {
+			continue
+		}
+		for _, genre := range movie.Genres {
+			deviations[genre] += rating - userAvg
+			sums[genre] += rating
+			counts[genre]++
+		}
+	}
+
+	affinities := make([]GenreAffinity, 0, len(counts))
+	for genre, count := range counts {
+		affinities = append(affinities, GenreAffinity{
+			Genre:         genre,
+			Affinity:      deviations[genre] / (float64(count) + genreAffinityShrinkage),
+			RatingsCount:  count,
+			AverageRating: sums[genre] / float64(count),
+		})
+	}
+
+	sort.Slice(affinities, func(i, j int) bool {
+		if affinities[i].Affinity != affinities[j].Affinity {
+			return affinities[i].Affinity > affinities[j].Affinity
+		}
+		return affinities[i].Genre < affinities[j].Genre
+	})
+
+	return affinities
+}
+
+// Géneros con mayor afinidad positiva. Se llama con db.mu tomado.
+func (db *Database) topGenres(userRatings map[int]float64, userAvg float64) []string {
+	top := make([]string, 0, topGenresCount)
+	for _, affinity := range db.genreAffinity(userRatings, userAvg) {
+		if len(top) >= topGenresCount || affinity.Affinity <= 0 {
+			break
+		}
+		top = append(top, affinity.Genre)
+	}
+	return top
+}
+
+// Número de ratings, promedio y TopGenres. Se llama con db.mu tomado (lectura).
+func (db *Database) setUserStats(user *User, userRatings map[int]float64) {
+	if len(userRatings) == 0 {
+		return
+	}
+	sum := 0.0
+	for _, rating := range userRatings {
+		sum += rating
+	}
+	user.RatingsCount = len(userRatings)
+	user.AverageRating = sum / float64(len(userRatings))
+	user.TopGenres = db.topGenres(userRatings, user.AverageRating)
+}
+
+// Estadísticas calculadas con la versión indicada de los ratings del usuario
+type userStatsEntry struct {
+	version       uint64
+	ratingsCount  int
+	averageRating float64
+	topGenres     []string
+}
+
+// Versión actual de los ratings de un usuario. Se llama con db.mu tomado.
+func (db *Database) ratingsVersion(userID int) uint64 {
+	if version, exists := db.ratingsVersions[userID]; exists {
+		return version
+	}
+	return db.ratingsBase
+}
+
+// Refrescar LastAccessed y, si los ratings cambiaron desde el último cálculo
+// (p. ej. tras una recarga del dataset), las estadísticas del usuario (solo
+// en la copia devuelta). Se llama con db.mu tomado (lectura).
+func (db *Database) touchUser(user *User) {
+	user.LastAccessed = time.Now()
+	userRatings := db.Ratings[user.UserID]
+	if len(userRatings) == 0 {
+		return
+	}
+
+	version := db.ratingsVersion(user.UserID)
+	db.statsMu.Lock()
+	entry, cached := db.userStats[user.UserID]
+	db.statsMu.Unlock()
+	if !cached || entry.version != version {
+		db.setUserStats(user, userRatings)
+		entry = userStatsEntry{version, user.RatingsCount, user.AverageRating, user.TopGenres}
+		db.statsMu.Lock()
+		db.userStats[user.UserID] = entry
+		db.statsMu.Unlock()
+	}
+	user.RatingsCount = entry.ratingsCount
+	user.AverageRating = entry.averageRating
+	user.TopGenres = entry.topGenres
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// VALIDACIÓN - Informe de calidad de los datasets
+// ============================================================================
+// -mode validate revisa cada dataset de -datasets con el mismo lector que
+// usan coordinador y workers (ratings_loader.go): movies.csv, ratings.csv y
+// las particiones ratings_part<N>.csv que existan, que deben sumar los mismos
+// ratings. No hace falta WORKERS ni se abre la base de datos. Falla si falta
+// un archivo, si las particiones no cuadran o, con -strict, ante cualquier
+// problema; en modo lenient los problemas solo se informan.
+const ModeValidate = "validate"
+
+type DatasetValidation struct {
+	Dataset          DatasetConfig `json:"dataset"`
+	Movies           int           `json:"movies"`
+	Ratings          *LoadReport   `json:"ratings,omitempty"`
+	Partitions       []*LoadReport `json:"partitions,omitempty"`
+	PartitionRatings int           `json:"partition_ratings"`
+	Errors           []string      `json:"errors,omitempty"`
+}
+
+// Validar todos los datasets; reportPath (opcional) recibe el informe en JSON
+func RunValidate(datasets []DatasetConfig, mode, reportPath string) error {
+	results := make([]*DatasetValidation, 0, len(datasets))
+	failed := 0
+	for _, dataset := range datasets {
+		result := ValidateDataset(dataset, mode)
+		printValidation(result)
+		if len(result.Errors) > 0 {
+			failed++
+		}
+		results = append(results, result)
+	}
+
+	if reportPath != "" {
+		data, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(reportPath, data, 0644); err != nil {
+			return fmt.Errorf("error escribiendo el informe: %v", err)
+		}
+		log.Printf("[VALIDATE] Informe guardado en %s", reportPath)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d de %d datasets con errores", failed, len(datasets))
+	}
+	log.Printf("[VALIDATE] %d datasets válidos (modo %s)", len(datasets), mode)
+	return nil
+}
+
+func ValidateDataset(cfg DatasetConfig, mode string) *DatasetValidation {
+	result := &DatasetValidation{Dataset: cfg}
+
+	movies := newLocalDataSet()
+	var known func(int) bool
+	if err := movies.loadMovies(filepath.Join(cfg.Dir, "movies.csv")); err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("movies.csv: %v", err))
+	} else {
+		result.Movies = len(movies.Movies)
+		known = func(movieID int) bool {
+			_, exists := movies.Movies[movieID]
+			return exists
+		}
+	}
+	opts := LoadOptions{Mode: mode, KnownMovie: known}
+
+	report, err := validateRatingsFile(filepath.Join(cfg.Dir, "ratings.csv"), opts)
+	result.Ratings = report
+	if err != nil {
+		result.Errors = append(result.Errors, err.Error())
+	}
+
+	partitions, err := partitionFiles(cfg.Dir)
+	if err != nil {
+		result.Errors = append(result.Errors, err.Error())
+	}
+	for _, path := range partitions {
+		report, err := validateRatingsFile(path, opts)
+		if report != nil {
+			result.Partitions = append(result.Partitions, report)
+			result.PartitionRatings += report.Loaded
+		}
+		if err != nil {
+			result.Errors = append(result.Errors, err.Error())
+		}
+	}
+
+	// Mismo criterio que la recarga: las particiones son ratings.csv dividido
+	if len(partitions) > 0 && len(result.Errors) == 0 && result.PartitionRatings != result.Ratings.Loaded {
+		result.Errors = append(result.Errors, fmt.Sprintf("las particiones suman %d ratings y ratings.csv tiene %d",
+			result.PartitionRatings, result.Ratings.Loaded))
+	}
+	return result
+}
+
+// Leer un archivo de ratings detectando pares (usuario, película) repetidos
+func validateRatingsFile(path string, opts LoadOptions) (*LoadReport, error) {
+	seen := make(map[int64]struct{})
+	key := func(row RatingRow) int64 {
+		return int64(row.UserID)<<32 | int64(uint32(row.MovieID))
+	}
+	return LoadRatingsCSV(path, opts, func(row RatingRow) bool {
+		_, duplicate := seen[key(row)]
+		return duplicate
+	}, func(row RatingRow, duplicate bool) {
+		seen[key(row)] = struct{}{}
+	})
+}
+
+// ratings_part<N>.csv del directorio, ordenados por N
+func partitionFiles(dir string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "ratings_part*.csv"))
+	if err != nil {
+		return nil, err
+	}
+	number := func(path string) int {
+		n, _ := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), "ratings_part"), ".csv"))
+		return n
+	}
+	sort.Slice(paths, func(i, j int) bool { return number(paths[i]) < number(paths[j]) })
+	return paths, nil
+}
+
+func printValidation(result *DatasetValidation) {
+	log.Printf("[VALIDATE] Dataset %s (%s)", result.Dataset.Name, result.Dataset.Dir)
+	log.Printf("[VALIDATE]   movies.csv: %d películas", result.Movies)
+	if result.Ratings != nil {
+		printLoadReport(result.Ratings, true)
+	}
+	for _, report := range result.Partitions {
+		printLoadReport(report, false)
+	}
+	if len(result.Partitions) > 0 {
+		log.Printf("[VALIDATE]   particiones: %d archivos, %d ratings", len(result.Partitions), result.PartitionRatings)
+	}
+	for _, message := range result.Errors {
+		log.Printf("[VALIDATE]   ❌ %s", message)
+	}
+	if len(result.Errors) == 0 {
+		log.Printf("[VALIDATE]   ✓ sin errores")
+	}
+}
+
+// Problemas con líneas de ejemplo y, para ratings.csv, las distribuciones
+func printLoadReport(report *LoadReport, detailed bool) {
+	log.Printf("[VALIDATE]   %s: %d filas, %d cargadas, %d descartadas",
+		filepath.Base(report.Path), report.Rows, report.Loaded, report.Rejected)
+
+	kinds := make([]string, 0, len(report.Issues))
+	for kind := range report.Issues {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		lines := make([]string, 0, len(report.Samples[kind]))
+		for _, line := range report.Samples[kind] {
+			lines = append(lines, strconv.Itoa(line))
+		}
+		log.Printf("[VALIDATE]     %s: %d (líneas %s)", kind, report.Issues[kind], strings.Join(lines, ", "))
+	}
+	if report.AbortedAt > 0 {
+		log.Printf("[VALIDATE]     lectura interrumpida en la línea %d (strict)", report.AbortedAt)
+		return
+	}
+	if !detailed || report.Loaded == 0 {
+		return
+	}
+
+	values := make([]string, 0, len(report.Histogram))
+	for value := range report.Histogram {
+		values = append(values, value)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		a, _ := strconv.ParseFloat(values[i], 64)
+		b, _ := strconv.ParseFloat(values[j], 64)
+		return a < b
+	})
+	histogram := make([]string, 0, len(values))
+	for _, value := range values {
+		histogram = append(histogram, fmt.Sprintf("%s=%d", value, report.Histogram[value]))
+	}
+	log.Printf("[VALIDATE]     ratings: %s", strings.Join(histogram, " "))
+
+	if report.MaxTimestamp > 0 {
+		log.Printf("[VALIDATE]     timestamps: %s .. %s",
+			time.Unix(report.MinTimestamp, 0).UTC().Format("2006-01-02"),
+			time.Unix(report.MaxTimestamp, 0).UTC().Format("2006-01-02"))
+	}
+	printDistribution("por usuario", "usuarios", report.Users)
+	printDistribution("por película", "películas", report.Movies)
+}
+
+func printDistribution(label, unit string, d DistributionSummary) {
+	log.Printf("[VALIDATE]     %s: %d %s, mín %d, mediana %.0f, media %.1f, p90 %d, máx %d, con un solo rating %d",
+		label, d.Count, unit, d.Min, d.Median, d.Mean, d.P90, d.Max, d.Single)
+}
@@ -1,18 +1,14 @@
 package main
 
 import (
-	"encoding/csv"
 	"encoding/json"
 	"flag"
 	"fmt"
-	"io"
 	"log"
-	"math"
 	"net"
-	"os"
 	"runtime"
 	"sort"
-	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -21,87 +17,86 @@ import (
 // WORKER DISTRIBUIDO - ETAPA 4
 // ============================================================================
 
-type WorkerRating struct {
-	UserID  int
-	MovieID int
-	Rating  float64
-}
-
 type WorkerUserRatings map[int]float64
 
 type WorkerDataSet struct {
 	UserRatingsMap map[int]WorkerUserRatings
+	UserTimestamps map[int]map[int]int64 // userID -> movieID -> timestamp
 	UserAvgRatings map[int]float64
 	AllUserIDs     []int
 	TotalRatings   int
+	Version        string
 	mu             sync.RWMutex
 }
 
+// Particiones de un dataset
+type datasetSlot struct {
+	current  *WorkerDataSet
+	staged   *WorkerDataSet // preparado por una recarga, sin confirmar
+	previous *WorkerDataSet // reemplazado por la última recarga
+}
+
 var (
-	workerDataset *WorkerDataSet
-	workerID      string
+	workerDatasets = make(map[string]*datasetSlot) // nombre -> particiones
+	datasetMu      sync.RWMutex
+	workerID       string
+	loadMode       = LoadLenient // --strict: strict
+	dataRoot       = "datasets"  // --data-root: únicas particiones que acepta una recarga
 )
 
-// Carga de datos de la partición
-func LoadWorkerPartition(filepath string) (*WorkerDataSet, error) {
-	log.Printf("[%s] Cargando partición: %s", workerID, filepath)
-
-	file, err := os.Open(filepath)
-	if err != nil {
-		return nil, err
+// Partición en uso del dataset; las solicitudes toman el puntero una sola vez.
+// No hay dataset implícito: una solicitud sin nombre se rechaza.
+func currentDataset(name string) (*WorkerDataSet, error) {
+	if name == "" {
+		return nil, fmt.Errorf("solicitud sin dataset")
 	}
-	defer file.Close()
-
-	reader := csv.NewReader(file)
-	ratings := make([]WorkerRating, 0)
-	reader.Read() // Skip header
-
-	count := 0
-	for {
-		record, err := reader.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil || len(record) < 3 {
-			continue
-		}
-
-		userID, err1 := strconv.Atoi(record[0])
-		movieID, err2 := strconv.Atoi(record[1])
-		rating, err3 := strconv.ParseFloat(record[2], 64)
-
-		if err1 != nil || err2 != nil || err3 != nil || rating < 0 || rating > 5 {
-			continue
-		}
-
-		ratings = append(ratings, WorkerRating{
-			UserID:  userID,
-			MovieID: movieID,
-			Rating:  rating,
-		})
-
-		count++
-		if count%500000 == 0 {
-			log.Printf("[%s] Procesados: %d ratings...", workerID, count)
-		}
+	datasetMu.RLock()
+	defer datasetMu.RUnlock()
+	slot, exists := workerDatasets[name]
+	if !exists || slot.current == nil {
+		return nil, fmt.Errorf("dataset %q no cargado", name)
 	}
+	return slot.current, nil
+}
 
-	log.Printf("[%s] Total ratings cargados: %d", workerID, count)
+// Carga de datos de la partición
+func LoadWorkerPartition(filepath string) (*WorkerDataSet, error) {
+	log.Printf("[%s] Cargando partición: %s", workerID, filepath)
 
-	// Construir dataset
 	ds := &WorkerDataSet{
 		UserRatingsMap: make(map[int]WorkerUserRatings),
+		UserTimestamps: make(map[int]map[int]int64),
 		UserAvgRatings: make(map[int]float64),
 		AllUserIDs:     make([]int, 0),
-		TotalRatings:   count,
 	}
 
-	for _, r := range ratings {
+	count := 0
+	seen := func(r RatingRow) bool {
+		_, duplicate := ds.UserRatingsMap[r.UserID][r.MovieID]
+		return duplicate
+	}
+	report, err := LoadRatingsCSV(filepath, LoadOptions{Mode: loadMode}, seen, func(r RatingRow, duplicate bool) {
 		if ds.UserRatingsMap[r.UserID] == nil {
 			ds.UserRatingsMap[r.UserID] = make(WorkerUserRatings)
+			ds.UserTimestamps[r.UserID] = make(map[int]int64)
 		}
 		ds.UserRatingsMap[r.UserID][r.MovieID] = r.Rating
+		ds.UserTimestamps[r.UserID][r.MovieID] = r.Timestamp
+
+		// Un par repetido reemplaza el rating anterior sin contarse otra vez
+		if duplicate {
+			return
+		}
+		count++
+		if count%500000 == 0 {
+			log.Printf("[%s] Procesados: %d ratings...", workerID, count)
+		}
+	})
+	if err != nil {
+		return nil, err
 	}
+	ds.TotalRatings = count
+	log.Printf("[%s] Total ratings cargados: %d (%s)", workerID, count, report.Summary())
 
 	// Calcular promedios
 	for userID, userRatings := range ds.UserRatingsMap {
@@ -119,39 +114,16 @@ func LoadWorkerPartition(filepath string) (*WorkerDataSet, error) {
 
 // Cálculo de similitud coseno
 func CosineSimilarityWorker(vec1, vec2 WorkerUserRatings, avg1, avg2 float64) (float64, int) {
-	commonMovies := make([]int, 0)
-	for movieID := range vec1 {
-		if _, exists := vec2[movieID]; exists {
-			commonMovies = append(commonMovies, movieID)
-		}
-	}
-
-	commonCount := len(commonMovies)
-	if commonCount < 3 {
-		return 0.0, commonCount
-	}
-
-	dotProduct := 0.0
-	norm1 := 0.0
-	norm2 := 0.0
-
-	for _, movieID := range commonMovies {
-		r1 := vec1[movieID] - avg1
-		r2 := vec2[movieID] - avg2
-		dotProduct += r1 * r2
-		norm1 += r1 * r1
-		norm2 += r2 * r2
-	}
-
-	if norm1 == 0 || norm2 == 0 {
-		return 0.0, commonCount
-	}
+	return CenteredCosine(vec1, vec2, avg1, avg2)
+}
 
-	return dotProduct / (math.Sqrt(norm1) * math.Sqrt(norm2)), commonCount
+// Similitud coseno con filtro as_of y decaimiento temporal
+func CosineSimilarityWorkerAt(vec1 WorkerUserRatings, ts1 map[int]int64, vec2 WorkerUserRatings, ts2 map[int]int64, avg1 float64, tc *TimeContext) (float64, int) {
+	return TimeWeightedCosine(vec1, ts1, vec2, ts2, avg1, tc)
 }
 
 // Procesar solicitud de similitud
-func ProcessSimilarityRequest(req SimilarityRequest) SimilarityResponse {
+func ProcessSimilarityRequest(dataset *WorkerDataSet, req SimilarityRequest) SimilarityResponse {
 	startTime := time.Now()
 
 	// Métricas de sistema
@@ -159,8 +131,8 @@ func ProcessSimilarityRequest(req SimilarityRequest) SimilarityResponse {
 	runtime.ReadMemStats(&memStats)
 	memBefore := memStats.Alloc
 
-	workerDataset.mu.RLock()
-	defer workerDataset.mu.RUnlock()
+	dataset.mu.RLock()
+	defer dataset.mu.RUnlock()
 
 	targetRatings := req.TargetRatings
 	targetAvg := req.TargetAvg
@@ -169,7 +141,7 @@ func ProcessSimilarityRequest(req SimilarityRequest) SimilarityResponse {
 	usersChecked := 0
 
 	// Sampling de usuarios locales
-	candidateUserIDs := workerDataset.AllUserIDs
+	candidateUserIDs := dataset.AllUserIDs
 	if len(candidateUserIDs) > req.SampleSize {
 		step := len(candidateUserIDs) / req.SampleSize
 		sampled := make([]int, 0, req.SampleSize)
@@ -184,10 +156,17 @@ func ProcessSimilarityRequest(req SimilarityRequest) SimilarityResponse {
 			continue
 		}
 
-		userRatings := workerDataset.UserRatingsMap[userID]
-		userAvg := workerDataset.UserAvgRatings[userID]
+		userRatings := dataset.UserRatingsMap[userID]
+		userAvg := dataset.UserAvgRatings[userID]
 
-		similarity, commonCount := CosineSimilarityWorker(targetRatings, userRatings, targetAvg, userAvg)
+		var similarity float64
+		var commonCount int
+		if req.Time != nil {
+			similarity, commonCount = CosineSimilarityWorkerAt(targetRatings, req.TargetTimestamps,
+				userRatings, dataset.UserTimestamps[userID], targetAvg, req.Time)
+		} else {
+			similarity, commonCount = CosineSimilarityWorker(targetRatings, userRatings, targetAvg, userAvg)
+		}
 		usersChecked++
 
 		if similarity > 0 && commonCount >= 3 {
@@ -225,6 +204,106 @@ func ProcessSimilarityRequest(req SimilarityRequest) SimilarityResponse {
 	}
 }
 
+// Desviaciones Slope One de la partición para los pares de películas pedidos
+func ProcessDeviationRequest(dataset *WorkerDataSet, req DeviationRequest) DeviationResponse {
+	startTime := time.Now()
+
+	index := make(map[int]int, len(req.MovieIDs))
+	for pos, movieID := range req.MovieIDs {
+		index[movieID] = pos
+	}
+	n := len(req.MovieIDs)
+	sums := make([]float64, n*(n-1)/2)
+	counts := make([]int32, n*(n-1)/2)
+
+	dataset.mu.RLock()
+	for _, userRatings := range dataset.UserRatingsMap {
+		AccumulateDeviations(userRatings, index, sums, counts)
+	}
+	dataset.mu.RUnlock()
+
+	return DeviationResponse{
+		WorkerID:    workerID,
+		Sums:        sums,
+		Counts:      counts,
+		ProcessTime: float64(time.Since(startTime).Milliseconds()),
+	}
+}
+
+// Recarga de la partición de un dataset sin reiniciar el worker. La carga se
+// hace aparte y el intercambio de punteros es atómico: las solicitudes en
+// curso terminan con la partición anterior. Un dataset que el worker no tiene
+// se agrega con prepare + commit.
+func ProcessReloadRequest(name string, req ReloadRequest) ReloadResponse {
+	resp := ReloadResponse{WorkerID: workerID}
+	if name == "" {
+		resp.Error = "solicitud de recarga sin dataset"
+		return resp
+	}
+
+	switch req.Phase {
+	case ReloadPrepare:
+		// Solo se descarta lo preparado antes: la versión anterior sigue
+		// disponible para un rollback hasta que se confirme la nueva
+		datasetMu.Lock()
+		if workerDatasets[name] == nil {
+			workerDatasets[name] = &datasetSlot{}
+		}
+		slot := workerDatasets[name]
+		slot.staged = nil
+		datasetMu.Unlock()
+
+		if err := WithinDir(dataRoot, req.Partition); err != nil {
+			resp.Error = fmt.Sprintf("partición rechazada: %v", err)
+			return resp
+		}
+		dataset, err := LoadWorkerPartition(req.Partition)
+		if err != nil {
+			resp.Error = fmt.Sprintf("error cargando %s: %v", req.Partition, err)
+			return resp
+		}
+		dataset.Version = req.Version
+		datasetMu.Lock()
+		slot.staged = dataset
+		datasetMu.Unlock()
+		resp.Version, resp.Users, resp.Ratings = dataset.Version, len(dataset.UserRatingsMap), dataset.TotalRatings
+		return resp
+	case ReloadCommit:
+		datasetMu.Lock()
+		slot := workerDatasets[name]
+		if slot == nil || slot.staged == nil || slot.staged.Version != req.Version {
+			datasetMu.Unlock()
+			resp.Error = fmt.Sprintf("versión %s no preparada", req.Version)
+			return resp
+		}
+		slot.previous, slot.current, slot.staged = slot.current, slot.staged, nil
+		datasetMu.Unlock()
+	case ReloadRollback:
+		datasetMu.Lock()
+		slot := workerDatasets[name]
+		if slot != nil {
+			slot.staged = nil
+			if slot.current != nil && slot.current.Version == req.Version && slot.previous != nil {
+				slot.current, slot.previous = slot.previous, slot.current
+			}
+		}
+		datasetMu.Unlock()
+	default:
+		resp.Error = fmt.Sprintf("fase de recarga desconocida: %s", req.Phase)
+		return resp
+	}
+
+	dataset, err := currentDataset(name)
+	if err != nil {
+		// Rollback de un dataset que nunca llegó a confirmarse
+		return resp
+	}
+	resp.Version, resp.Users, resp.Ratings = dataset.Version, len(dataset.UserRatingsMap), dataset.TotalRatings
+	log.Printf("[%s] Recarga %s de %q: versión activa %s (%d usuarios, %d ratings)",
+		workerID, req.Phase, name, resp.Version, resp.Users, resp.Ratings)
+	return resp
+}
+
 // Manejador de conexiones TCP
 func handleConnection(conn net.Conn) {
 	defer conn.Close()
@@ -232,22 +311,56 @@ func handleConnection(conn net.Conn) {
 	decoder := json.NewDecoder(conn)
 	encoder := json.NewEncoder(conn)
 
-	var req SimilarityRequest
+	var req WorkerRequest
 	if err := decoder.Decode(&req); err != nil {
 		log.Printf("[%s] Error decodificando solicitud: %v", workerID, err)
 		return
 	}
 
-	log.Printf("[%s] Procesando solicitud para usuario %d", workerID, req.TargetUserID)
+	// La recarga puede agregar el dataset; el resto lo necesita cargado
+	var dataset *WorkerDataSet
+	if req.Op != OpReload {
+		var err error
+		if dataset, err = currentDataset(req.Dataset); err != nil {
+			log.Printf("[%s] Solicitud rechazada: %v", workerID, err)
+			return
+		}
+	}
 
-	response := ProcessSimilarityRequest(req)
+	var response interface{}
+	var processTime float64
+	switch req.Op {
+	case OpSimilarity:
+		log.Printf("[%s] Procesando solicitud para usuario %d", workerID, req.TargetUserID)
+		similarity := ProcessSimilarityRequest(dataset, req.SimilarityRequest)
+		response, processTime = similarity, similarity.ProcessTime
+	case OpDeviations:
+		if req.Deviations == nil {
+			log.Printf("[%s] Solicitud de desviaciones sin películas", workerID)
+			return
+		}
+		log.Printf("[%s] Calculando desviaciones Slope One para %d películas", workerID, len(req.Deviations.MovieIDs))
+		deviations := ProcessDeviationRequest(dataset, *req.Deviations)
+		response, processTime = deviations, deviations.ProcessTime
+	case OpReload:
+		if req.Reload == nil {
+			log.Printf("[%s] Solicitud de recarga sin parámetros", workerID)
+			return
+		}
+		start := time.Now()
+		response = ProcessReloadRequest(req.Dataset, *req.Reload)
+		processTime = float64(time.Since(start).Milliseconds())
+	default:
+		log.Printf("[%s] Operación desconocida: %s", workerID, req.Op)
+		return
+	}
 
 	if err := encoder.Encode(response); err != nil {
 		log.Printf("[%s] Error enviando respuesta: %v", workerID, err)
 		return
 	}
 
-	log.Printf("[%s] Solicitud completada en %.2fms", workerID, response.ProcessTime)
+	log.Printf("[%s] Solicitud completada en %.2fms", workerID, processTime)
 }
 
 // Servidor TCP del worker
@@ -259,8 +372,10 @@ func startWorkerServer(listenAddr string) {
 	defer listener.Close()
 
 	log.Printf("[%s] Worker escuchando en %s", workerID, listenAddr)
-	log.Printf("[%s] Dataset cargado: %d usuarios, %d ratings",
-		workerID, len(workerDataset.UserRatingsMap), workerDataset.TotalRatings)
+	for name, slot := range workerDatasets {
+		log.Printf("[%s] Dataset %s cargado: %d usuarios, %d ratings",
+			workerID, name, len(slot.current.UserRatingsMap), slot.current.TotalRatings)
+	}
 
 	for {
 		conn, err := listener.Accept()
@@ -276,9 +391,17 @@ func startWorkerServer(listenAddr string) {
 func main() {
 	listenAddr := flag.String("listen", ":9001", "Dirección de escucha del worker (ej: :9001)")
 	partitionFile := flag.String("partition", "", "Archivo de partición de datos")
+	datasetName := flag.String("dataset", "ml-25m", "Nombre del dataset de --partition (el mismo que en -datasets del coordinador)")
 	workerName := flag.String("name", "", "Nombre del worker")
+	extraDatasets := flag.String("datasets", "", "Otros datasets: nombre=partición separados por comas")
+	strict := flag.Bool("strict", false, "Rechazar una partición con cualquier fila inválida o anómala")
+	flag.StringVar(&dataRoot, "data-root", dataRoot, "Directorio del que una recarga puede cargar particiones")
 	flag.Parse()
 
+	if *strict {
+		loadMode = LoadStrict
+	}
+
 	if *partitionFile == "" {
 		log.Fatal("Debe especificar un archivo de partición con --partition")
 	}
@@ -290,11 +413,30 @@ func main() {
 		workerID = fmt.Sprintf("worker%s", *listenAddr)
 	}
 
-	// Cargar partición de datos
-	var err error
-	workerDataset, err = LoadWorkerPartition(*partitionFile)
-	if err != nil {
-		log.Fatalf("[%s] Error cargando partición: %v", workerID, err)
+	// Cargar la partición de cada dataset con su nombre
+	if *datasetName == "" {
+		log.Fatalf("[%s] --dataset no puede estar vacío", workerID)
+	}
+	partitions := map[string]string{*datasetName: *partitionFile}
+	if *extraDatasets != "" {
+		for _, entry := range strings.Split(*extraDatasets, ",") {
+			parts := strings.SplitN(strings.TrimSpace(entry), "=", 2)
+			if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+				log.Fatalf("[%s] Dataset inválido en --datasets: %q (nombre=partición)", workerID, entry)
+			}
+			if _, exists := partitions[parts[0]]; exists {
+				log.Fatalf("[%s] Dataset repetido: %s", workerID, parts[0])
+			}
+			partitions[parts[0]] = parts[1]
+		}
+	}
+	for name, partition := range partitions {
+		dataset, err := LoadWorkerPartition(partition)
+		if err != nil {
+			log.Fatalf("[%s] Error cargando partición %s: %v", workerID, partition, err)
+		}
+		dataset.Version = initialDatasetVersion
+		workerDatasets[name] = &datasetSlot{current: dataset}
 	}
 
 	log.Printf("[%s] Inicializado correctamente", workerID)